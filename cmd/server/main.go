@@ -6,16 +6,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/Jancd/1504/internal/client"
 	"github.com/Jancd/1504/internal/handler"
+	"github.com/Jancd/1504/internal/imagegen"
+	"github.com/Jancd/1504/internal/llm"
 	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/internal/style"
+	"github.com/Jancd/1504/internal/tts"
 	"github.com/Jancd/1504/internal/task"
 	"github.com/Jancd/1504/pkg/config"
 	"github.com/Jancd/1504/pkg/ffmpeg"
 	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -35,95 +41,107 @@ func main() {
 
 	logger.Info("Starting MVP Video Generator Server")
 
-	// 检查FFmpeg是否已安装（仅在local_sd模式下必需）
+	// 检查FFmpeg是否已安装（仅七牛云single模式不需要本地渲染）
 	ff := ffmpeg.New()
 	if err := ff.CheckInstalled(); err != nil {
-		if cfg.VideoGeneration.Type == "local_sd" {
-			logger.Fatal("FFmpeg check failed (required for local_sd mode)", zap.Error(err))
+		if cfg.VideoGeneration.Type != "qiniu" || cfg.VideoGeneration.Qiniu.Mode != "single" {
+			logger.Fatal("FFmpeg check failed (required for local rendering)", zap.Error(err))
 		} else {
-			logger.Warn("FFmpeg not found (not required for qiniu mode)", zap.Error(err))
+			logger.Warn("FFmpeg not found (not required for qiniu single mode)", zap.Error(err))
 		}
 	}
 
-	// 创建OpenAI客户端
-	openaiClient := client.NewOpenAIClient(
-		cfg.OpenAI.APIKey,
-		cfg.OpenAI.Model,
-		cfg.OpenAI.BaseURL,
-		cfg.OpenAI.Timeout,
-	)
-	logger.Info("OpenAI client initialized", zap.String("model", cfg.OpenAI.Model))
+	// 创建各步骤的大语言模型客户端
+	parseClient := newLLMClient(cfg, "parse", cfg.LLM.Parse)
+	storyboardClient := newLLMClient(cfg, "storyboard", cfg.LLM.Storyboard)
+	characterClient := newLLMClient(cfg, "characters", cfg.LLM.Characters)
 
 	// 创建视频生成客户端
-	var sdClient *client.SDClient
+	var imageGenerator imagegen.Generator
 	var qiniuVideoClient *client.QiniuVideoClient
 
 	switch cfg.VideoGeneration.Type {
 	case "qiniu":
 		// 七牛云文生视频
-		qiniuVideoClient = client.NewQiniuVideoClient(
-			cfg.VideoGeneration.Qiniu.APIURL,
-			cfg.VideoGeneration.Qiniu.APIKey,
-			cfg.VideoGeneration.Qiniu.Model,
-			cfg.VideoGeneration.Qiniu.Timeout,
-		)
-		logger.Info("Qiniu Video client initialized",
-			zap.String("api_url", cfg.VideoGeneration.Qiniu.APIURL),
-			zap.String("model", cfg.VideoGeneration.Qiniu.Model))
-
-		// 检查健康状态
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := qiniuVideoClient.CheckHealth(ctx); err != nil {
-			logger.Warn("Qiniu Video API health check failed", zap.Error(err))
-		}
+		qiniuVideoClient = newQiniuVideoClient(cfg)
 
 	case "local_sd":
-		// 本地Stable Diffusion
-		sdClient = client.NewSDClient(
-			cfg.VideoGeneration.LocalSD.APIURL,
-			cfg.VideoGeneration.LocalSD.Timeout,
-		)
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := sdClient.CheckHealth(ctx); err != nil {
-			logger.Warn("Stable Diffusion API health check failed (service may not be running)", zap.Error(err))
-		} else {
-			logger.Info("Stable Diffusion client initialized",
-				zap.String("api_url", cfg.VideoGeneration.LocalSD.APIURL))
-		}
+		// 本地Stable Diffusion(或其他图像生成后端)
+		imageGenerator = newImageGenerator(cfg)
+
+	case "hybrid":
+		// 混合模式: SD生成关键帧,七牛云图生视频
+		imageGenerator = newImageGenerator(cfg)
+		qiniuVideoClient = newQiniuVideoClient(cfg)
 
 	default:
 		logger.Fatal("Unsupported video generation type", zap.String("type", cfg.VideoGeneration.Type))
 	}
 
-	// 确保七牛云服务可用
-	if cfg.VideoGeneration.Type == "qiniu" && qiniuVideoClient == nil {
-		logger.Fatal("Qiniu video client is required but not initialized")
+	// 创建任务存储
+	var taskStore task.Store
+	switch cfg.Storage.TaskStore {
+	case "memory":
+		taskStore = task.NewMemoryStore()
+	default:
+		fileStore, err := task.NewFileStore(filepath.Join(cfg.Storage.DataDir, "tasks"))
+		if err != nil {
+			logger.Fatal("Failed to initialize task store", zap.Error(err))
+		}
+		taskStore = fileStore
 	}
+	logger.Info("Task store initialized", zap.String("type", cfg.Storage.TaskStore))
 
 	// 创建任务管理器
-	taskManager := task.NewManager()
+	taskManager := task.NewManager(taskStore)
+
+	// 创建剧集管理器,剧集记录与任务记录使用相同的存储方式
+	seriesDir := ""
+	if cfg.Storage.TaskStore != "memory" {
+		seriesDir = filepath.Join(cfg.Storage.DataDir, "series")
+	}
+	seriesManager, err := task.NewSeriesManager(seriesDir)
+	if err != nil {
+		logger.Fatal("Failed to initialize series store", zap.Error(err))
+	}
+
+	// 尚未提交的剧集分集保持排队,由上一集完成后提交
+	if n := taskManager.RecoverInterrupted(seriesManager.Pending); n > 0 {
+		logger.Warn("Marked unfinished tasks as interrupted", zap.Int("count", n))
+	}
+
+	// 加载风格预设
+	styleRegistry, err := style.NewRegistry(cfg.Styles.Dir)
+	if err != nil {
+		logger.Fatal("Failed to load style presets", zap.Error(err))
+	}
 
 	// 创建服务
-	parserService := service.NewParserService(openaiClient, cfg.Storage.DataDir)
-	storyboardService := service.NewStoryboardService(openaiClient, cfg.Storage.DataDir)
+	parserService := service.NewParserService(parseClient, cfg.Storage.DataDir, cfg.Parsing.ChunkSize, cfg.Parsing.MaxInFlight)
+	pacer := service.NewPacer(cfg.Video.ReadingSpeed, cfg.Video.MinShotDuration)
+	storyboardService := service.NewStoryboardService(storyboardClient, styleRegistry, pacer, cfg.Storage.DataDir)
+	characterService := service.NewCharacterService(characterClient, cfg.Storage.DataDir, cfg.Parsing.ChunkSize)
 
-	// 解析视频分辨率
-	var width, height int
-	switch cfg.Video.Resolution {
-	case "1920x1080":
-		width, height = 1920, 1080
-	case "1280x720":
-		width, height = 1280, 720
-	case "3840x2160":
-		width, height = 3840, 2160
-	default:
+	// 解析视频基准分辨率,实际输出尺寸按任务的画面比例换算
+	width, height, err := utils.ParseResolution(cfg.Video.Resolution)
+	if err != nil {
+		logger.Warn("Invalid video resolution, using 1920x1080", zap.Error(err))
 		width, height = 1920, 1080
 	}
 
-	imageService := service.NewImageService(sdClient, storyboardService, cfg.Storage.DataDir, width, height)
-	renderService := service.NewRenderService(cfg.Storage.DataDir, cfg.Video.FPS)
+	imageService := service.NewImageService(imageGenerator, storyboardService, characterService, styleRegistry, cfg.Storage.DataDir, width, height, cfg.VideoGeneration.LocalSD.ImageSize)
+	// 创建配音服务(可选)
+	var voiceService *service.VoiceService
+	if cfg.TTS.Enabled {
+		provider, err := tts.New(cfg.TTS, cfg.OpenAI)
+		if err != nil {
+			logger.Fatal("Failed to initialize TTS provider", zap.Error(err))
+		}
+		voiceService = service.NewVoiceService(provider, characterService, cfg.Storage.DataDir, cfg.TTS.DefaultVoice, cfg.TTS.Voices, cfg.TTS.Ducking)
+		logger.Info("TTS voice-over enabled", zap.String("provider", cfg.TTS.Provider))
+	}
+
+	renderService := service.NewRenderService(cfg.Storage.DataDir, width, height, cfg.Video.FPS, cfg.Video.TransitionDuration, cfg.Video.KenBurns, voiceService, storyboardService)
 
 	// 创建七牛云视频服务
 	var qiniuVideoService *service.QiniuVideoService
@@ -131,20 +149,47 @@ func main() {
 	if qiniuVideoClient != nil {
 		qiniuVideoService = service.NewQiniuVideoService(
 			qiniuVideoClient,
+			characterService,
+			styleRegistry,
 			cfg.Storage.DataDir,
 			cfg.VideoGeneration.Qiniu.MaxWaitTime,
+			width,
+			height,
+			cfg.VideoGeneration.Qiniu.MaxInFlight,
+			cfg.VideoGeneration.Qiniu.ClipLength,
 		)
 		logger.Info("Qiniu Video Service initialized")
 	}
 
+	// 创建回调服务
+	webhookService := service.NewWebhookService(
+		cfg.Webhook.Timeout,
+		cfg.Webhook.MaxAttempts,
+		cfg.Webhook.InitialBackoff,
+	)
+
+	// 创建试运行计划服务
+	planService := service.NewPlanService(
+		characterService,
+		cfg.Estimate,
+		service.VideoMode(cfg.VideoGeneration),
+		cfg.VideoGeneration.Qiniu.MaxInFlight,
+		cfg.Storage.DataDir,
+	)
+
 	// 创建HTTP处理器
 	videoHandler := handler.NewVideoHandler(
 		taskManager,
+		seriesManager,
 		parserService,
 		storyboardService,
+		characterService,
 		imageService,
 		renderService,
 		qiniuVideoService,
+		webhookService,
+		planService,
+		styleRegistry,
 		cfg,
 	)
 
@@ -159,7 +204,7 @@ func main() {
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -182,10 +227,32 @@ func main() {
 	api := r.Group("/api")
 	{
 		api.POST("/generate", videoHandler.Generate)
+		api.POST("/upload", videoHandler.Upload)
+		api.POST("/upload/inspect", videoHandler.InspectUpload)
+		api.POST("/plan", videoHandler.CreatePlan)
+		api.GET("/plan/:plan_id", videoHandler.GetPlan)
+		api.POST("/plan/:plan_id/promote", videoHandler.PromotePlan)
+		api.DELETE("/plan/:plan_id", videoHandler.DeletePlan)
 		api.GET("/tasks/:task_id", videoHandler.GetTask)
 		api.GET("/tasks", videoHandler.ListTasks)
 		api.GET("/download/:task_id", videoHandler.Download)
 		api.DELETE("/tasks/:task_id", videoHandler.DeleteTask)
+		api.POST("/tasks/:task_id/cancel", videoHandler.CancelTask)
+		api.POST("/tasks/:task_id/retry", videoHandler.RetryTask)
+		api.GET("/tasks/:task_id/events", videoHandler.TaskEvents)
+		api.POST("/tasks/:task_id/shots/:shot_id/regenerate", videoHandler.RegenerateShot)
+		api.GET("/tasks/:task_id/storyboard", videoHandler.GetStoryboard)
+		api.PUT("/tasks/:task_id/storyboard", videoHandler.UpdateStoryboard)
+		api.POST("/tasks/:task_id/approve", videoHandler.ApproveStoryboard)
+		api.GET("/tasks/:task_id/characters", videoHandler.GetCharacters)
+		api.PUT("/tasks/:task_id/characters", videoHandler.UpdateCharacters)
+		api.GET("/styles", videoHandler.ListStyles)
+		api.POST("/series", videoHandler.CreateSeries)
+		api.GET("/series", videoHandler.ListSeries)
+		api.GET("/series/:series_id", videoHandler.GetSeries)
+		api.POST("/series/:series_id/cancel", videoHandler.CancelSeries)
+		api.DELETE("/series/:series_id", videoHandler.DeleteSeries)
+		api.GET("/series/:series_id/download", videoHandler.DownloadSeries)
 	}
 
 	// 启动服务器
@@ -198,6 +265,8 @@ func main() {
 		Addr:    addr,
 		Handler: r,
 	}
+	// 关闭时先结束任务事件流,Shutdown 不会等待这些长连接直到超时
+	srv.RegisterOnShutdown(videoHandler.CloseStreams)
 
 	// 优雅关闭
 	go func() {
@@ -217,8 +286,72 @@ func main() {
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatal("Server forced to shutdown", zap.Error(err))
+		logger.Error("Server forced to shutdown", zap.Error(err))
+	}
+
+	// 回调投递可能仍在重试,单独给出覆盖完整重试窗口的等待时间
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), webhookService.MaxDuration())
+	defer closeCancel()
+	videoHandler.Close(closeCtx)
+	if err := taskManager.Close(); err != nil {
+		logger.Error("Failed to flush task store", zap.Error(err))
 	}
 
 	logger.Info("Server exited")
 }
+
+// newLLMClient 按步骤配置创建大语言模型客户端
+func newLLMClient(cfg *config.Config, step string, stepCfg config.LLMStepConfig) *client.LLMClient {
+	provider, err := llm.New(stepCfg, cfg.LLM, cfg.OpenAI)
+	if err != nil {
+		logger.Fatal("Failed to initialize LLM provider", zap.String("step", step), zap.Error(err))
+	}
+	logger.Info("LLM client initialized",
+		zap.String("step", step),
+		zap.String("provider", provider.Name()))
+
+	return client.NewLLMClient(provider, cfg.LLM.MaxRepairs)
+}
+
+// newQiniuVideoClient 创建七牛云视频客户端并检查健康状态
+func newQiniuVideoClient(cfg *config.Config) *client.QiniuVideoClient {
+	qiniuVideoClient := client.NewQiniuVideoClient(
+		cfg.VideoGeneration.Qiniu.APIURL,
+		cfg.VideoGeneration.Qiniu.APIKey,
+		cfg.VideoGeneration.Qiniu.Model,
+		cfg.VideoGeneration.Qiniu.Timeout,
+	)
+	logger.Info("Qiniu Video client initialized",
+		zap.String("api_url", cfg.VideoGeneration.Qiniu.APIURL),
+		zap.String("model", cfg.VideoGeneration.Qiniu.Model))
+
+	// 检查健康状态
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := qiniuVideoClient.CheckHealth(ctx); err != nil {
+		logger.Warn("Qiniu Video API health check failed", zap.Error(err))
+	}
+
+	return qiniuVideoClient
+}
+
+// newImageGenerator 按配置创建图像生成后端并检查健康状态
+func newImageGenerator(cfg *config.Config) imagegen.Generator {
+	generator, err := imagegen.New(cfg.VideoGeneration.LocalSD, cfg.OpenAI)
+	if err != nil {
+		logger.Fatal("Failed to initialize image generator", zap.Error(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := generator.CheckHealth(ctx); err != nil {
+		logger.Warn("Image generation backend health check failed (service may not be running)",
+			zap.String("backend", cfg.VideoGeneration.LocalSD.Backend),
+			zap.Error(err))
+	} else {
+		logger.Info("Image generator initialized",
+			zap.String("backend", cfg.VideoGeneration.LocalSD.Backend))
+	}
+
+	return generator
+}
@@ -0,0 +1,397 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Jancd/1504/internal/llm"
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidLLMResponse 模型回复在纠正后仍不符合格式要求
+var ErrInvalidLLMResponse = errors.New("invalid llm response")
+
+// LLMClient 剧本解析、分镜和角色设定的大语言模型客户端
+type LLMClient struct {
+	provider   llm.Provider
+	maxRepairs int // 回复校验失败时最多请求模型纠正的次数
+}
+
+// NewLLMClient 创建大语言模型客户端
+func NewLLMClient(provider llm.Provider, maxRepairs int) *LLMClient {
+	return &LLMClient{
+		provider:   provider,
+		maxRepairs: maxRepairs,
+	}
+}
+
+// ParseScript 解析剧本
+func (c *LLMClient) ParseScript(ctx context.Context, text string) (*model.ParsedScript, error) {
+	logger.Info("Calling LLM to parse script",
+		zap.String("provider", c.provider.Name()),
+		zap.Int("text_length", len(text)))
+
+	prompt := fmt.Sprintf(`你是一个专业的剧本分析师。请分析以下小说文本,提取关键信息并生成结构化数据。
+
+文本:
+%s
+
+要求:
+1. 识别所有场景,包括地点和时间
+2. 提取所有出现的角色
+3. 识别每个场景中的对话和动作
+4. 分析角色的情绪状态
+5. 统计元数据信息
+
+请严格按照以下JSON格式返回(不要添加任何markdown标记):
+{
+    "scenes": [
+        {
+            "id": 1,
+            "location": "场景地点描述",
+            "time": "时间描述(如:傍晚、清晨等)",
+            "characters": ["角色1", "角色2"],
+            "dialogues": [
+                {
+                    "character": "角色名",
+                    "text": "对话内容",
+                    "emotion": "情绪(如:高兴、悲伤、紧张等)"
+                }
+            ],
+            "actions": [
+                {
+                    "character": "角色名",
+                    "description": "动作描述"
+                }
+            ]
+        }
+    ],
+    "characters": ["角色1", "角色2", "角色3"],
+    "metadata": {
+        "total_scenes": 1,
+        "word_count": 100
+    }
+}`, text)
+
+	parsed, err := completeJSON(ctx, c, "parse", llm.Request{
+		System:      "你是一个专业的剧本分析师,擅长从文本中提取结构化信息。请始终以JSON格式返回结果,不要添加任何markdown代码块标记。",
+		Prompt:      prompt,
+		Temperature: 0.3, // 降低温度以获得更稳定的输出
+		JSON:        true,
+	}, (*model.ParsedScript).Normalize)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Script parsed successfully",
+		zap.Int("scenes", len(parsed.Scenes)),
+		zap.Int("characters", len(parsed.Characters)))
+
+	return parsed, nil
+}
+
+// GenerateStoryboard 生成分镜脚本
+// episode 非空时按剧集分集设计,开头加前情回顾镜头,非最后一集以悬念镜头结尾
+func (c *LLMClient) GenerateStoryboard(ctx context.Context, parsed *model.ParsedScript, targetDuration int, aspectRatio string, episode *model.Episode) (*model.Storyboard, error) {
+	logger.Info("Calling LLM to generate storyboard",
+		zap.String("provider", c.provider.Name()),
+		zap.Int("scenes", len(parsed.Scenes)),
+		zap.Int("target_duration", targetDuration),
+		zap.String("aspect_ratio", aspectRatio))
+
+	// 将解析结果转为JSON
+	parsedJSON, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal parsed script: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`你是一个专业的分镜师。基于以下剧本解析结果,设计详细的分镜脚本。
+
+剧本解析结果:
+%s
+
+目标视频时长: %d秒
+画面比例: %s(%s)
+
+要求:
+1. 为每个重要对话和动作创建独立镜头
+2. 合理分配镜头类型(特写closeup、中景medium、远景long)
+3. 为每个镜头分配合适的时长(2-8秒)
+4. 设计转场效果(cut直切、fade淡入淡出、dissolve溶解)
+5. 为每个镜头生成详细的画面描述,用于AI绘图
+6. 总时长应接近目标时长
+7. 画面描述的构图必须适配画面比例
+%s
+镜头类型选择原则:
+- 对话场景: 多用特写(closeup)展现表情
+- 动作场景: 使用中景(medium)
+- 场景介绍: 使用远景(long)
+
+转场选择原则:
+- 同一场景内对话: cut直切
+- 场景转换: fade或dissolve
+- 时间跳跃: fade
+
+请严格按照以下JSON格式返回(不要添加任何markdown标记):
+{
+    "shots": [
+        {
+            "id": 1,
+            "type": "closeup",
+            "description": "详细的画面描述,包含环境、角色外貌、动作、表情等",
+            "characters": ["角色1"],
+            "duration": 3.0,
+            "transition": "cut",
+            "dialogue": {
+                "character": "角色1",
+                "text": "对话内容",
+                "emotion": "情绪"
+            }
+        }
+    ],
+    "total_duration": 60.0
+}`, string(parsedJSON), targetDuration, aspectRatio, framingHint(aspectRatio), episodeHint(episode))
+
+	storyboard, err := completeJSON(ctx, c, "storyboard", llm.Request{
+		System:      "你是一个专业的分镜师,擅长将剧本转换为视觉化的分镜脚本。请始终以JSON格式返回结果,不要添加任何markdown代码块标记。",
+		Prompt:      prompt,
+		Temperature: 0.5,
+		JSON:        true,
+	}, func(storyboard *model.Storyboard) []string {
+		return storyboard.Normalize(parsed.Characters)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Storyboard generated successfully",
+		zap.Int("shots", len(storyboard.Shots)),
+		zap.Float64("total_duration", storyboard.TotalDuration))
+
+	return storyboard, nil
+}
+
+// ExtractCharacters 提取角色外貌设定
+func (c *LLMClient) ExtractCharacters(ctx context.Context, text string, names []string) ([]model.Character, error) {
+	logger.Info("Calling LLM to extract character sheet",
+		zap.String("provider", c.provider.Name()),
+		zap.Int("characters", len(names)))
+
+	namesJSON, err := json.Marshal(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal character names: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`你是一个专业的角色设计师。请根据以下小说文本,为每个角色设计固定的外貌设定,用于保证AI绘图时同一角色在所有镜头中外观一致。
+
+文本:
+%s
+
+角色列表:
+%s
+
+要求:
+1. 为角色列表中的每个角色输出一条设定,name必须与列表中的名称完全一致
+2. 优先使用文本中的外貌描写,文本未提及的部分根据角色身份合理补充
+3. appearance、hair、clothing、age、palette 使用简洁的英文短语,可直接用作绘图Prompt
+4. 同一作品中的角色外貌应有明显区分
+
+请严格按照以下JSON格式返回(不要添加任何markdown标记):
+{
+    "characters": [
+        {
+            "name": "角色名",
+            "appearance": "slender build, fair skin, large brown eyes",
+            "hair": "long straight black hair with bangs",
+            "clothing": "navy school uniform with red ribbon",
+            "age": "teenage girl, 16 years old",
+            "palette": "navy and red"
+        }
+    ]
+}`, text, string(namesJSON))
+
+	sheet, err := completeJSON[model.CharacterSheet](ctx, c, "characters", llm.Request{
+		System:      "你是一个专业的角色设计师,擅长为角色设计统一的视觉形象。请始终以JSON格式返回结果,不要添加任何markdown代码块标记。",
+		Prompt:      prompt,
+		Temperature: 0.3,
+		JSON:        true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return sheet.Characters, nil
+}
+
+// ResolveAliases 识别指向同一角色的不同称呼
+// samples 为角色名对应的原文台词示例;返回别名到标准名的映射,标准名和别名都来自 names
+func (c *LLMClient) ResolveAliases(ctx context.Context, names []string, samples map[string][]string) (map[string]string, error) {
+	logger.Info("Calling LLM to resolve character aliases",
+		zap.String("provider", c.provider.Name()),
+		zap.Int("characters", len(names)))
+
+	type entry struct {
+		Name    string   `json:"name"`
+		Samples []string `json:"samples,omitempty"`
+	}
+	entries := make([]entry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, entry{Name: name, Samples: samples[name]})
+	}
+	entriesJSON, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal character names: %w", err)
+	}
+
+	prompt := fmt.Sprintf(`以下角色名来自同一部小说分段解析的结果,不同段落可能用不同称呼指代同一角色(如全名、姓氏、昵称、称谓)。
+请根据名称和台词示例,找出指向同一角色的称呼。
+
+角色列表:
+%s
+
+要求:
+1. 只合并确定是同一角色的称呼,不确定时不要合并
+2. 每组选择最完整、最正式的称呼作为标准名
+3. alias 和 canonical 都必须与角色列表中的名称完全一致
+4. 没有别名时返回空数组
+
+请严格按照以下JSON格式返回(不要添加任何markdown标记):
+{
+    "aliases": [
+        {"alias": "老李", "canonical": "李明"}
+    ]
+}`, string(entriesJSON))
+
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+
+	type aliasResult struct {
+		Aliases []struct {
+			Alias     string `json:"alias"`
+			Canonical string `json:"canonical"`
+		} `json:"aliases"`
+	}
+
+	result, err := completeJSON(ctx, c, "aliases", llm.Request{
+		System:      "你是一个专业的剧本分析师,擅长识别小说中角色的不同称呼。请始终以JSON格式返回结果,不要添加任何markdown代码块标记。",
+		Prompt:      prompt,
+		Temperature: 0.1,
+		JSON:        true,
+	}, func(result *aliasResult) []string {
+		var problems []string
+		for _, a := range result.Aliases {
+			if !known[a.Alias] {
+				problems = append(problems, fmt.Sprintf("alias %q is not in the character list", a.Alias))
+			}
+			if !known[a.Canonical] {
+				problems = append(problems, fmt.Sprintf("canonical %q is not in the character list", a.Canonical))
+			}
+		}
+		return problems
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make(map[string]string)
+	for _, a := range result.Aliases {
+		if a.Alias != a.Canonical {
+			aliases[a.Alias] = a.Canonical
+		}
+	}
+	return aliases, nil
+}
+
+// completeJSON 请求模型返回JSON并解析校验
+// JSON无法解析或 validate 返回问题时,把原回复和具体问题发回模型要求纠正,最多纠正 maxRepairs 次
+func completeJSON[T any](ctx context.Context, c *LLMClient, step string, req llm.Request, validate func(*T) []string) (*T, error) {
+	for attempt := 0; ; attempt++ {
+		content, err := c.provider.Complete(ctx, req)
+		if err != nil {
+			logger.Error("Failed to call LLM", zap.String("step", step), zap.Error(err))
+			return nil, fmt.Errorf("llm call failed: %w", err)
+		}
+
+		logger.Debug("LLM response received",
+			zap.String("step", step),
+			zap.Int("attempt", attempt),
+			zap.String("content", content))
+
+		var result T
+		var problems []string
+		if err := json.Unmarshal([]byte(content), &result); err != nil {
+			problems = []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+		} else if validate != nil {
+			problems = validate(&result)
+		}
+
+		if len(problems) == 0 {
+			return &result, nil
+		}
+
+		logger.Warn("LLM response failed validation",
+			zap.String("step", step),
+			zap.Int("attempt", attempt),
+			zap.Strings("problems", problems))
+
+		if attempt >= c.maxRepairs {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidLLMResponse, strings.Join(problems, "; "))
+		}
+
+		req.History = append(req.History,
+			llm.Message{Role: llm.RoleUser, Content: req.Prompt},
+			llm.Message{Role: llm.RoleAssistant, Content: content})
+		req.Prompt = repairPrompt(problems)
+	}
+}
+
+// repairPrompt 要求模型纠正回复的追问
+func repairPrompt(problems []string) string {
+	var b strings.Builder
+	b.WriteString("你上一次返回的JSON存在以下问题:\n")
+	for i, problem := range problems {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, problem)
+	}
+	b.WriteString("\n请修正以上所有问题,按原来要求的格式重新返回完整的JSON(不要添加任何markdown标记)。")
+	return b.String()
+}
+
+// episodeHint 剧集分集的额外分镜要求,不是分集任务时返回空
+func episodeHint(episode *model.Episode) string {
+	if episode == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n这是一部连续剧集的第%d集(共%d集),另有以下要求:\n", episode.Number, episode.Total)
+	if episode.Recap != "" {
+		fmt.Fprintf(&b, "- 第一个镜头为前情回顾,用远景或中景简要呈现上一集结尾的情节,时长不超过4秒。上一集结尾:\n%s\n", episode.Recap)
+	}
+	if episode.IsFinal() {
+		b.WriteString("- 这是最后一集,最后一个镜头要给故事一个完整的收尾\n")
+	} else {
+		b.WriteString("- 最后一个镜头停在本集最有悬念的时刻(如角色的惊讶特写或未揭晓的危机),吸引观众观看下一集,不要提前交代后续情节\n")
+	}
+	return b.String()
+}
+
+// framingHint 画面比例对应的构图说明
+func framingHint(aspectRatio string) string {
+	switch aspectRatio {
+	case model.AspectRatioPortrait:
+		return "竖屏短视频,采用竖向构图,主体居中,人物多用半身和特写,避免横向铺开的宽景"
+	case model.AspectRatioSquare:
+		return "方形画面,主体居中,构图紧凑"
+	case model.AspectRatioClassic:
+		return "4:3横屏,构图略紧凑于宽屏"
+	default:
+		return "横屏宽画幅,可使用横向展开的场景构图"
+	}
+}
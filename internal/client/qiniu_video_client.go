@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -44,14 +45,22 @@ type VideoGenerateRequest struct {
 
 // VideoInstance 视频实例
 type VideoInstance struct {
-	Prompt string `json:"prompt"`
+	Prompt string      `json:"prompt"`
+	Image  *VideoImage `json:"image,omitempty"` // 图生视频的首帧
+}
+
+// VideoImage 图生视频输入图像
+type VideoImage struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded"`
+	MimeType           string `json:"mimeType"`
 }
 
 // VideoParameters 视频参数
 type VideoParameters struct {
-	GenerateAudio   bool `json:"generateAudio"`
-	DurationSeconds int  `json:"durationSeconds"`
-	SampleCount     int  `json:"sampleCount"`
+	GenerateAudio   bool   `json:"generateAudio"`
+	DurationSeconds int    `json:"durationSeconds"`
+	SampleCount     int    `json:"sampleCount"`
+	AspectRatio     string `json:"aspectRatio,omitempty"` // 如 16:9、9:16
 }
 
 // VideoGenerateResponse 视频生成响应
@@ -91,22 +100,44 @@ func (r *VideoGenerateResponse) GetVideoURL() string {
 }
 
 // GenerateVideo 生成视频
-func (c *QiniuVideoClient) GenerateVideo(ctx context.Context, prompt string, duration int) (*VideoGenerateResponse, error) {
+// aspectRatio 为空时使用模型默认比例
+func (c *QiniuVideoClient) GenerateVideo(ctx context.Context, prompt string, duration int, aspectRatio string) (*VideoGenerateResponse, error) {
 	logger.Info("Calling Qiniu Video Generation API",
 		zap.String("prompt", prompt),
-		zap.Int("duration", duration))
+		zap.Int("duration", duration),
+		zap.String("aspect_ratio", aspectRatio))
+
+	return c.createTask(ctx, VideoInstance{Prompt: prompt}, duration, aspectRatio)
+}
+
+// GenerateVideoFromImage 以图像为首帧生成视频
+func (c *QiniuVideoClient) GenerateVideoFromImage(ctx context.Context, prompt string, image []byte, mimeType string, duration int, aspectRatio string) (*VideoGenerateResponse, error) {
+	logger.Info("Calling Qiniu Image-to-Video API",
+		zap.String("prompt", prompt),
+		zap.Int("image_size", len(image)),
+		zap.Int("duration", duration),
+		zap.String("aspect_ratio", aspectRatio))
+
+	instance := VideoInstance{
+		Prompt: prompt,
+		Image: &VideoImage{
+			BytesBase64Encoded: base64.StdEncoding.EncodeToString(image),
+			MimeType:           mimeType,
+		},
+	}
+	return c.createTask(ctx, instance, duration, aspectRatio)
+}
 
+// createTask 提交视频生成任务
+func (c *QiniuVideoClient) createTask(ctx context.Context, instance VideoInstance, duration int, aspectRatio string) (*VideoGenerateResponse, error) {
 	// 构建请求 (Veo API格式)
 	req := VideoGenerateRequest{
-		Instances: []VideoInstance{
-			{
-				Prompt: prompt,
-			},
-		},
+		Instances: []VideoInstance{instance},
 		Parameters: VideoParameters{
 			GenerateAudio:   true,
 			DurationSeconds: duration,
 			SampleCount:     1,
+			AspectRatio:     aspectRatio,
 		},
 		Model: c.model,
 	}
@@ -206,8 +237,12 @@ func (c *QiniuVideoClient) QueryTaskStatus(ctx context.Context, taskID string) (
 	return &result, nil
 }
 
+// StatusCallback 轮询状态回调
+type StatusCallback func(result *VideoGenerateResponse, checkCount int)
+
 // WaitForCompletion 等待视频生成完成
-func (c *QiniuVideoClient) WaitForCompletion(ctx context.Context, taskID string, maxWaitTime time.Duration) (*VideoGenerateResponse, error) {
+// onStatus 在每次成功查询到状态后调用,可为nil
+func (c *QiniuVideoClient) WaitForCompletion(ctx context.Context, taskID string, maxWaitTime time.Duration, onStatus StatusCallback) (*VideoGenerateResponse, error) {
 	logger.Info("Waiting for video generation to complete",
 		zap.String("task_id", taskID),
 		zap.Duration("max_wait_time", maxWaitTime))
@@ -241,6 +276,10 @@ func (c *QiniuVideoClient) WaitForCompletion(ctx context.Context, taskID string,
 				zap.String("message", result.Message),
 				zap.Int("check_count", checkCount))
 
+			if onStatus != nil {
+				onStatus(result, checkCount)
+			}
+
 			// 检查是否完成 (七牛云API状态)
 			if result.Status == "Completed" || result.Status == "completed" || result.Status == "success" {
 				videoURL := result.GetVideoURL()
@@ -42,6 +42,28 @@ type Txt2ImgRequest struct {
 	Height         int     `json:"height"`
 	SamplerName    string  `json:"sampler_name"`
 	Seed           int64   `json:"seed,omitempty"`
+
+	AlwaysOnScripts map[string]interface{} `json:"alwayson_scripts,omitempty"`
+}
+
+// SetReferenceImage 通过ControlNet reference_only 使用参考图约束角色外观
+// 需要SD WebUI安装ControlNet扩展
+func (r *Txt2ImgRequest) SetReferenceImage(image []byte) {
+	if r.AlwaysOnScripts == nil {
+		r.AlwaysOnScripts = make(map[string]interface{})
+	}
+	r.AlwaysOnScripts["controlnet"] = map[string]interface{}{
+		"args": []map[string]interface{}{
+			{
+				"enabled":       true,
+				"module":        "reference_only",
+				"image":         base64.StdEncoding.EncodeToString(image),
+				"weight":        0.8,
+				"control_mode":  "Balanced",
+				"pixel_perfect": true,
+			},
+		},
+	}
 }
 
 // Txt2ImgResponse SD文生图响应
@@ -52,23 +74,25 @@ type Txt2ImgResponse struct {
 }
 
 // GenerateImage 生成图像
-func (c *SDClient) GenerateImage(ctx context.Context, prompt, negativePrompt string, width, height int) ([]byte, error) {
-	logger.Info("Generating image with Stable Diffusion",
-		zap.String("prompt", prompt),
-		zap.Int("width", width),
-		zap.Int("height", height))
-
-	// 构建请求
-	req := Txt2ImgRequest{
-		Prompt:         prompt,
-		NegativePrompt: negativePrompt,
-		Steps:          30,
-		CFGScale:       7.5,
-		Width:          width,
-		Height:         height,
-		SamplerName:    "DPM++ 2M Karras",
+// 未设置的采样参数使用默认值;Seed 为-1时由SD随机选择
+func (c *SDClient) GenerateImage(ctx context.Context, req Txt2ImgRequest) ([]byte, error) {
+	if req.Steps <= 0 {
+		req.Steps = 30
+	}
+	if req.CFGScale <= 0 {
+		req.CFGScale = 7.5
+	}
+	if req.SamplerName == "" {
+		req.SamplerName = "DPM++ 2M Karras"
 	}
 
+	logger.Info("Generating image with Stable Diffusion",
+		zap.String("prompt", req.Prompt),
+		zap.Int("width", req.Width),
+		zap.Int("height", req.Height),
+		zap.String("sampler", req.SamplerName),
+		zap.Int64("seed", req.Seed))
+
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
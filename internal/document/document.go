@@ -0,0 +1,181 @@
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	// ErrUnsupportedFormat 不支持的文件类型
+	ErrUnsupportedFormat = errors.New("unsupported document format")
+	// ErrInvalidDocument 文件内容无法解析
+	ErrInvalidDocument = errors.New("invalid document")
+)
+
+// 文件格式
+const (
+	FormatText     = "txt"
+	FormatMarkdown = "md"
+	FormatFountain = "fountain"
+	FormatDocx     = "docx"
+	FormatEpub     = "epub"
+)
+
+// 文本编码
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingGBK     = "gbk"
+)
+
+// MaxExpansion DOCX、EPUB解压后的总字节数上限相对上传文件大小上限的倍数
+const MaxExpansion = 10
+
+// Document 从上传文件中提取的纯文本
+type Document struct {
+	Format   string    `json:"format"`
+	Encoding string    `json:"encoding,omitempty"` // 纯文本文件检测到的编码
+	Text     string    `json:"-"`
+	Length   int       `json:"length"`             // 字数
+	Chapters []Chapter `json:"chapters,omitempty"` // EPUB按阅读顺序排列的章节
+}
+
+// Chapter 电子书章节
+type Chapter struct {
+	Index  int    `json:"index"` // 从1开始
+	Title  string `json:"title"`
+	Length int    `json:"length"`
+	Text   string `json:"-"`
+}
+
+// Format 按文件扩展名判断格式
+func Format(filename string) (string, error) {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")); ext {
+	case FormatText, FormatDocx, FormatEpub, FormatFountain:
+		return ext, nil
+	case FormatMarkdown, "markdown":
+		return FormatMarkdown, nil
+	default:
+		return "", fmt.Errorf("%w: %q, must be one of: .txt, .md, .fountain, .docx, .epub", ErrUnsupportedFormat, filepath.Ext(filename))
+	}
+}
+
+// Extract 按文件扩展名提取纯文本
+// maxDecompressed 为DOCX、EPUB中所有文件解压后的总字节数上限
+func Extract(filename string, data []byte, maxDecompressed int64) (*Document, error) {
+	format, err := Format(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Format: format}
+	switch format {
+	case FormatDocx:
+		doc.Text, err = extractDocx(data, maxDecompressed)
+	case FormatEpub:
+		doc.Chapters, err = extractEpub(data, maxDecompressed)
+		doc.Text = joinChapters(doc.Chapters)
+	default:
+		doc.Text, doc.Encoding, err = DecodeText(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Text = normalizeText(doc.Text)
+	doc.Length = utf8.RuneCountInString(doc.Text)
+	if doc.Length == 0 {
+		return nil, fmt.Errorf("%w: no text found", ErrInvalidDocument)
+	}
+	return doc, nil
+}
+
+// SelectChapters 只保留指定序号的章节,序号从1开始
+func (d *Document) SelectChapters(indexes []int) error {
+	if len(d.Chapters) == 0 {
+		return fmt.Errorf("%w: chapters can only be selected from epub files", ErrInvalidDocument)
+	}
+
+	selected := make([]Chapter, 0, len(indexes))
+	for _, index := range indexes {
+		if index < 1 || index > len(d.Chapters) {
+			return fmt.Errorf("%w: chapter %d out of range 1-%d", ErrInvalidDocument, index, len(d.Chapters))
+		}
+		selected = append(selected, d.Chapters[index-1])
+	}
+
+	d.Chapters = selected
+	d.Text = joinChapters(selected)
+	d.Length = utf8.RuneCountInString(d.Text)
+	return nil
+}
+
+// DecodeText 检测编码并将纯文本转为UTF-8
+// 有BOM时按BOM解码;否则合法的UTF-8按UTF-8处理,不合法时按GBK(GB18030)解码
+func DecodeText(data []byte) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), EncodingUTF8, nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		text, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		return string(text), EncodingUTF16LE, wrapDecodeError(err)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		text, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		return string(text), EncodingUTF16BE, wrapDecodeError(err)
+	case utf8.Valid(data):
+		return string(data), EncodingUTF8, nil
+	}
+
+	text, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", wrapDecodeError(err)
+	}
+	return string(text), EncodingGBK, nil
+}
+
+// wrapDecodeError 包装解码错误
+func wrapDecodeError(err error) error {
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode text: %v", ErrInvalidDocument, err)
+	}
+	return nil
+}
+
+// joinChapters 以空行连接各章节正文
+func joinChapters(chapters []Chapter) string {
+	texts := make([]string, 0, len(chapters))
+	for _, chapter := range chapters {
+		texts = append(texts, chapter.Text)
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// normalizeText 统一换行符,去除行尾空白并合并连续空行
+func normalizeText(text string) string {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\u00a0", " ", "\u200b", "", "\ufeff", "").Replace(text)
+
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\u3000")
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
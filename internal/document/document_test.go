@@ -0,0 +1,213 @@
+package document
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeText(t *testing.T) {
+	encode := func(t *testing.T, encoder interface{ Bytes([]byte) ([]byte, error) }, text string) []byte {
+		t.Helper()
+		data, err := encoder.Bytes([]byte(text))
+		if err != nil {
+			t.Fatalf("encode %q: %v", text, err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     func(t *testing.T) []byte
+		text     string
+		encoding string
+	}{
+		{
+			name:     "utf-8",
+			data:     func(*testing.T) []byte { return []byte("你好，世界") },
+			text:     "你好，世界",
+			encoding: EncodingUTF8,
+		},
+		{
+			name:     "utf-8 with bom",
+			data:     func(*testing.T) []byte { return append([]byte{0xEF, 0xBB, 0xBF}, "你好"...) },
+			text:     "你好",
+			encoding: EncodingUTF8,
+		},
+		{
+			name: "utf-16le with bom",
+			data: func(t *testing.T) []byte {
+				return encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder(), "你好 hi")
+			},
+			text:     "你好 hi",
+			encoding: EncodingUTF16LE,
+		},
+		{
+			name: "utf-16be with bom",
+			data: func(t *testing.T) []byte {
+				return encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder(), "你好 hi")
+			},
+			text:     "你好 hi",
+			encoding: EncodingUTF16BE,
+		},
+		{
+			name: "gbk",
+			data: func(t *testing.T) []byte {
+				return encode(t, simplifiedchinese.GBK.NewEncoder(), "第一章 雨夜\n他推开门。")
+			},
+			text:     "第一章 雨夜\n他推开门。",
+			encoding: EncodingGBK,
+		},
+		{
+			name:     "ascii",
+			data:     func(*testing.T) []byte { return []byte("INT. ROOM - DAY") },
+			text:     "INT. ROOM - DAY",
+			encoding: EncodingUTF8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, encoding, err := DecodeText(tt.data(t))
+			if err != nil {
+				t.Fatalf("DecodeText() error = %v", err)
+			}
+			if text != tt.text || encoding != tt.encoding {
+				t.Errorf("DecodeText() = (%q, %q), want (%q, %q)", text, encoding, tt.text, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+		err      error
+	}{
+		{filename: "novel.TXT", want: FormatText},
+		{filename: "script.markdown", want: FormatMarkdown},
+		{filename: "script.md", want: FormatMarkdown},
+		{filename: "a.b.fountain", want: FormatFountain},
+		{filename: "book.epub", want: FormatEpub},
+		{filename: "draft.docx", want: FormatDocx},
+		{filename: "draft.doc", err: ErrUnsupportedFormat},
+		{filename: "noext", err: ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			got, err := Format(tt.filename)
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("Format() = (%q, %v), want (%q, %v)", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "line endings", text: "a\r\nb\rc", want: "a\nb\nc"},
+		{name: "trailing spaces", text: "a \t\u3000\nb", want: "a\nb"},
+		{name: "blank lines collapsed", text: "\n\na\n\n\n\nb\n\n", want: "a\n\nb"},
+		{name: "invisible characters", text: "\ufeffa\u200bb\u00a0c", want: "ab c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.text); got != tt.want {
+				t.Errorf("normalizeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectChapters(t *testing.T) {
+	chapters := []Chapter{
+		{Index: 1, Title: "一", Text: "甲"},
+		{Index: 2, Title: "二", Text: "乙"},
+		{Index: 3, Title: "三", Text: "丙"},
+	}
+
+	tests := []struct {
+		name    string
+		indexes []int
+		text    string
+		err     bool
+	}{
+		{name: "keeps requested order", indexes: []int{3, 1}, text: "丙\n\n甲"},
+		{name: "out of range", indexes: []int{4}, err: true},
+		{name: "zero", indexes: []int{0}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{Chapters: append([]Chapter(nil), chapters...)}
+			err := doc.SelectChapters(tt.indexes)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidDocument) {
+					t.Errorf("SelectChapters() error = %v, want ErrInvalidDocument", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectChapters() error = %v", err)
+			}
+			if doc.Text != tt.text || doc.Length != len([]rune(tt.text)) {
+				t.Errorf("text = %q (%d), want %q", doc.Text, doc.Length, tt.text)
+			}
+		})
+	}
+
+	if err := (&Document{}).SelectChapters([]int{1}); !errors.Is(err, ErrInvalidDocument) {
+		t.Errorf("SelectChapters() without chapters error = %v, want ErrInvalidDocument", err)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		want     *Document
+		err      error
+	}{
+		{
+			name:     "plain text is normalized",
+			filename: "novel.txt",
+			data:     []byte("第一章\r\n\r\n\r\n他来了。  "),
+			want:     &Document{Format: FormatText, Encoding: EncodingUTF8, Text: "第一章\n\n他来了。", Length: 9},
+		},
+		{
+			name:     "empty text",
+			filename: "empty.md",
+			data:     []byte(" \n\n "),
+			err:      ErrInvalidDocument,
+		},
+		{
+			name:     "unsupported format",
+			filename: "novel.pdf",
+			data:     []byte("%PDF"),
+			err:      ErrUnsupportedFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Extract(tt.filename, tt.data, 1<<20)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Extract() error = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(doc, tt.want) {
+				t.Errorf("Extract() = %+v, want %+v", doc, tt.want)
+			}
+		})
+	}
+}
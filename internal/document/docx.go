@@ -0,0 +1,111 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// extractDocx 提取Word文档正文,每个段落一行
+func extractDocx(data []byte, maxDecompressed int64) (string, error) {
+	archive, err := openZip(data, maxDecompressed)
+	if err != nil {
+		return "", fmt.Errorf("%w: not a docx file: %v", ErrInvalidDocument, err)
+	}
+
+	body, err := readZipFile(archive, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+
+	// 只关心 w:t(文本)、w:tab、w:br 和 w:p(段落结束)
+	var b strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to parse document.xml: %v", ErrInvalidDocument, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "br", "cr":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+// maxEntrySize 压缩包中单个文件解压后的最大字节数,防止压缩炸弹
+const maxEntrySize = 64 << 20
+
+// zipArchive 带解压总量预算的压缩包
+// 所有文件解压后的总字节数不超过预算,防止由大量高压缩比文件组成的压缩炸弹
+type zipArchive struct {
+	*zip.Reader
+	remaining int64
+}
+
+// openZip 打开压缩包,maxDecompressed 为解压总字节数上限
+func openZip(data []byte, maxDecompressed int64) (*zipArchive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &zipArchive{Reader: reader, remaining: maxDecompressed}, nil
+}
+
+// readZipFile 读取压缩包中的文件,读取的字节数计入解压总量
+func readZipFile(archive *zipArchive, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to open %s: %v", ErrInvalidDocument, name, err)
+		}
+		defer rc.Close()
+
+		limit := min(int64(maxEntrySize), archive.remaining)
+		data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read %s: %v", ErrInvalidDocument, name, err)
+		}
+		if int64(len(data)) > limit {
+			if limit == archive.remaining {
+				return nil, fmt.Errorf("%w: decompressed content is too large", ErrInvalidDocument)
+			}
+			return nil, fmt.Errorf("%w: %s is too large", ErrInvalidDocument, name)
+		}
+		archive.remaining -= int64(len(data))
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: %s not found", ErrInvalidDocument, name)
+}
@@ -0,0 +1,142 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// zipEntry 测试压缩包中的文件
+type zipEntry struct {
+	name string
+	body string
+}
+
+// buildZip 按顺序写入文件,生成压缩包
+func buildZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatalf("create %s: %v", entry.name, err)
+		}
+		if _, err := f.Write([]byte(entry.body)); err != nil {
+			t.Fatalf("write %s: %v", entry.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// docxBody 生成包含指定段落XML的 word/document.xml
+func docxBody(paragraphs string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		paragraphs + `</w:body></w:document>`
+}
+
+func TestExtractDocx(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            func(t *testing.T) []byte
+		maxDecompressed int64
+		want            string
+		err             bool
+	}{
+		{
+			name: "paragraphs, tabs and breaks",
+			data: func(t *testing.T) []byte {
+				return buildZip(t, zipEntry{"word/document.xml", docxBody(
+					`<w:p><w:r><w:t>第一</w:t></w:r><w:r><w:t>段</w:t></w:r></w:p>` +
+						`<w:p><w:r><w:t>甲</w:t><w:tab/><w:t>乙</w:t><w:br/><w:t>丙</w:t></w:r></w:p>` +
+						`<w:p><w:pPr><w:rPr><w:b/></w:rPr></w:pPr></w:p>`)})
+			},
+			maxDecompressed: 1 << 20,
+			want:            "第一段\n甲\t乙\n丙\n\n",
+		},
+		{
+			name:            "not a zip",
+			data:            func(*testing.T) []byte { return []byte("plain text") },
+			maxDecompressed: 1 << 20,
+			err:             true,
+		},
+		{
+			name: "missing document.xml",
+			data: func(t *testing.T) []byte {
+				return buildZip(t, zipEntry{"word/styles.xml", "<styles/>"})
+			},
+			maxDecompressed: 1 << 20,
+			err:             true,
+		},
+		{
+			name: "exceeds decompression budget",
+			data: func(t *testing.T) []byte {
+				return buildZip(t, zipEntry{"word/document.xml", docxBody(
+					`<w:p><w:r><w:t>` + strings.Repeat("a", 4096) + `</w:t></w:r></w:p>`)})
+			},
+			maxDecompressed: 1024,
+			err:             true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractDocx(tt.data(t), tt.maxDecompressed)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidDocument) {
+					t.Errorf("extractDocx() error = %v, want ErrInvalidDocument", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractDocx() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("extractDocx() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadZipFileBudget(t *testing.T) {
+	data := buildZip(t,
+		zipEntry{"a.txt", strings.Repeat("a", 600)},
+		zipEntry{"b.txt", strings.Repeat("b", 600)},
+	)
+
+	tests := []struct {
+		name   string
+		budget int64
+		err    bool
+	}{
+		{name: "total fits", budget: 1200},
+		{name: "each fits but total does not", budget: 1199, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive, err := openZip(data, tt.budget)
+			if err != nil {
+				t.Fatalf("openZip() error = %v", err)
+			}
+
+			for _, name := range []string{"a.txt", "b.txt"} {
+				if _, err = readZipFile(archive, name); err != nil {
+					break
+				}
+			}
+			if tt.err != (err != nil) {
+				t.Errorf("readZipFile() error = %v, want error %v", err, tt.err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidDocument) {
+				t.Errorf("readZipFile() error = %v, want ErrInvalidDocument", err)
+			}
+		})
+	}
+}
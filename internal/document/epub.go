@@ -0,0 +1,293 @@
+package document
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// epubContainer META-INF/container.xml
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage OPF文件中的清单和阅读顺序
+type epubPackage struct {
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		Itemrefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// epubNCX EPUB2目录
+type epubNCX struct {
+	NavPoints []epubNavPoint `xml:"navMap>navPoint"`
+}
+
+// epubNavPoint EPUB2目录项,可嵌套
+type epubNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []epubNavPoint `xml:"navPoint"`
+}
+
+// extractEpub 按阅读顺序提取电子书各章节正文
+// 章节标题优先取目录中的标题,其次取正文中第一个标题
+func extractEpub(data []byte, maxDecompressed int64) ([]Chapter, error) {
+	archive, err := openZip(data, maxDecompressed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not an epub file: %v", ErrInvalidDocument, err)
+	}
+
+	var container epubContainer
+	if err := readXML(archive, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("%w: container.xml has no rootfile", ErrInvalidDocument)
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := readXML(archive, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	// 清单中的路径相对于OPF文件所在目录
+	baseDir := path.Dir(opfPath)
+	resolve := func(href string) string {
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		return path.Clean(path.Join(baseDir, href))
+	}
+
+	hrefs := make(map[string]string)
+	mediaTypes := make(map[string]string)
+	titles := make(map[string]string)
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = resolve(item.Href)
+		mediaTypes[item.ID] = item.MediaType
+	}
+	for _, item := range pkg.Manifest {
+		switch {
+		case item.ID == pkg.Spine.Toc || item.MediaType == "application/x-dtbncx+xml":
+			collectNCXTitles(archive, hrefs[item.ID], titles)
+		case strings.Contains(item.Properties, "nav"):
+			collectNavTitles(archive, hrefs[item.ID], titles)
+		}
+	}
+
+	var chapters []Chapter
+	for _, ref := range pkg.Spine.Itemrefs {
+		href, ok := hrefs[ref.IDRef]
+		if !ok || !strings.Contains(mediaTypes[ref.IDRef], "html") {
+			continue
+		}
+
+		content, err := readZipFile(archive, href)
+		if err != nil {
+			return nil, err
+		}
+		text, heading := htmlText(content)
+		text = normalizeText(text)
+		if text == "" {
+			continue
+		}
+
+		title := titles[href]
+		if title == "" {
+			title = heading
+		}
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", len(chapters)+1)
+		}
+
+		chapters = append(chapters, Chapter{
+			Index:  len(chapters) + 1,
+			Title:  title,
+			Length: utf8.RuneCountInString(text),
+			Text:   text,
+		})
+	}
+
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("%w: epub has no readable chapters", ErrInvalidDocument)
+	}
+	return chapters, nil
+}
+
+// readXML 读取并解析压缩包中的XML文件
+func readXML(archive *zipArchive, name string, v interface{}) error {
+	data, err := readZipFile(archive, name)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: failed to parse %s: %v", ErrInvalidDocument, name, err)
+	}
+	return nil
+}
+
+// collectNCXTitles 从EPUB2目录中收集各文件的章节标题,同一文件取第一个
+func collectNCXTitles(archive *zipArchive, ncxPath string, titles map[string]string) {
+	var ncx epubNCX
+	if err := readXML(archive, ncxPath, &ncx); err != nil {
+		return
+	}
+
+	var walk func(points []epubNavPoint)
+	walk = func(points []epubNavPoint) {
+		for _, point := range points {
+			addTitle(titles, path.Dir(ncxPath), point.Content.Src, point.Label)
+			walk(point.Children)
+		}
+	}
+	walk(ncx.NavPoints)
+}
+
+// collectNavTitles 从EPUB3导航文档中收集各文件的章节标题
+func collectNavTitles(archive *zipArchive, navPath string, titles map[string]string) {
+	data, err := readZipFile(archive, navPath)
+	if err != nil {
+		return
+	}
+
+	decoder := newHTMLDecoder(data)
+	href := ""
+	var label strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "a" {
+				href = attr(t, "href")
+				label.Reset()
+			}
+		case xml.CharData:
+			if href != "" {
+				label.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "a" && href != "" {
+				addTitle(titles, path.Dir(navPath), href, label.String())
+				href = ""
+			}
+		}
+	}
+}
+
+// addTitle 记录目录项对应文件的标题,忽略锚点
+func addTitle(titles map[string]string, baseDir, href, title string) {
+	href, _, _ = strings.Cut(href, "#")
+	title = strings.Join(strings.Fields(title), " ")
+	if href == "" || title == "" {
+		return
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	key := path.Clean(path.Join(baseDir, href))
+	if _, ok := titles[key]; !ok {
+		titles[key] = title
+	}
+}
+
+// htmlBlocks 结束时换行的块级元素
+var htmlBlocks = map[string]bool{
+	"p": true, "div": true, "li": true, "tr": true, "section": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// htmlText 将XHTML正文转为纯文本,同时返回第一个标题
+func htmlText(data []byte) (string, string) {
+	var b, heading strings.Builder
+	decoder := newHTMLDecoder(data)
+	skip := 0 // 位于 head/script/style 内部的层数
+	inHeading, headingDone := false, false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "script" || name == "style":
+				skip++
+			case name == "br":
+				b.WriteString("\n")
+			case isHeading(name) && !headingDone:
+				inHeading = true
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "script" || name == "style":
+				skip--
+			case htmlBlocks[name]:
+				b.WriteString("\n")
+			}
+			if inHeading && isHeading(name) {
+				inHeading, headingDone = false, true
+			}
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			b.Write(t)
+			if inHeading {
+				heading.Write(t)
+			}
+		}
+	}
+
+	return b.String(), strings.Join(strings.Fields(heading.String()), " ")
+}
+
+// isHeading 是否为 h1-h6 标题元素
+func isHeading(name string) bool {
+	return len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6'
+}
+
+// newHTMLDecoder 宽松模式的XHTML解析器,容忍HTML实体和未闭合标签
+func newHTMLDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+// attr 读取元素属性
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package document
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const epubContainerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+// xhtml 生成章节XHTML
+func xhtml(body string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><html xmlns="http://www.w3.org/1999/xhtml">` +
+		`<head><title>ignored</title><style>p { color: red; }</style></head><body>` + body + `</body></html>`
+}
+
+func TestExtractEpub(t *testing.T) {
+	// 清单顺序与阅读顺序不同,章节按spine排列;封面图片和空章节被跳过
+	opf := `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <manifest>
+    <item id="c3" href="Text/c3.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="c1" href="Text/c1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="Text/chapter%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover" href="Images/cover.jpg" media-type="image/jpeg"/>
+    <item id="blank" href="Text/blank.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c4" href="Text/c4.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine toc="ncx">
+    <itemref idref="cover"/>
+    <itemref idref="c1"/>
+    <itemref idref="blank"/>
+    <itemref idref="c2"/>
+    <itemref idref="missing"/>
+    <itemref idref="c3"/>
+    <itemref idref="c4"/>
+  </spine>
+</package>`
+
+	ncx := `<?xml version="1.0"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/"><navMap>
+  <navPoint><navLabel><text>第一卷</text></navLabel><content src="Text/c1.xhtml"/>
+    <navPoint><navLabel><text>第一章  雨夜</text></navLabel><content src="Text/c1.xhtml#start"/></navPoint>
+    <navPoint><navLabel><text>第二章</text></navLabel><content src="Text/chapter%202.xhtml"/></navPoint>
+  </navPoint>
+</navMap></ncx>`
+
+	nav := `<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+  <nav epub:type="toc"><ol>
+    <li><a href="Text/c1.xhtml#p1">序章</a></li>
+    <li><a href="Text/c2.xhtml"> 第二章 <span>风起</span></a></li>
+  </ol></nav>
+</body></html>`
+
+	opf3 := `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="c2" href="Text/c2.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="Text/c1.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="c1"/><itemref idref="c2"/></spine>
+</package>`
+
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		titles  []string
+		texts   []string
+	}{
+		{
+			name: "epub2 spine order with ncx titles",
+			entries: []zipEntry{
+				{"mimetype", "application/epub+zip"},
+				{"META-INF/container.xml", epubContainerXML},
+				{"OEBPS/content.opf", opf},
+				{"OEBPS/toc.ncx", ncx},
+				{"OEBPS/Text/c3.xhtml", xhtml(`<h2>第三章 <b>风</b></h2><p>风起了。</p>`)},
+				{"OEBPS/Text/c1.xhtml", xhtml(`<h1>雨</h1><p>他推开门。</p><p>雨很大。</p>`)},
+				{"OEBPS/Text/chapter 2.xhtml", xhtml(`<p>她笑了。<br/>然后走了。</p>`)},
+				{"OEBPS/Text/blank.xhtml", xhtml(`<div>  </div>`)},
+				{"OEBPS/Text/c4.xhtml", xhtml(`<p>尾声&nbsp;。</p>`)},
+			},
+			titles: []string{"第一卷", "第二章", "第三章 风", "Chapter 4"},
+			texts:  []string{"雨\n他推开门。\n雨很大。", "她笑了。\n然后走了。", "第三章 风\n风起了。", "尾声 。"},
+		},
+		{
+			name: "epub3 nav titles",
+			entries: []zipEntry{
+				{"META-INF/container.xml", epubContainerXML},
+				{"OEBPS/content.opf", opf3},
+				{"OEBPS/nav.xhtml", nav},
+				{"OEBPS/Text/c1.xhtml", xhtml(`<p>开始。</p>`)},
+				{"OEBPS/Text/c2.xhtml", xhtml(`<p>继续。</p>`)},
+			},
+			titles: []string{"序章", "第二章 风起"},
+			texts:  []string{"开始。", "继续。"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chapters, err := extractEpub(buildZip(t, tt.entries...), 1<<20)
+			if err != nil {
+				t.Fatalf("extractEpub() error = %v", err)
+			}
+
+			var titles, texts []string
+			for i, chapter := range chapters {
+				if chapter.Index != i+1 {
+					t.Errorf("chapter %d has index %d", i, chapter.Index)
+				}
+				if chapter.Length != len([]rune(chapter.Text)) {
+					t.Errorf("chapter %d length = %d, want %d", chapter.Index, chapter.Length, len([]rune(chapter.Text)))
+				}
+				titles = append(titles, chapter.Title)
+				texts = append(texts, chapter.Text)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("titles = %q, want %q", titles, tt.titles)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("texts = %q, want %q", texts, tt.texts)
+			}
+		})
+	}
+}
+
+func TestExtractEpubErrors(t *testing.T) {
+	chapter := xhtml(`<p>` + "正文" + `</p>`)
+	opf := `<package><manifest><item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/></manifest>` +
+		`<spine><itemref idref="c1"/></spine></package>`
+	container := `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`
+
+	// 解压总量恰好够用
+	budget := int64(len(container) + len(opf) + len(chapter))
+
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		budget  int64
+		wantErr bool
+	}{
+		{
+			name:    "fits budget",
+			entries: []zipEntry{{"META-INF/container.xml", container}, {"content.opf", opf}, {"c1.xhtml", chapter}},
+			budget:  budget,
+		},
+		{
+			name:    "exceeds budget across entries",
+			entries: []zipEntry{{"META-INF/container.xml", container}, {"content.opf", opf}, {"c1.xhtml", chapter}},
+			budget:  budget - 1,
+			wantErr: true,
+		},
+		{
+			name:    "missing container",
+			entries: []zipEntry{{"content.opf", opf}, {"c1.xhtml", chapter}},
+			budget:  1 << 20,
+			wantErr: true,
+		},
+		{
+			name:    "no rootfile",
+			entries: []zipEntry{{"META-INF/container.xml", `<container><rootfiles/></container>`}},
+			budget:  1 << 20,
+			wantErr: true,
+		},
+		{
+			name:    "no readable chapters",
+			entries: []zipEntry{{"META-INF/container.xml", container}, {"content.opf", opf}, {"c1.xhtml", xhtml("")}},
+			budget:  1 << 20,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := extractEpub(buildZip(t, tt.entries...), tt.budget)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("extractEpub() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidDocument) {
+				t.Errorf("extractEpub() error = %v, want ErrInvalidDocument", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// GetCharacters 获取任务的角色设定表
+func (h *VideoHandler) GetCharacters(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	if _, ok := h.taskManager.Get(taskID); !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	characters, err := h.characterService.Load(taskID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Character sheet not ready",
+			Error:     "task has no generated character sheet",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      characters,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateCharacters 替换任务的角色设定表
+// 修改在之后生成或重新生成的镜头中生效
+func (h *VideoHandler) UpdateCharacters(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	var characters model.CharacterSheet
+	if err := c.ShouldBindJSON(&characters); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid request",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	t, ok := h.taskManager.Get(taskID)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	// 执行中的任务可能正在读取设定表
+	if t.Status == model.TaskStatusProcessing {
+		c.JSON(http.StatusConflict, model.APIResponse{
+			Code:      409,
+			Message:   "Task is processing",
+			Error:     "character sheet cannot be edited while the task is processing",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if err := h.characterService.Save(taskID, &characters); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidCharacterSheet) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.APIResponse{
+			Code:      status,
+			Message:   "Failed to update character sheet",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	t.UpdatedAt = time.Now()
+	h.taskManager.Update(t)
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      characters,
+		Timestamp: time.Now(),
+	})
+}
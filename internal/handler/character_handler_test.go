@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetCharacters(t *testing.T) {
+	h := newTestHandler(t, nil)
+	withImageService(t, h)
+
+	h.taskManager.Create(model.NewTask("fresh", model.Input{Text: "x"}))
+	h.taskManager.Create(model.NewTask("ready", model.Input{Text: "x"}))
+	sheet := &model.CharacterSheet{Characters: []model.Character{{Name: "小明", Hair: "short black hair", Seed: 7}}}
+	if err := h.characterService.Save("ready", sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "unknown task", path: "/api/tasks/missing/characters", code: http.StatusNotFound},
+		{name: "sheet not generated", path: "/api/tasks/fresh/characters", code: http.StatusBadRequest},
+		{name: "generated sheet", path: "/api/tasks/ready/characters", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodGet, "/api/tasks/:task_id/characters", tt.path, nil, h.GetCharacters)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var got model.CharacterSheet
+			decodeData(t, resp, &got)
+			if len(got.Characters) != 1 || got.Characters[0].Hair != "short black hair" || got.Characters[0].Seed != 7 {
+				t.Errorf("characters = %+v", got)
+			}
+		})
+	}
+}
+
+func TestUpdateCharacters(t *testing.T) {
+	h := newTestHandler(t, nil)
+	withImageService(t, h)
+
+	done := model.NewTask("done", model.Input{Text: "x"})
+	done.Status = model.TaskStatusCompleted
+	h.taskManager.Create(done)
+	running := model.NewTask("running", model.Input{Text: "x"})
+	running.Status = model.TaskStatusProcessing
+	h.taskManager.Create(running)
+
+	// 参考图相对路径以任务项目目录为基准
+	refPath := filepath.Join(h.config.Storage.DataDir, "projects", "done", "ref.png")
+	if err := os.MkdirAll(filepath.Dir(refPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(refPath, []byte("ref"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := model.CharacterSheet{Characters: []model.Character{
+		{Name: "小明", Hair: "short black hair", Seed: 7, ReferenceImage: "ref.png"},
+	}}
+
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+		code int
+	}{
+		{name: "missing body", path: "/api/tasks/done/characters", code: http.StatusBadRequest},
+		{name: "unknown task", path: "/api/tasks/missing/characters", body: valid, code: http.StatusNotFound},
+		{name: "task processing", path: "/api/tasks/running/characters", body: valid, code: http.StatusConflict},
+		{
+			name: "empty name",
+			path: "/api/tasks/done/characters",
+			body: model.CharacterSheet{Characters: []model.Character{{Hair: "red"}}},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "duplicate name",
+			path: "/api/tasks/done/characters",
+			body: model.CharacterSheet{Characters: []model.Character{{Name: "小明"}, {Name: "小明"}}},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "negative seed",
+			path: "/api/tasks/done/characters",
+			body: model.CharacterSheet{Characters: []model.Character{{Name: "小明", Seed: -1}}},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "missing reference image",
+			path: "/api/tasks/done/characters",
+			body: model.CharacterSheet{Characters: []model.Character{{Name: "小明", ReferenceImage: "nope.png"}}},
+			code: http.StatusBadRequest,
+		},
+		{name: "valid sheet", path: "/api/tasks/done/characters", body: valid, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodPut, "/api/tasks/:task_id/characters", tt.path, tt.body, h.UpdateCharacters)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+		})
+	}
+
+	sheet, err := h.characterService.Load("done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Characters) != 1 || sheet.Characters[0].ReferenceImage != "ref.png" {
+		t.Errorf("saved sheet = %+v", sheet)
+	}
+}
+
+func TestRegenerateShotUsesCharacters(t *testing.T) {
+	h := newTestHandler(t, nil)
+	sd := withImageService(t, h)
+
+	shot := model.Shot{ID: 1, Type: model.ShotTypeMedium, Description: "门口", Duration: 3, Prompt: "at the door", Characters: []string{"小明"}}
+	createStoryboardTask(t, h, "done", model.TaskStatusCompleted, shot)
+
+	refPath := filepath.Join(h.config.Storage.DataDir, "projects", "done", "ref.png")
+	if err := os.WriteFile(refPath, []byte("ref"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sheet := &model.CharacterSheet{Characters: []model.Character{
+		{Name: "小明", Hair: "short black hair", Seed: 7, ReferenceImage: "ref.png"},
+	}}
+	if err := h.characterService.Save("done", sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body interface{}
+		seed int64
+	}{
+		{name: "character seed", seed: 7},
+		{name: "explicit seed wins", body: model.RegenerateShotRequest{Seed: 42}, seed: 42},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd.mu.Lock()
+			before := len(sd.requests)
+			sd.mu.Unlock()
+
+			recorder, resp := serve(t, http.MethodPost, "/api/tasks/:task_id/shots/:shot_id/regenerate", "/api/tasks/done/shots/1/regenerate", tt.body, h.RegenerateShot)
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d: %+v", recorder.Code, resp)
+			}
+
+			sd.mu.Lock()
+			req := sd.requests[before]
+			sd.mu.Unlock()
+
+			if !strings.Contains(req.Prompt, "小明 (short black hair)") {
+				t.Errorf("prompt %q lacks character description", req.Prompt)
+			}
+			if req.Seed != tt.seed {
+				t.Errorf("seed = %d, want %d", req.Seed, tt.seed)
+			}
+
+			controlnet, _ := req.AlwaysOnScripts["controlnet"].(map[string]interface{})
+			args, _ := controlnet["args"].([]interface{})
+			if len(args) != 1 {
+				t.Fatalf("controlnet args = %v", req.AlwaysOnScripts)
+			}
+			image, _ := args[0].(map[string]interface{})["image"].(string)
+			if image != base64.StdEncoding.EncodeToString([]byte("ref")) {
+				t.Errorf("reference image = %q", image)
+			}
+		})
+	}
+}
+
+func TestSubmitRejectsReferenceImage(t *testing.T) {
+	h := newTestHandler(t, nil)
+	withStoryboardService(t, h)
+	h.config.Limits.MaxTextLength = 1000
+	h.config.Series.EpisodeLength = 1000
+	h.config.Series.MaxEpisodes = 5
+	h.config.Series.MaxTextLength = 5000
+
+	withReference := []model.Character{{Name: "小明", Seed: 7, ReferenceImage: "ref.png"}}
+	preset := []model.Character{{Name: "小明", Hair: "short black hair", Seed: 7}}
+
+	tests := []struct {
+		name    string
+		route   string
+		handler func(h *VideoHandler) gin.HandlerFunc
+		body    interface{}
+		code    int
+	}{
+		{
+			name:    "generate with reference image",
+			route:   "/api/generate",
+			handler: func(h *VideoHandler) gin.HandlerFunc { return h.Generate },
+			body:    model.Input{Text: "他推开门。", Characters: withReference},
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:    "series with reference image",
+			route:   "/api/series",
+			handler: func(h *VideoHandler) gin.HandlerFunc { return h.CreateSeries },
+			body:    model.SeriesInput{Text: "他推开门。", Characters: withReference},
+			code:    http.StatusBadRequest,
+		},
+		{
+			name:    "generate with preset",
+			route:   "/api/generate",
+			handler: func(h *VideoHandler) gin.HandlerFunc { return h.Generate },
+			body:    model.Input{Text: "他推开门。", Characters: preset},
+			code:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodPost, tt.route, tt.route, tt.body, tt.handler(h))
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+			if tt.code != http.StatusOK {
+				if !strings.Contains(resp.Error, "PUT /api/tasks/:task_id/characters") {
+					t.Errorf("error = %q, want a hint to the characters endpoint", resp.Error)
+				}
+				return
+			}
+
+			var data struct {
+				TaskID string `json:"task_id"`
+			}
+			decodeData(t, resp, &data)
+			task, ok := h.taskManager.Get(data.TaskID)
+			if !ok || len(task.Input.Characters) != 1 || task.Input.Characters[0].Hair != "short black hair" {
+				t.Errorf("task characters = %+v", task)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/task"
+	"github.com/Jancd/1504/pkg/config"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "stdout", ""); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestHandler 创建只包含任务管理器和队列的处理器,队列中的任务由 process 执行
+func newTestHandler(t *testing.T, process task.Handler) *VideoHandler {
+	t.Helper()
+	if process == nil {
+		process = func(ctx context.Context, taskID string) {}
+	}
+
+	cfg := &config.Config{}
+	cfg.Storage.DataDir = t.TempDir()
+	cfg.Limits.MaxConcurrentTasks = 1
+
+	h := &VideoHandler{
+		taskManager: task.NewManager(task.NewMemoryStore()),
+		taskQueue:   task.NewQueue(1, process),
+		config:      cfg,
+	}
+	h.ctx, h.stop = context.WithCancel(context.Background())
+	h.streams, h.closeStreams = context.WithCancel(context.Background())
+	t.Cleanup(h.stop)
+	t.Cleanup(h.closeStreams)
+	return h
+}
+
+// serve 通过路由调用处理函数,body 非nil时编码为JSON请求体
+func serve(t *testing.T, method, route, path string, body interface{}, handler gin.HandlerFunc) (*httptest.ResponseRecorder, model.APIResponse) {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	router := gin.New()
+	router.Handle(method, route, handler)
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	var resp model.APIResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", recorder.Body.String(), err)
+	}
+	return recorder, resp
+}
+
+// decodeData 将响应中的 data 解码到 v
+func decodeData(t *testing.T, resp model.APIResponse, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("encode data: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode data %s: %v", data, err)
+	}
+}
+
+// sseEvent 解析出的SSE事件
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents 读取SSE流直到连接关闭
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimPrefix(line, "data:")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+// eventTask 解析任务事件中的任务快照
+func eventTask(t *testing.T, event sseEvent) *model.Task {
+	t.Helper()
+	var payload struct {
+		Data model.Task `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(event.data), &payload); err != nil {
+		t.Fatalf("decode event %q: %v", event.data, err)
+	}
+	return &payload.Data
+}
+
+func TestTaskEvents(t *testing.T) {
+	h := newTestHandler(t, nil)
+	router := gin.New()
+	router.GET("/api/tasks/:task_id/events", h.TaskEvents)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	finished := model.NewTask("finished", model.Input{Text: "x"})
+	finished.Status = model.TaskStatusCompleted
+	h.taskManager.Create(finished)
+	h.taskManager.Create(model.NewTask("live", model.Input{Text: "x"}))
+
+	tests := []struct {
+		name     string
+		taskID   string
+		update   func()
+		code     int
+		statuses []string
+	}{
+		{name: "unknown task", taskID: "missing", code: http.StatusNotFound},
+		{
+			name:     "finished task replays state and closes",
+			taskID:   "finished",
+			code:     http.StatusOK,
+			statuses: []string{model.TaskStatusCompleted},
+		},
+		{
+			name:   "live task streams until finished",
+			taskID: "live",
+			update: func() {
+				h.taskManager.UpdateTaskProgress("live", 50)
+				h.taskManager.SetTaskResult("live", &model.Result{VideoPath: "out.mp4"})
+			},
+			code:     http.StatusOK,
+			statuses: []string{model.TaskStatusQueued, model.TaskStatusQueued, model.TaskStatusCompleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/tasks/"+tt.taskID+"/events", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.code {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			// 收到响应头时订阅已经建立
+			if tt.update != nil {
+				go tt.update()
+			}
+
+			var statuses []string
+			for _, event := range readEvents(t, resp) {
+				if event.name == model.TaskEventTask {
+					statuses = append(statuses, eventTask(t, event).Status)
+				}
+			}
+			if ctx.Err() != nil {
+				t.Fatal("stream did not close after the task finished")
+			}
+			if strings.Join(statuses, ",") != strings.Join(tt.statuses, ",") {
+				t.Errorf("task events = %v, want %v", statuses, tt.statuses)
+			}
+		})
+	}
+}
+
+func TestTaskEventsClose(t *testing.T) {
+	interval := eventHeartbeatInterval
+	eventHeartbeatInterval = 20 * time.Millisecond
+	defer func() { eventHeartbeatInterval = interval }()
+
+	tests := []struct {
+		name  string
+		close func(h *VideoHandler)
+	}{
+		{name: "server shutdown", close: func(h *VideoHandler) { h.CloseStreams() }},
+		{
+			name: "task deleted without event",
+			close: func(h *VideoHandler) {
+				if err := h.taskManager.Delete("live"); err != nil {
+					t.Error(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, nil)
+			router := gin.New()
+			router.GET("/api/tasks/:task_id/events", h.TaskEvents)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			h.taskManager.Create(model.NewTask("live", model.Input{Text: "x"}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/tasks/live/events", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			go tt.close(h)
+			readEvents(t, resp)
+			if ctx.Err() != nil {
+				t.Fatal("stream did not close")
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/Jancd/1504/internal/llm"
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreatePlan 试运行: 只解析剧本和生成分镜,返回结果和正式生成的用量、耗时、费用估算
+// 请求体同 /api/generate;计划确认后可通过 PromotePlan 转为任务,不再重复调用LLM
+func (h *VideoHandler) CreatePlan(c *gin.Context) {
+	var req model.Input
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid request",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	req.Episode = nil
+	if resp := h.prepareInput(&req, h.config.Limits.MaxTextLength); resp != nil {
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	// 计划ID即转为任务后的任务ID,解析结果和分镜保存在该任务的项目目录
+	planID := uuid.New().String()
+	ctx, meter := llm.WithMeter(c.Request.Context())
+
+	logger.Info("Plan started",
+		zap.String("plan_id", planID),
+		zap.Int("text_length", len(req.Text)))
+
+	parsed, err := h.parserService.Parse(ctx, planID, req.Text, req.InputFormat, nil)
+	if err != nil {
+		h.discardPlan(planID)
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:      500,
+			Message:   "Failed to parse script",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	storyboard, err := h.storyboardService.Generate(ctx, planID, parsed, req.Options, nil)
+	if err != nil {
+		h.discardPlan(planID)
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:      500,
+			Message:   "Failed to generate storyboard",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if len(storyboard.Shots) > h.config.Limits.MaxShotsPerVideo {
+		h.discardPlan(planID)
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:    400,
+			Message: "Too many shots",
+			Error: fmt.Sprintf("too many shots generated (%d), maximum is %d",
+				len(storyboard.Shots), h.config.Limits.MaxShotsPerVideo),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	plan := &model.Plan{
+		ID:         planID,
+		Input:      req,
+		Parsed:     parsed,
+		Storyboard: storyboard,
+		CreatedAt:  time.Now(),
+	}
+	plan.Estimate = h.planService.Estimate(plan, meter.Usage())
+
+	if err := h.planService.Save(plan); err != nil {
+		h.discardPlan(planID)
+		logger.Error("Failed to persist plan", zap.String("plan_id", planID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:      500,
+			Message:   "Failed to save plan",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	logger.Info("Plan created",
+		zap.String("plan_id", planID),
+		zap.Int("shots", len(storyboard.Shots)),
+		zap.Int("plan_tokens", plan.Estimate.PlanTokens.PromptTokens+plan.Estimate.PlanTokens.CompletionTokens),
+		zap.Float64("task_cost", plan.Estimate.TaskCost))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      plan,
+		Timestamp: time.Now(),
+	})
+}
+
+// GetPlan 获取试运行计划
+func (h *VideoHandler) GetPlan(c *gin.Context) {
+	plan, ok := h.findPlan(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      plan,
+		Timestamp: time.Now(),
+	})
+}
+
+// PromotePlan 将试运行计划转为任务
+// 解析和分镜步骤直接标记为完成,任务从角色设定和图像生成继续;开启分镜审核时任务直接进入待审核状态
+func (h *VideoHandler) PromotePlan(c *gin.Context) {
+	plan, ok := h.findPlan(c)
+	if !ok {
+		return
+	}
+
+	if plan.TaskID != "" {
+		h.planPromoted(c, plan.ID)
+		return
+	}
+
+	t := model.NewTask(plan.ID, plan.Input)
+	t.UpdateStep(model.StepParseScript, model.StepStatusCompleted)
+	t.UpdateStep(model.StepGenerateStoryboard, model.StepStatusCompleted)
+
+	if plan.Input.Options.ReviewStoryboard {
+		t.Status = model.TaskStatusAwaitingReview
+	}
+
+	// 任务ID即计划ID,并发转换时只有一个能创建成功
+	if err := h.taskManager.CreateUnique(t); err != nil {
+		h.planPromoted(c, plan.ID)
+		return
+	}
+
+	// 入队后任务可能立即开始执行,状态在入队前读取
+	status := t.Status
+	data := gin.H{
+		"task_id": t.ID,
+		"status":  status,
+	}
+	if status == model.TaskStatusQueued {
+		position := h.taskQueue.Submit(t.ID)
+		data["queue_position"] = position
+		data["estimated_time"] = h.estimateTime(t.ID)
+	}
+
+	plan.TaskID = t.ID
+	if err := h.planService.Save(plan); err != nil {
+		logger.Warn("Failed to mark plan as promoted", zap.String("plan_id", plan.ID), zap.Error(err))
+	}
+
+	logger.Info("Plan promoted",
+		zap.String("plan_id", plan.ID),
+		zap.String("task_id", t.ID),
+		zap.String("status", status))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// DeletePlan 删除未转为任务的试运行计划及其解析结果和分镜
+// 已转为任务的计划与任务共用项目目录,需通过删除任务清理
+func (h *VideoHandler) DeletePlan(c *gin.Context) {
+	plan, ok := h.findPlan(c)
+	if !ok {
+		return
+	}
+
+	if _, exists := h.taskManager.Get(plan.ID); exists || plan.TaskID != "" {
+		h.planPromoted(c, plan.ID)
+		return
+	}
+
+	h.discardPlan(plan.ID)
+
+	logger.Info("Plan deleted", zap.String("plan_id", plan.ID))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "Plan deleted successfully",
+		Timestamp: time.Now(),
+	})
+}
+
+// planPromoted 返回计划已转为任务的错误响应
+func (h *VideoHandler) planPromoted(c *gin.Context, planID string) {
+	c.JSON(http.StatusConflict, model.APIResponse{
+		Code:      409,
+		Message:   "Plan already promoted",
+		Error:     fmt.Sprintf("plan %s has already been promoted to a task", planID),
+		Timestamp: time.Now(),
+	})
+}
+
+// findPlan 按路径参数加载计划,不存在时返回404
+func (h *VideoHandler) findPlan(c *gin.Context) (*model.Plan, bool) {
+	planID := c.Param("plan_id")
+
+	var plan *model.Plan
+	_, err := uuid.Parse(planID)
+	if err == nil {
+		plan, err = h.planService.Load(planID)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Plan not found",
+			Error:     fmt.Sprintf("plan %s does not exist", planID),
+			Timestamp: time.Now(),
+		})
+		return nil, false
+	}
+	return plan, true
+}
+
+// discardPlan 删除试运行失败时已保存的中间结果
+func (h *VideoHandler) discardPlan(planID string) {
+	projectDir := filepath.Join(h.config.Storage.DataDir, "projects", planID)
+	if !utils.FileExists(projectDir) {
+		return
+	}
+	if err := utils.RemoveDir(projectDir); err != nil {
+		logger.Warn("Failed to remove plan directory",
+			zap.String("plan_id", planID),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,414 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/screenplay"
+	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// recapLength 前情回顾引用上一集结尾的最大字数
+const recapLength = 300
+
+// CreateSeries 创建剧集
+// 长篇文本按章节和段落拆分为多集,每集作为独立任务依次排队,共享生成选项和角色设定
+// 预计耗时按各集依次执行估算,并发数大于1时各集的图像和渲染步骤会重叠执行
+func (h *VideoHandler) CreateSeries(c *gin.Context) {
+	var req model.SeriesInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid request",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	// Fountain按空行区分对白和动作,JSON是完整的剧本结构,都无法按段落拆分
+	if req.InputFormat == model.InputFormatFountain || req.InputFormat == model.InputFormatJSON {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid input format",
+			Error:     "series input_format must be one of: text, markdown",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	input := model.Input{Text: req.Text, InputFormat: req.InputFormat, Options: req.Options, Characters: req.Characters}
+	if resp := h.prepareInput(&input, h.config.Series.MaxTextLength); resp != nil {
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	req.InputFormat, req.Options = input.InputFormat, input.Options
+
+	// 未指定集数时按每集字数估算
+	count := req.Episodes
+	if count == 0 {
+		length := h.config.Series.EpisodeLength
+		count = min((utf8.RuneCountInString(req.Text)+length-1)/length, h.config.Series.MaxEpisodes)
+	}
+	if count <= 0 || count > h.config.Series.MaxEpisodes {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid episode count",
+			Error:     fmt.Sprintf("episodes must be between 1 and %d", h.config.Series.MaxEpisodes),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	texts := service.SplitEpisodes(req.Text, count)
+	if req.InputFormat == model.InputFormatMarkdown {
+		// 从场景中间开始的分集沿用上一集的场景标题
+		for i := 1; i < len(texts); i++ {
+			texts[i] = screenplay.ContinueMarkdown(texts[i-1], texts[i])
+		}
+	}
+
+	// 每集单独生成,单集同样受 limits.max_text_length 限制
+	for i, text := range texts {
+		if len(text) > h.config.Limits.MaxTextLength {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:      400,
+				Message:   "Episode too long",
+				Error:     fmt.Sprintf("episode %d text length exceeds maximum of %d characters, increase episodes", i+1, h.config.Limits.MaxTextLength),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	series := &model.Series{
+		ID:        uuid.New().String(),
+		Status:    model.TaskStatusQueued,
+		Input:     req,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	tasks := make([]*model.Task, 0, len(texts))
+	for i, text := range texts {
+		episode := &model.Episode{
+			SeriesID: series.ID,
+			Number:   i + 1,
+			Total:    len(texts),
+		}
+		if i > 0 {
+			episode.Recap = service.TailText(texts[i-1], recapLength)
+		}
+
+		t := model.NewTask(uuid.New().String(), model.Input{
+			Text:        text,
+			InputFormat: req.InputFormat,
+			Options:     req.Options,
+			Characters:  req.Characters,
+			Episode:     episode,
+		})
+		tasks = append(tasks, t)
+		series.Episodes = append(series.Episodes, model.SeriesEpisode{
+			Number:    episode.Number,
+			TaskID:    t.ID,
+			Status:    t.Status,
+			Submitted: i == 0,
+		})
+	}
+
+	// 先保存剧集再提交分集,分集执行时需要读取剧集共享的角色设定
+	if err := h.seriesManager.Create(series); err != nil {
+		logger.Error("Failed to persist series", zap.String("series_id", series.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:      500,
+			Message:   "Failed to create series",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	// 只提交第一集,之后每集在上一集生成角色设定后提交,保证新角色先写入剧集共享设定
+	for _, t := range tasks {
+		h.taskManager.Create(t)
+	}
+	h.taskQueue.Submit(tasks[0].ID)
+
+	logger.Info("Series created",
+		zap.String("series_id", series.ID),
+		zap.Int("episodes", len(tasks)),
+		zap.Int("text_length", len(req.Text)))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    0,
+		Message: "success",
+		Data: gin.H{
+			"series_id":      series.ID,
+			"status":         series.Status,
+			"episodes":       series.Episodes,
+			"estimated_time": h.estimateTime(tasks[0].ID) + int(h.taskManager.AverageDuration(defaultEstimatedTime))*(len(tasks)-1),
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// GetSeries 获取剧集状态,状态和进度由各集任务汇总
+func (h *VideoHandler) GetSeries(c *gin.Context) {
+	series, ok := h.findSeries(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      h.refreshSeries(series),
+		Timestamp: time.Now(),
+	})
+}
+
+// ListSeries 列出所有剧集
+func (h *VideoHandler) ListSeries(c *gin.Context) {
+	list := h.seriesManager.List()
+	for i, series := range list {
+		list[i] = h.refreshSeries(series)
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    0,
+		Message: "success",
+		Data: gin.H{
+			"series": list,
+			"total":  len(list),
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// CancelSeries 取消剧集中所有未结束的分集
+func (h *VideoHandler) CancelSeries(c *gin.Context) {
+	series, ok := h.findSeries(c)
+	if !ok {
+		return
+	}
+
+	cancelled := 0
+	for _, episode := range series.Episodes {
+		if _, err := h.cancelTask(episode.TaskID); err == nil {
+			cancelled++
+		}
+	}
+
+	if cancelled == 0 {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Series cannot be cancelled",
+			Error:     "series has no queued or processing episodes",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	logger.Info("Series cancel requested",
+		zap.String("series_id", series.ID),
+		zap.Int("episodes", cancelled))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "Series cancelled",
+		Data:      h.refreshSeries(series),
+		Timestamp: time.Now(),
+	})
+}
+
+// DeleteSeries 删除剧集及其所有分集任务
+func (h *VideoHandler) DeleteSeries(c *gin.Context) {
+	series, ok := h.findSeries(c)
+	if !ok {
+		return
+	}
+
+	// 先删除剧集记录,删除分集时不会再提交后续分集
+	if err := h.seriesManager.Delete(series.ID); err != nil {
+		logger.Error("Failed to delete series",
+			zap.String("series_id", series.ID),
+			zap.Error(err))
+	}
+
+	for _, episode := range series.Episodes {
+		if _, ok := h.taskManager.Get(episode.TaskID); ok {
+			h.removeTask(episode.TaskID)
+		}
+	}
+
+	// 删除合并视频
+	seriesDir := filepath.Join(h.config.Storage.DataDir, "series", series.ID)
+	if utils.FileExists(seriesDir) {
+		if err := utils.RemoveDir(seriesDir); err != nil {
+			logger.Warn("Failed to remove series directory",
+				zap.String("series_id", series.ID),
+				zap.Error(err))
+		}
+	}
+
+	logger.Info("Series deleted", zap.String("series_id", series.ID))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "Series deleted successfully",
+		Timestamp: time.Now(),
+	})
+}
+
+// DownloadSeries 下载各集按顺序拼接的完整视频
+func (h *VideoHandler) DownloadSeries(c *gin.Context) {
+	series, ok := h.findSeries(c)
+	if !ok {
+		return
+	}
+
+	series = h.refreshSeries(series)
+	if series.Status != model.TaskStatusCompleted {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Series not ready",
+			Error:     fmt.Sprintf("series status is %s", series.Status),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	episodes := make([]*model.Result, 0, len(series.Episodes))
+	for _, episode := range series.Episodes {
+		t, ok := h.taskManager.Get(episode.TaskID)
+		if !ok || t.Result == nil || !utils.FileExists(t.Result.VideoPath) {
+			c.JSON(http.StatusNotFound, model.APIResponse{
+				Code:      404,
+				Message:   "Video file not found",
+				Error:     fmt.Sprintf("video of episode %d has been deleted or moved", episode.Number),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		episodes = append(episodes, t.Result)
+	}
+
+	result, err := h.renderService.ConcatEpisodes(c.Request.Context(), series.ID, episodes)
+	if err != nil {
+		logger.Error("Failed to concatenate series video",
+			zap.String("series_id", series.ID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:      500,
+			Message:   "Failed to build series video",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if err := h.seriesManager.SetResult(series.ID, result); err != nil {
+		logger.Warn("Failed to persist series result",
+			zap.String("series_id", series.ID),
+			zap.Error(err))
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=series_%s.mp4", series.ID))
+	c.Header("Content-Type", "video/mp4")
+
+	logger.Info("Series video downloaded",
+		zap.String("series_id", series.ID),
+		zap.String("file_path", result.VideoPath))
+
+	c.File(result.VideoPath)
+}
+
+// findSeries 按路径参数查找剧集,不存在时返回404
+func (h *VideoHandler) findSeries(c *gin.Context) (*model.Series, bool) {
+	seriesID := c.Param("series_id")
+
+	series, ok := h.seriesManager.Get(seriesID)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Series not found",
+			Error:     fmt.Sprintf("series %s does not exist", seriesID),
+			Timestamp: time.Now(),
+		})
+		return nil, false
+	}
+	return series, true
+}
+
+// refreshSeries 按各集任务的最新状态汇总剧集状态,返回汇总后的副本
+// 剧集已被删除时返回传入的副本
+func (h *VideoHandler) refreshSeries(series *model.Series) *model.Series {
+	refreshed, err := h.seriesManager.Refresh(series.ID, h.taskManager.Get)
+	if err != nil {
+		logger.Warn("Failed to refresh series status",
+			zap.String("series_id", series.ID),
+			zap.Error(err))
+	}
+	if refreshed == nil {
+		return series
+	}
+	return refreshed
+}
+
+// submitNextEpisode 提交分集的下一集
+// 在分集生成角色设定后调用,分集在此之前结束(失败、取消、删除)时也需要调用,避免后续分集一直不被提交;
+// 已取消或删除的下一集跳过,继续提交其后的分集
+func (h *VideoHandler) submitNextEpisode(episode *model.Episode) {
+	if episode == nil {
+		return
+	}
+
+	for number := episode.Number; ; number++ {
+		taskID, ok := h.seriesManager.NextEpisode(episode.SeriesID, number)
+		if !ok {
+			return
+		}
+		if t, ok := h.taskManager.Get(taskID); ok && t.Status == model.TaskStatusQueued {
+			position := h.taskQueue.Submit(taskID)
+			logger.Info("Next episode queued",
+				zap.String("series_id", episode.SeriesID),
+				zap.Int("episode", number+1),
+				zap.String("task_id", taskID),
+				zap.Int("queue_position", position))
+			return
+		}
+	}
+}
+
+// characterPresets 生成角色设定时使用的预设角色
+// 剧集分集先沿用剧集共享设定中本集出场的角色,再由请求中的预设覆盖
+func (h *VideoHandler) characterPresets(t *model.Task, parsed *model.ParsedScript) []model.Character {
+	if t.Input.Episode == nil {
+		return t.Input.Characters
+	}
+
+	appearing := make(map[string]bool)
+	for _, name := range parsed.Characters {
+		appearing[name] = true
+	}
+
+	var presets []model.Character
+	for _, c := range h.seriesManager.Characters(t.Input.Episode.SeriesID) {
+		if appearing[c.Name] {
+			presets = append(presets, c)
+		}
+	}
+	return append(presets, t.Input.Characters...)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/task"
+)
+
+func TestCreateSeriesTextLimits(t *testing.T) {
+	h := newTestHandler(t, nil)
+	withStoryboardService(t, h)
+	seriesManager, err := task.NewSeriesManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.seriesManager = seriesManager
+	h.config.Limits.MaxTextLength = 20
+	h.config.Series.MaxTextLength = 60
+	h.config.Series.EpisodeLength = 1000
+	h.config.Series.MaxEpisodes = 5
+
+	paragraph := strings.Repeat("a", 15)
+	text := strings.Join([]string{paragraph, paragraph, paragraph}, "\n\n")
+
+	tests := []struct {
+		name     string
+		text     string
+		episodes int
+		code     int
+		message  string
+	}{
+		{name: "series text over series limit", text: strings.Repeat("a", 61), episodes: 5, code: http.StatusBadRequest, message: "Text too long"},
+		{name: "episode over per-episode limit", text: text, episodes: 1, code: http.StatusBadRequest, message: "Episode too long"},
+		{name: "series longer than a single task", text: text, episodes: 3, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := model.SeriesInput{Text: tt.text, Episodes: tt.episodes}
+			recorder, resp := serve(t, http.MethodPost, "/api/series", "/api/series", body, h.CreateSeries)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+			if tt.message != "" && resp.Message != tt.message {
+				t.Errorf("message = %q, want %q", resp.Message, tt.message)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var data struct {
+				Episodes []model.SeriesEpisode `json:"episodes"`
+			}
+			decodeData(t, resp, &data)
+			if len(data.Episodes) != tt.episodes {
+				t.Fatalf("episodes = %d, want %d", len(data.Episodes), tt.episodes)
+			}
+			for _, episode := range data.Episodes {
+				task, _ := h.taskManager.Get(episode.TaskID)
+				if len(task.Input.Text) > h.config.Limits.MaxTextLength {
+					t.Errorf("episode %d text length = %d, over limit", episode.Number, len(task.Input.Text))
+				}
+			}
+		})
+	}
+}
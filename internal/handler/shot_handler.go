@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RegenerateShot 重新生成单个镜头图像,可选重新渲染视频
+func (h *VideoHandler) RegenerateShot(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	shotID, err := strconv.Atoi(c.Param("shot_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid shot ID",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req model.RegenerateShotRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:      400,
+				Message:   "Invalid request",
+				Error:     err.Error(),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	t, ok := h.taskManager.Get(taskID)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if h.useQiniuMode {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Shot regeneration not supported",
+			Error:     "shot images are only generated in local_sd and hybrid modes",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if t.Status == model.TaskStatusQueued || t.Status == model.TaskStatusProcessing {
+		c.JSON(http.StatusConflict, model.APIResponse{
+			Code:      409,
+			Message:   "Task is still running",
+			Error:     fmt.Sprintf("task status is %s", t.Status),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	// 重新渲染会重新执行图像和渲染步骤,只允许已完成的任务,避免绕过分镜审核或把未完成的任务标记为完成
+	if req.Rerender && t.Status != model.TaskStatusCompleted {
+		c.JSON(http.StatusConflict, model.APIResponse{
+			Code:      409,
+			Message:   "Task cannot be rerendered",
+			Error:     fmt.Sprintf("only completed tasks can be rerendered, task status is %s", t.Status),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if !t.IsStepCompleted(model.StepGenerateStoryboard) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Storyboard not ready",
+			Error:     "task has no generated storyboard",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	shot, err := h.imageService.RegenerateShot(ctx, taskID, shotID, t.Input.Options, req.Prompt, req.Seed)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrShotNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, model.APIResponse{
+			Code:      status,
+			Message:   "Failed to regenerate shot",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	h.taskManager.Publish(taskID, model.TaskEventShot, model.StepGenerateImages, model.ShotEvent{
+		ShotID:    shot.ID,
+		ImagePath: shot.ImagePath,
+	})
+
+	data := gin.H{
+		"shot": shot,
+	}
+
+	// 使用更新后的分镜重新渲染视频: 作为排队任务重新执行图像和渲染步骤,
+	// 已生成的图像和片段直接复用,受并发数限制且可通过取消接口取消
+	if req.Rerender {
+		_, err := h.taskManager.Transition(taskID, []string{model.TaskStatusCompleted}, func(t *model.Task) error {
+			if h.taskQueue.Done(taskID) != nil {
+				return errTaskStillRunning
+			}
+
+			t.ResetSteps(model.StepGenerateImages, model.StepRenderVideo)
+			t.Status = model.TaskStatusQueued
+			t.Error = ""
+			t.Result = nil
+			return nil
+		})
+		if err != nil {
+			h.transitionFailed(c, taskID, "Task cannot be rerendered", err)
+			return
+		}
+
+		position := h.taskQueue.Submit(taskID)
+		data["status"] = model.TaskStatusQueued
+		data["queue_position"] = position
+		data["estimated_time"] = h.estimateTime(taskID)
+	}
+
+	logger.Info("Shot regenerated",
+		zap.String("task_id", taskID),
+		zap.Int("shot_id", shotID),
+		zap.Bool("rerender", req.Rerender))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/client"
+	"github.com/Jancd/1504/internal/imagegen"
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/pkg/utils"
+)
+
+// fakeSD 模拟Stable Diffusion文生图接口,记录收到的请求
+type fakeSD struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []client.Txt2ImgRequest
+}
+
+// newFakeSD 启动模拟SD服务
+func newFakeSD(t *testing.T) *fakeSD {
+	t.Helper()
+	sd := &fakeSD{}
+	sd.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req client.Txt2ImgRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sd.mu.Lock()
+		sd.requests = append(sd.requests, req)
+		sd.mu.Unlock()
+
+		json.NewEncoder(w).Encode(client.Txt2ImgResponse{
+			Images: []string{base64.StdEncoding.EncodeToString([]byte("png"))},
+		})
+	}))
+	t.Cleanup(sd.Close)
+	return sd
+}
+
+// withImageService 为处理器配置连接模拟SD服务的图像、分镜和角色服务
+func withImageService(t *testing.T, h *VideoHandler) *fakeSD {
+	t.Helper()
+	sd := newFakeSD(t)
+	dataDir := h.config.Storage.DataDir
+	withStoryboardService(t, h)
+	h.characterService = service.NewCharacterService(nil, dataDir, 0)
+	h.imageService = service.NewImageService(imagegen.NewA1111Generator(sd.URL, 5), h.storyboardService, h.characterService, h.styles, dataDir, 64, 64, 0)
+	return sd
+}
+
+// createStoryboardTask 创建已生成分镜的任务
+func createStoryboardTask(t *testing.T, h *VideoHandler, taskID, status string, shots ...model.Shot) *model.Task {
+	t.Helper()
+	task := model.NewTask(taskID, model.Input{Text: "x"})
+	task.Status = status
+	task.UpdateStep(model.StepParseScript, model.StepStatusCompleted)
+	task.UpdateStep(model.StepGenerateStoryboard, model.StepStatusCompleted)
+	h.taskManager.Create(task)
+
+	path := filepath.Join(h.config.Storage.DataDir, "projects", taskID, "storyboard.json")
+	if err := utils.EnsureDir(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SaveJSON(path, &model.Storyboard{Shots: shots}); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestRegenerateShot(t *testing.T) {
+	h := newTestHandler(t, nil)
+	sd := withImageService(t, h)
+
+	shot := model.Shot{ID: 1, Type: "medium", Description: "门口", Duration: 3, Prompt: "old prompt"}
+	createStoryboardTask(t, h, "done", model.TaskStatusCompleted, shot)
+	createStoryboardTask(t, h, "running", model.TaskStatusProcessing, shot)
+	fresh := model.NewTask("fresh", model.Input{Text: "x"})
+	fresh.Status = model.TaskStatusFailed
+	h.taskManager.Create(fresh)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   interface{}
+		code   int
+		prompt string
+		seed   int64
+	}{
+		{name: "invalid shot id", path: "/api/tasks/done/shots/abc/regenerate", code: http.StatusBadRequest},
+		{name: "unknown task", path: "/api/tasks/missing/shots/1/regenerate", code: http.StatusNotFound},
+		{name: "task still running", path: "/api/tasks/running/shots/1/regenerate", code: http.StatusConflict},
+		{name: "no storyboard", path: "/api/tasks/fresh/shots/1/regenerate", code: http.StatusBadRequest},
+		{name: "unknown shot", path: "/api/tasks/done/shots/9/regenerate", code: http.StatusNotFound},
+		{
+			name:   "custom prompt and seed",
+			path:   "/api/tasks/done/shots/1/regenerate",
+			body:   model.RegenerateShotRequest{Prompt: "new prompt", Seed: 42},
+			code:   http.StatusOK,
+			prompt: "new prompt",
+			seed:   42,
+		},
+		{
+			name:   "prompt and seed are kept",
+			path:   "/api/tasks/done/shots/1/regenerate",
+			code:   http.StatusOK,
+			prompt: "new prompt",
+			seed:   42,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sd.mu.Lock()
+			before := len(sd.requests)
+			sd.mu.Unlock()
+
+			recorder, resp := serve(t, http.MethodPost, "/api/tasks/:task_id/shots/:shot_id/regenerate", tt.path, tt.body, h.RegenerateShot)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+
+			sd.mu.Lock()
+			requests := sd.requests[before:]
+			sd.mu.Unlock()
+			if tt.code != http.StatusOK {
+				if len(requests) != 0 {
+					t.Errorf("SD called %d times on error", len(requests))
+				}
+				return
+			}
+
+			if len(requests) != 1 {
+				t.Fatalf("SD called %d times, want 1", len(requests))
+			}
+			if requests[0].Prompt != tt.prompt || requests[0].Seed != tt.seed {
+				t.Errorf("SD request prompt=%q seed=%d, want %q %d", requests[0].Prompt, requests[0].Seed, tt.prompt, tt.seed)
+			}
+
+			var data struct {
+				Shot model.Shot `json:"shot"`
+			}
+			decodeData(t, resp, &data)
+			if data.Shot.Prompt != tt.prompt {
+				t.Errorf("shot prompt = %q, want %q", data.Shot.Prompt, tt.prompt)
+			}
+			if image, err := os.ReadFile(data.Shot.ImagePath); err != nil || string(image) != "png" {
+				t.Errorf("image file = %q, %v", image, err)
+			}
+
+			storyboard, err := h.storyboardService.Load("done")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if storyboard.Shots[0].Prompt != tt.prompt || storyboard.Shots[0].ImagePath != data.Shot.ImagePath {
+				t.Errorf("storyboard not updated: %+v", storyboard.Shots[0])
+			}
+		})
+	}
+}
+
+func TestRegenerateShotRerender(t *testing.T) {
+	submitted := make(chan string, 1)
+	h := newTestHandler(t, func(ctx context.Context, taskID string) { submitted <- taskID })
+	withImageService(t, h)
+
+	shot := model.Shot{ID: 1, Type: "medium", Description: "门口", Duration: 3, Prompt: "at the door"}
+	done := createStoryboardTask(t, h, "done", model.TaskStatusCompleted, shot)
+	done.UpdateStep(model.StepGenerateImages, model.StepStatusCompleted)
+	done.UpdateStep(model.StepRenderVideo, model.StepStatusCompleted)
+	done.Result = &model.Result{VideoPath: "out.mp4"}
+	createStoryboardTask(t, h, "failed", model.TaskStatusFailed, shot)
+
+	body := model.RegenerateShotRequest{Rerender: true}
+
+	// 未完成的任务不能重新渲染
+	recorder, resp := serve(t, http.MethodPost, "/api/tasks/:task_id/shots/:shot_id/regenerate", "/api/tasks/failed/shots/1/regenerate", body, h.RegenerateShot)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("failed task: status = %d, want 409: %+v", recorder.Code, resp)
+	}
+
+	// 已完成的任务重新排队执行图像和渲染步骤
+	recorder, resp = serve(t, http.MethodPost, "/api/tasks/:task_id/shots/:shot_id/regenerate", "/api/tasks/done/shots/1/regenerate", body, h.RegenerateShot)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("completed task: status = %d, want 200: %+v", recorder.Code, resp)
+	}
+	var data struct {
+		Status string `json:"status"`
+	}
+	decodeData(t, resp, &data)
+	if data.Status != model.TaskStatusQueued {
+		t.Errorf("response status = %q, want queued", data.Status)
+	}
+
+	select {
+	case id := <-submitted:
+		if id != "done" {
+			t.Errorf("submitted task %s, want done", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rerender was not queued")
+	}
+
+	task, _ := h.taskManager.Get("done")
+	if task.Result != nil {
+		t.Errorf("result = %+v, want cleared", task.Result)
+	}
+	// 只重置图像和渲染步骤,之前的步骤保持完成
+	steps := map[string]string{
+		model.StepParseScript:        model.StepStatusCompleted,
+		model.StepGenerateStoryboard: model.StepStatusCompleted,
+		model.StepGenerateImages:     model.StepStatusPending,
+		model.StepRenderVideo:        model.StepStatusPending,
+	}
+	for _, step := range task.Steps {
+		if want, ok := steps[step.Name]; ok && step.Status != want {
+			t.Errorf("step %s = %s, want %s", step.Name, step.Status, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/internal/task"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetStoryboard 获取任务的分镜脚本
+func (h *VideoHandler) GetStoryboard(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	t, ok := h.taskManager.Get(taskID)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if !t.IsStepCompleted(model.StepGenerateStoryboard) {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Storyboard not ready",
+			Error:     "task has no generated storyboard",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	storyboard, err := h.storyboardService.Load(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.APIResponse{
+			Code:      500,
+			Message:   "Failed to load storyboard",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      storyboard,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateStoryboard 替换等待审核的分镜脚本
+// 请求体为完整的分镜脚本,可修改、增删和重新排序镜头
+func (h *VideoHandler) UpdateStoryboard(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	var storyboard model.Storyboard
+	if err := c.ShouldBindJSON(&storyboard); err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid request",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	t, ok := h.taskManager.Get(taskID)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if t.Status != model.TaskStatusAwaitingReview {
+		c.JSON(http.StatusConflict, model.APIResponse{
+			Code:      409,
+			Message:   "Storyboard is not awaiting review",
+			Error:     fmt.Sprintf("task status is %s", t.Status),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if err := h.storyboardService.Save(taskID, &storyboard, t.Input.Options, h.config.Limits.MaxShotsPerVideo); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidStoryboard) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.APIResponse{
+			Code:      status,
+			Message:   "Failed to update storyboard",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	t.UpdatedAt = time.Now()
+	h.taskManager.Update(t)
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      storyboard,
+		Timestamp: time.Now(),
+	})
+}
+
+// ApproveStoryboard 确认分镜,继续生成图像和渲染视频
+func (h *VideoHandler) ApproveStoryboard(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	// 状态检查和转换在任务管理器的锁内完成,重复确认只有一次能让任务入队
+	_, err := h.taskManager.Transition(taskID, []string{model.TaskStatusAwaitingReview}, func(t *model.Task) error {
+		if h.taskQueue.Done(taskID) != nil {
+			return errTaskStillRunning
+		}
+
+		// 分镜步骤已完成,重新入队后从图像生成继续
+		t.Status = model.TaskStatusQueued
+		return nil
+	})
+	if errors.Is(err, task.ErrStatusConflict) {
+		c.JSON(http.StatusConflict, model.APIResponse{
+			Code:      409,
+			Message:   "Storyboard is not awaiting review",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err != nil {
+		h.transitionFailed(c, taskID, "Storyboard cannot be approved", err)
+		return
+	}
+
+	position := h.taskQueue.Submit(taskID)
+
+	logger.Info("Storyboard approved",
+		zap.String("task_id", taskID),
+		zap.Int("queue_position", position))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    0,
+		Message: "success",
+		Data: gin.H{
+			"task_id":        taskID,
+			"status":         model.TaskStatusQueued,
+			"queue_position": position,
+			"estimated_time": h.estimateTime(taskID),
+		},
+		Timestamp: time.Now(),
+	})
+}
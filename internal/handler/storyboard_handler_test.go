@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/internal/style"
+)
+
+// withStoryboardService 为处理器配置不连接LLM、只有内置风格的分镜服务
+func withStoryboardService(t *testing.T, h *VideoHandler) {
+	t.Helper()
+	styles, err := style.NewRegistry(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.styles = styles
+	h.storyboardService = service.NewStoryboardService(nil, styles, service.NewPacer(4, 2), h.config.Storage.DataDir)
+}
+
+func TestGetStoryboard(t *testing.T) {
+	h := newTestHandler(t, nil)
+	withStoryboardService(t, h)
+
+	shot := model.Shot{ID: 1, Type: model.ShotTypeMedium, Description: "门口", Duration: 3}
+	createStoryboardTask(t, h, "ready", model.TaskStatusAwaitingReview, shot)
+	h.taskManager.Create(model.NewTask("fresh", model.Input{Text: "x"}))
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "unknown task", path: "/api/tasks/missing/storyboard", code: http.StatusNotFound},
+		{name: "storyboard not generated", path: "/api/tasks/fresh/storyboard", code: http.StatusBadRequest},
+		{name: "generated storyboard", path: "/api/tasks/ready/storyboard", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodGet, "/api/tasks/:task_id/storyboard", tt.path, nil, h.GetStoryboard)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var storyboard model.Storyboard
+			decodeData(t, resp, &storyboard)
+			if len(storyboard.Shots) != 1 || storyboard.Shots[0].Description != shot.Description {
+				t.Errorf("storyboard = %+v", storyboard)
+			}
+		})
+	}
+}
+
+func TestUpdateStoryboard(t *testing.T) {
+	h := newTestHandler(t, nil)
+	withStoryboardService(t, h)
+	h.config.Limits.MaxShotsPerVideo = 2
+
+	shot := model.Shot{ID: 1, Type: model.ShotTypeMedium, Description: "门口", Duration: 3}
+	stored := shot
+	stored.ImagePath = "images/shot_1.png"
+	createStoryboardTask(t, h, "review", model.TaskStatusAwaitingReview, stored)
+	createStoryboardTask(t, h, "done", model.TaskStatusCompleted, shot)
+
+	// 客户端提交的图像和片段路径会被忽略
+	edited := model.Storyboard{Shots: []model.Shot{
+		{ID: 1, Type: model.ShotTypeCloseup, Description: "特写", Duration: 2, Prompt: "close up", ImagePath: "/etc/passwd"},
+		{Type: model.ShotTypeLong, Description: "远景", Duration: 4, VideoPath: "/etc/shadow"},
+	}}
+
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+		code int
+	}{
+		{name: "missing body", path: "/api/tasks/review/storyboard", code: http.StatusBadRequest},
+		{name: "unknown task", path: "/api/tasks/missing/storyboard", body: edited, code: http.StatusNotFound},
+		{name: "not awaiting review", path: "/api/tasks/done/storyboard", body: edited, code: http.StatusConflict},
+		{name: "no shots", path: "/api/tasks/review/storyboard", body: model.Storyboard{}, code: http.StatusBadRequest},
+		{
+			name: "too many shots",
+			path: "/api/tasks/review/storyboard",
+			body: model.Storyboard{Shots: []model.Shot{shot, shot, shot}},
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "invalid shot type",
+			path: "/api/tasks/review/storyboard",
+			body: model.Storyboard{Shots: []model.Shot{{ID: 1, Type: "aerial", Description: "x", Duration: 1}}},
+			code: http.StatusBadRequest,
+		},
+		{name: "edited storyboard", path: "/api/tasks/review/storyboard", body: edited, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodPut, "/api/tasks/:task_id/storyboard", tt.path, tt.body, h.UpdateStoryboard)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+		})
+	}
+
+	// 保存时补齐镜头ID、转场、Prompt和总时长
+	storyboard, err := h.storyboardService.Load("review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storyboard.Shots) != 2 {
+		t.Fatalf("shots = %d, want 2", len(storyboard.Shots))
+	}
+	added := storyboard.Shots[1]
+	if added.ID != 2 || added.Transition != model.TransitionCut || added.Prompt == "" {
+		t.Errorf("added shot not normalized: %+v", added)
+	}
+	if storyboard.Shots[0].Prompt != "close up" {
+		t.Errorf("prompt = %q, want edited prompt kept", storyboard.Shots[0].Prompt)
+	}
+	if storyboard.Shots[0].ImagePath != stored.ImagePath || added.VideoPath != "" {
+		t.Errorf("media paths = %q, %q, want stored paths only", storyboard.Shots[0].ImagePath, added.VideoPath)
+	}
+	if storyboard.TotalDuration != 6 {
+		t.Errorf("total duration = %v, want 6", storyboard.TotalDuration)
+	}
+}
+
+func TestApproveStoryboard(t *testing.T) {
+	submitted := make(chan string, 1)
+	h := newTestHandler(t, func(ctx context.Context, taskID string) {
+		submitted <- taskID
+	})
+	withStoryboardService(t, h)
+
+	shot := model.Shot{ID: 1, Type: model.ShotTypeMedium, Description: "门口", Duration: 3}
+	createStoryboardTask(t, h, "review", model.TaskStatusAwaitingReview, shot)
+	createStoryboardTask(t, h, "done", model.TaskStatusCompleted, shot)
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "unknown task", path: "/api/tasks/missing/approve", code: http.StatusNotFound},
+		{name: "not awaiting review", path: "/api/tasks/done/approve", code: http.StatusConflict},
+		{name: "approve", path: "/api/tasks/review/approve", code: http.StatusOK},
+		{name: "approve twice", path: "/api/tasks/review/approve", code: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodPost, "/api/tasks/:task_id/approve", tt.path, nil, h.ApproveStoryboard)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+		})
+	}
+
+	select {
+	case taskID := <-submitted:
+		if taskID != "review" {
+			t.Errorf("submitted %q, want review", taskID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("approved task was not submitted")
+	}
+	select {
+	case taskID := <-submitted:
+		t.Errorf("unexpected submission of %q", taskID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestApproveStoryboardConcurrent(t *testing.T) {
+	submitted := make(chan string, 8)
+	h := newTestHandler(t, func(ctx context.Context, taskID string) {
+		submitted <- taskID
+	})
+	withStoryboardService(t, h)
+
+	shot := model.Shot{ID: 1, Type: model.ShotTypeMedium, Description: "门口", Duration: 3}
+	createStoryboardTask(t, h, "review", model.TaskStatusAwaitingReview, shot)
+
+	// 同时确认多次只有一次能让任务入队
+	const attempts = 8
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder, _ := serve(t, http.MethodPost, "/api/tasks/:task_id/approve", "/api/tasks/review/approve", nil, h.ApproveStoryboard)
+			codes <- recorder.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	approved := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			approved++
+		case http.StatusConflict:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if approved != 1 {
+		t.Errorf("%d approvals succeeded, want 1", approved)
+	}
+
+	<-submitted
+	select {
+	case taskID := <-submitted:
+		t.Errorf("task %q submitted twice", taskID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ListStyles 列出可用的风格预设
+func (h *VideoHandler) ListStyles(c *gin.Context) {
+	styles := h.styles.List()
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    0,
+		Message: "success",
+		Data: gin.H{
+			"styles": styles,
+			"total":  len(styles),
+		},
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// eventHeartbeatInterval SSE心跳间隔,防止代理断开空闲连接
+var eventHeartbeatInterval = 15 * time.Second
+
+// TaskEvents 以Server-Sent Events推送任务进度
+// 连接建立后先推送一次当前状态,任务结束、被删除或服务关闭时关闭连接
+func (h *VideoHandler) TaskEvents(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	// 先订阅再读取当前状态,避免遗漏两者之间的事件
+	events, unsubscribe := h.taskManager.Events().Subscribe(taskID)
+	defer unsubscribe()
+
+	t, ok := h.taskManager.Get(taskID)
+	if !ok {
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	logger.Info("Task event stream opened", zap.String("task_id", taskID))
+
+	// 回放当前状态
+	snapshot := h.taskSnapshot(t)
+	c.SSEvent(model.TaskEventTask, &model.TaskEvent{
+		Type:      model.TaskEventTask,
+		TaskID:    taskID,
+		Data:      snapshot,
+		Timestamp: time.Now(),
+	})
+	c.Writer.Flush()
+	if snapshot.IsFinished() {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-h.streams.Done():
+			return false
+		case <-heartbeat.C:
+			// 兜底确认任务是否已结束或被删除,避免错过结束事件时连接一直不关闭
+			current, ok := h.taskManager.Get(taskID)
+			if !ok {
+				return false
+			}
+			if snapshot := h.taskSnapshot(current); snapshot.IsFinished() {
+				c.SSEvent(model.TaskEventTask, &model.TaskEvent{
+					Type:      model.TaskEventTask,
+					TaskID:    taskID,
+					Data:      snapshot,
+					Timestamp: time.Now(),
+				})
+				return false
+			}
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+
+			// 任务结束后关闭连接
+			if snapshot, ok := event.Data.(*model.Task); ok && snapshot.IsFinished() {
+				return false
+			}
+			return true
+		}
+	})
+
+	logger.Info("Task event stream closed", zap.String("task_id", taskID))
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jancd/1504/internal/document"
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// multipartOverhead 表单中除文件外其他字段和分隔符的预留字节数
+const multipartOverhead = 1 << 20
+
+// Upload 上传剧本文件并创建生成任务
+// 表单字段: file 为 .txt/.md/.fountain/.docx/.epub 文件;chapters 可选,从EPUB中选取章节,如 "1-3,5";
+// input 可选,与 /api/generate 请求体相同的JSON(其中 text 由文件内容替代)
+func (h *VideoHandler) Upload(c *gin.Context) {
+	doc, ok := h.readDocument(c)
+	if !ok {
+		return
+	}
+
+	var req model.Input
+	if raw := c.PostForm("input"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req); err != nil {
+			c.JSON(http.StatusBadRequest, model.APIResponse{
+				Code:      400,
+				Message:   "Invalid request",
+				Error:     fmt.Sprintf("invalid input field: %v", err),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	req.Text = doc.Text
+	if req.InputFormat == "" {
+		switch doc.Format {
+		case document.FormatMarkdown:
+			req.InputFormat = model.InputFormatMarkdown
+		case document.FormatFountain:
+			req.InputFormat = model.InputFormatFountain
+		}
+	}
+
+	h.createTask(c, req)
+}
+
+// InspectUpload 预览上传文件提取出的文本信息,不创建任务
+// 可用于查看EPUB的章节列表后再选择章节上传
+func (h *VideoHandler) InspectUpload(c *gin.Context) {
+	doc, ok := h.readDocument(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "success",
+		Data:      doc,
+		Timestamp: time.Now(),
+	})
+}
+
+// readDocument 读取表单中的文件并提取纯文本,失败时写入错误响应
+func (h *VideoHandler) readDocument(c *gin.Context) (*document.Document, bool) {
+	maxSize := h.config.Storage.MaxUploadSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.fileTooLarge(c)
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "File is required",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return nil, false
+	}
+	if header.Size > maxSize {
+		h.fileTooLarge(c)
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Failed to read file",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Failed to read file",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return nil, false
+	}
+
+	doc, err := document.Extract(header.Filename, data, maxSize*document.MaxExpansion)
+	if err == nil && c.PostForm("chapters") != "" {
+		var indexes []int
+		if indexes, err = parseChapters(c.PostForm("chapters")); err == nil {
+			err = doc.SelectChapters(indexes)
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to extract uploaded document",
+			zap.String("filename", header.Filename),
+			zap.Error(err))
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   "Invalid document",
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+		return nil, false
+	}
+
+	logger.Info("Document extracted",
+		zap.String("filename", header.Filename),
+		zap.String("format", doc.Format),
+		zap.String("encoding", doc.Encoding),
+		zap.Int("length", doc.Length),
+		zap.Int("chapters", len(doc.Chapters)))
+
+	return doc, true
+}
+
+// fileTooLarge 返回文件超过大小限制的错误响应
+func (h *VideoHandler) fileTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, model.APIResponse{
+		Code:      413,
+		Message:   "File too large",
+		Error:     fmt.Sprintf("file size exceeds maximum of %d bytes", h.config.Storage.MaxUploadSize),
+		Timestamp: time.Now(),
+	})
+}
+
+// maxChapters 最多可选择的章节数
+const maxChapters = 10000
+
+// parseChapters 解析章节选择,如 "1-3,5" 表示第1、2、3、5章
+// 重复或重叠的章节只保留第一次出现的位置
+func parseChapters(spec string) ([]int, error) {
+	var indexes []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapters %q: must be like 1-3,5", spec)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || end < start {
+				return nil, fmt.Errorf("invalid chapters %q: must be like 1-3,5", spec)
+			}
+		}
+		if start < 1 || end-start >= maxChapters {
+			return nil, fmt.Errorf("invalid chapters %q: chapter range out of bounds", spec)
+		}
+		for i := start; i <= end; i++ {
+			if seen[i] {
+				continue
+			}
+			if len(indexes) >= maxChapters {
+				return nil, fmt.Errorf("invalid chapters %q: chapter range out of bounds", spec)
+			}
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("invalid chapters %q: no chapter selected", spec)
+	}
+	return indexes, nil
+}
@@ -2,13 +2,19 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/screenplay"
 	"github.com/Jancd/1504/internal/service"
+	"github.com/Jancd/1504/internal/style"
 	"github.com/Jancd/1504/internal/task"
 	"github.com/Jancd/1504/pkg/config"
 	"github.com/Jancd/1504/pkg/logger"
@@ -21,38 +27,99 @@ import (
 // VideoHandler 视频处理器
 type VideoHandler struct {
 	taskManager       *task.Manager
+	seriesManager     *task.SeriesManager
 	parserService     *service.ParserService
 	storyboardService *service.StoryboardService
+	characterService  *service.CharacterService
 	imageService      *service.ImageService
 	renderService     *service.RenderService
 	qiniuVideoService *service.QiniuVideoService
+	webhookService    *service.WebhookService
+	planService       *service.PlanService
+	styles            *style.Registry
+	taskQueue         *task.Queue
 	config            *config.Config
 	useQiniuMode      bool // 是否使用七牛云直接生成视频模式
+
+	// 后台回调投递使用服务生命周期的ctx,关闭服务时取消并等待投递协程退出
+	ctx        context.Context
+	stop       context.CancelFunc
+	deliveries sync.WaitGroup
+
+	// 任务事件流在HTTP服务开始关闭时结束,避免长连接拖住关闭
+	streams      context.Context
+	closeStreams context.CancelFunc
 }
 
+const (
+	// defaultEstimatedTime 没有历史任务时的预估耗时(秒)
+	defaultEstimatedTime = 300
+	// cancelWaitTimeout 删除任务时等待执行中任务退出的最长时间
+	cancelWaitTimeout = 10 * time.Second
+)
+
 // NewVideoHandler 创建视频处理器
 func NewVideoHandler(
 	taskManager *task.Manager,
+	seriesManager *task.SeriesManager,
 	parserService *service.ParserService,
 	storyboardService *service.StoryboardService,
+	characterService *service.CharacterService,
 	imageService *service.ImageService,
 	renderService *service.RenderService,
 	qiniuVideoService *service.QiniuVideoService,
+	webhookService *service.WebhookService,
+	planService *service.PlanService,
+	styles *style.Registry,
 	cfg *config.Config,
 ) *VideoHandler {
 	// 判断使用哪种模式
 	useQiniu := cfg.VideoGeneration.Type == "qiniu"
 
-	return &VideoHandler{
+	h := &VideoHandler{
 		taskManager:       taskManager,
+		seriesManager:     seriesManager,
 		parserService:     parserService,
 		storyboardService: storyboardService,
+		characterService:  characterService,
 		imageService:      imageService,
 		renderService:     renderService,
 		qiniuVideoService: qiniuVideoService,
+		webhookService:    webhookService,
+		planService:       planService,
+		styles:            styles,
 		config:            cfg,
 		useQiniuMode:      useQiniu,
 	}
+	h.ctx, h.stop = context.WithCancel(context.Background())
+	h.streams, h.closeStreams = context.WithCancel(context.Background())
+
+	// 任务队列,限制同时执行的流水线数量
+	h.taskQueue = task.NewQueue(cfg.Limits.MaxConcurrentTasks, h.processTask)
+
+	return h
+}
+
+// CloseStreams 结束所有任务事件流,通过 http.Server.RegisterOnShutdown 在服务关闭时调用
+func (h *VideoHandler) CloseStreams() {
+	h.closeStreams()
+}
+
+// Close 关闭处理器: 在 ctx 结束前等待进行中的回调投递完成,超时后取消剩余投递并等待其退出
+func (h *VideoHandler) Close(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		h.deliveries.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn("Cancelling pending webhook deliveries")
+	}
+	h.stop()
+	<-done
 }
 
 // Generate 创建生成任务
@@ -68,39 +135,17 @@ func (h *VideoHandler) Generate(c *gin.Context) {
 		return
 	}
 
-	// 验证输入
-	if len(req.Text) == 0 {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
-			Code:      400,
-			Message:   "Text is required",
-			Error:     "text field cannot be empty",
-			Timestamp: time.Now(),
-		})
-		return
-	}
+	h.createTask(c, req)
+}
 
-	if len(req.Text) > h.config.Limits.MaxTextLength {
-		c.JSON(http.StatusBadRequest, model.APIResponse{
-			Code:      400,
-			Message:   "Text too long",
-			Error:     fmt.Sprintf("text length exceeds maximum of %d characters", h.config.Limits.MaxTextLength),
-			Timestamp: time.Now(),
-		})
-		return
-	}
+// createTask 校验输入、创建任务并加入队列
+func (h *VideoHandler) createTask(c *gin.Context, req model.Input) {
+	// 剧集分集信息只能由 /api/series 设置
+	req.Episode = nil
 
-	// 设置默认选项
-	if req.Options.Style == "" {
-		req.Options.Style = "anime"
-	}
-	if req.Options.DurationTarget == 0 {
-		req.Options.DurationTarget = 60
-	}
-	if req.Options.AspectRatio == "" {
-		req.Options.AspectRatio = "16:9"
-	}
-	if req.Options.BGM == "" {
-		req.Options.BGM = h.config.Video.DefaultBGM
+	if resp := h.prepareInput(&req, h.config.Limits.MaxTextLength); resp != nil {
+		c.JSON(http.StatusBadRequest, resp)
+		return
 	}
 
 	// 创建任务
@@ -113,91 +158,340 @@ func (h *VideoHandler) Generate(c *gin.Context) {
 		zap.String("task_id", taskID),
 		zap.Int("text_length", len(req.Text)))
 
-	// 异步处理任务
-	go h.processTask(taskID)
+	// 加入任务队列
+	position := h.taskQueue.Submit(taskID)
+
+	logger.Info("Task queued",
+		zap.String("task_id", taskID),
+		zap.Int("queue_position", position))
 
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    0,
 		Message: "success",
 		Data: gin.H{
 			"task_id":        taskID,
-			"status":         "processing",
-			"estimated_time": 300,
+			"status":         model.TaskStatusQueued,
+			"queue_position": position,
+			"estimated_time": h.estimateTime(taskID),
 		},
 		Timestamp: time.Now(),
 	})
 }
 
-// processTask 处理任务
-func (h *VideoHandler) processTask(taskID string) {
-	ctx := context.Background()
+// prepareInput 填充默认选项并校验生成参数,参数不合法时返回错误响应
+// maxTextLength 为输入文字的最大长度(字节)
+func (h *VideoHandler) prepareInput(input *model.Input, maxTextLength int) *model.APIResponse {
+	// 验证输入
+	if len(input.Text) == 0 {
+		return &model.APIResponse{
+			Code:      400,
+			Message:   "Text is required",
+			Error:     "text field cannot be empty",
+			Timestamp: time.Now(),
+		}
+	}
+
+	if len(input.Text) > maxTextLength {
+		return &model.APIResponse{
+			Code:      400,
+			Message:   "Text too long",
+			Error:     fmt.Sprintf("text length exceeds maximum of %d characters", maxTextLength),
+			Timestamp: time.Now(),
+		}
+	}
+
+	// 结构化剧本在提交时解析,格式错误直接返回
+	switch input.InputFormat {
+	case "":
+		input.InputFormat = model.InputFormatText
+	case model.InputFormatText:
+	case model.InputFormatFountain, model.InputFormatMarkdown, model.InputFormatJSON:
+		if _, err := screenplay.Parse(input.InputFormat, input.Text); err != nil {
+			return &model.APIResponse{
+				Code:      400,
+				Message:   "Invalid script",
+				Error:     err.Error(),
+				Timestamp: time.Now(),
+			}
+		}
+	default:
+		return &model.APIResponse{
+			Code:      400,
+			Message:   "Invalid input format",
+			Error:     "input_format must be one of: text, fountain, markdown, json",
+			Timestamp: time.Now(),
+		}
+	}
+
+	// 设置默认选项
+	if input.Options.Style == "" {
+		input.Options.Style = style.DefaultStyle
+	}
+	if input.Options.DurationTarget == 0 {
+		input.Options.DurationTarget = 60
+	}
+	if input.Options.AspectRatio == "" {
+		input.Options.AspectRatio = model.AspectRatioLandscape
+	}
+	if input.Options.BGM == "" {
+		input.Options.BGM = h.config.Video.DefaultBGM
+	}
+
+	// 验证画面比例
+	switch input.Options.AspectRatio {
+	case model.AspectRatioLandscape, model.AspectRatioPortrait, model.AspectRatioSquare, model.AspectRatioClassic:
+	default:
+		return &model.APIResponse{
+			Code:      400,
+			Message:   "Invalid aspect ratio",
+			Error:     "aspect_ratio must be one of: 16:9, 9:16, 1:1, 4:3",
+			Timestamp: time.Now(),
+		}
+	}
+
+	// 验证风格
+	if !h.styles.Has(input.Options.Style) {
+		return &model.APIResponse{
+			Code:      400,
+			Message:   "Invalid style",
+			Error:     fmt.Sprintf("style must be one of: %s", strings.Join(h.styles.Names(), ", ")),
+			Timestamp: time.Now(),
+		}
+	}
+
+	// 验证预设角色
+	for _, character := range input.Characters {
+		if character.Name == "" || character.Seed < 0 {
+			return &model.APIResponse{
+				Code:      400,
+				Message:   "Invalid character",
+				Error:     "characters must have a name and a non-negative seed",
+				Timestamp: time.Now(),
+			}
+		}
+		// 参考图相对任务项目目录,提交时目录尚未创建(剧集各集也不共用目录),只能在任务创建后通过角色接口设置
+		if character.ReferenceImage != "" {
+			return &model.APIResponse{
+				Code:      400,
+				Message:   "Invalid character",
+				Error:     "reference_image cannot be set when submitting; set it with PUT /api/tasks/:task_id/characters",
+				Timestamp: time.Now(),
+			}
+		}
+	}
+
+	// 验证回调地址
+	if input.CallbackURL != "" {
+		if u, err := url.Parse(input.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &model.APIResponse{
+				Code:      400,
+				Message:   "Invalid callback URL",
+				Error:     "callback_url must be an absolute http or https URL",
+				Timestamp: time.Now(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// estimateTime 根据历史平均耗时和排在任务前面的任务数估算完成时间(秒)
+func (h *VideoHandler) estimateTime(taskID string) int {
+	avg := h.taskManager.AverageDuration(defaultEstimatedTime)
 
+	// 前面的任务(执行中+排在前面的,不含自身)按并发数分批执行,再加上自身的执行时间
+	workers := h.taskQueue.Workers()
+	ahead := h.taskQueue.Ahead(taskID)
+	waves := 0
+	if ahead >= workers {
+		waves = (ahead-workers)/workers + 1
+	}
+
+	return int(avg * float64(waves+1))
+}
+
+// taskSnapshot 复制任务并在副本上填充当前的排队位置,不修改共享的任务
+func (h *VideoHandler) taskSnapshot(t *model.Task) *model.Task {
+	snapshot := t.Snapshot()
+	snapshot.QueuePosition = 0
+	if snapshot.Status == model.TaskStatusQueued {
+		snapshot.QueuePosition = h.taskQueue.Position(snapshot.ID)
+	}
+	return snapshot
+}
+
+// processTask 处理任务,ctx 在任务被取消时关闭
+func (h *VideoHandler) processTask(ctx context.Context, taskID string) {
 	t, ok := h.taskManager.Get(taskID)
 	if !ok {
 		logger.Error("Task not found in processTask", zap.String("task_id", taskID))
 		return
 	}
 
-	// 更新任务状态为处理中
-	t.Status = model.TaskStatusProcessing
-	h.taskManager.Update(t)
+	// 剧集分集无论如何结束都提交下一集(已提交过时不会重复提交)
+	defer h.submitNextEpisode(t.Input.Episode)
+
+	// 更新任务状态为处理中;任务在开始执行前已被取消时跳过
+	if _, err := h.taskManager.Transition(taskID, []string{model.TaskStatusQueued}, func(t *model.Task) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		t.Status = model.TaskStatusProcessing
+		return nil
+	}); err != nil {
+		logger.Info("Skipping cancelled task", zap.String("task_id", taskID), zap.Error(err))
+		return
+	}
 
 	logger.Info("Starting task processing", zap.String("task_id", taskID))
 
-	// 步骤1: 解析剧本
-	t.UpdateStep(model.StepParseScript, model.StepStatusProcessing)
-	h.taskManager.Update(t)
+	// 步骤1: 解析剧本(重试时复用已保存的解析结果)
+	var parsed *model.ParsedScript
+	if t.IsStepCompleted(model.StepParseScript) {
+		parsed = resumeArtifact(taskID, model.StepParseScript, h.parserService.Load)
+	}
 
-	parsed, err := h.parserService.Parse(ctx, taskID, t.Input.Text)
-	if err != nil {
-		h.failTask(taskID, model.StepParseScript, fmt.Sprintf("Failed to parse script: %v", err))
-		return
+	if parsed == nil {
+		h.updateStep(t, model.StepParseScript, model.StepStatusProcessing)
+
+		var err error
+		parsed, err = h.parserService.Parse(ctx, taskID, t.Input.Text, t.Input.InputFormat, func(completed, total int) {
+			t.SetStepProgress(model.StepParseScript, (completed*100)/total, fmt.Sprintf("%d/%d chunks", completed, total))
+			h.taskManager.Update(t)
+		})
+		if err != nil {
+			h.failTask(ctx, taskID, model.StepParseScript, fmt.Sprintf("Failed to parse script: %v", err))
+			return
+		}
+
+		h.updateStep(t, model.StepParseScript, model.StepStatusCompleted)
 	}
 
-	t.UpdateStep(model.StepParseScript, model.StepStatusCompleted)
-	h.taskManager.Update(t)
+	// 角色设定表(重试时复用已保存或人工编辑过的设定)
+	// 剧集分集优先沿用剧集共享的角色设定,新出现的角色加入共享设定
+	if _, err := h.characterService.Load(taskID); err != nil {
+		h.updateStep(t, model.StepGenerateCharacters, model.StepStatusProcessing)
 
-	// 步骤2: 生成分镜
-	t.UpdateStep(model.StepGenerateStoryboard, model.StepStatusProcessing)
-	h.taskManager.Update(t)
+		sheet, err := h.characterService.Generate(ctx, taskID, t.Input.Text, parsed, h.characterPresets(t, parsed))
+		if err != nil {
+			h.failTask(ctx, taskID, model.StepGenerateCharacters, fmt.Sprintf("Failed to generate character sheet: %v", err))
+			return
+		}
+		if t.Input.Episode != nil {
+			if err := h.seriesManager.AddCharacters(t.Input.Episode.SeriesID, sheet.Characters); err != nil {
+				logger.Warn("Failed to share characters with series",
+					zap.String("task_id", taskID),
+					zap.String("series_id", t.Input.Episode.SeriesID),
+					zap.Error(err))
+			}
+		}
 
-	storyboard, err := h.storyboardService.Generate(ctx, taskID, parsed, t.Input.Options.DurationTarget)
-	if err != nil {
-		h.failTask(taskID, model.StepGenerateStoryboard, fmt.Sprintf("Failed to generate storyboard: %v", err))
-		return
+		h.updateStep(t, model.StepGenerateCharacters, model.StepStatusCompleted)
+	} else if !t.IsStepCompleted(model.StepGenerateCharacters) {
+		h.updateStep(t, model.StepGenerateCharacters, model.StepStatusCompleted)
 	}
 
-	// 检查镜头数量限制
-	if len(storyboard.Shots) > h.config.Limits.MaxShotsPerVideo {
-		h.failTask(taskID, model.StepGenerateStoryboard,
-			fmt.Sprintf("Too many shots generated (%d), maximum is %d",
-				len(storyboard.Shots), h.config.Limits.MaxShotsPerVideo))
-		return
+	// 角色设定已写入剧集共享设定,下一集可以开始执行
+	h.submitNextEpisode(t.Input.Episode)
+
+	// 步骤2: 生成分镜(重试时复用已保存的分镜脚本)
+	var storyboard *model.Storyboard
+	if t.IsStepCompleted(model.StepGenerateStoryboard) {
+		storyboard = resumeArtifact(taskID, model.StepGenerateStoryboard, h.storyboardService.Load)
 	}
 
-	t.UpdateStep(model.StepGenerateStoryboard, model.StepStatusCompleted)
-	h.taskManager.Update(t)
+	if storyboard == nil {
+		h.updateStep(t, model.StepGenerateStoryboard, model.StepStatusProcessing)
+
+		var err error
+		storyboard, err = h.storyboardService.Generate(ctx, taskID, parsed, t.Input.Options, t.Input.Episode)
+		if err != nil {
+			h.failTask(ctx, taskID, model.StepGenerateStoryboard, fmt.Sprintf("Failed to generate storyboard: %v", err))
+			return
+		}
+
+		// 检查镜头数量限制
+		if len(storyboard.Shots) > h.config.Limits.MaxShotsPerVideo {
+			h.failTask(ctx, taskID, model.StepGenerateStoryboard,
+				fmt.Sprintf("Too many shots generated (%d), maximum is %d",
+					len(storyboard.Shots), h.config.Limits.MaxShotsPerVideo))
+			return
+		}
+
+		h.updateStep(t, model.StepGenerateStoryboard, model.StepStatusCompleted)
+
+		// 审核模式: 分镜生成后暂停,等待人工编辑和确认
+		if t.Input.Options.ReviewStoryboard {
+			if _, err := h.taskManager.Transition(taskID, []string{model.TaskStatusProcessing}, func(t *model.Task) error {
+				t.Status = model.TaskStatusAwaitingReview
+				return nil
+			}); err != nil {
+				logger.Info("Task cancelled before storyboard review", zap.String("task_id", taskID), zap.Error(err))
+				return
+			}
+
+			logger.Info("Storyboard awaiting review", zap.String("task_id", taskID))
+			return
+		}
+	}
 
 	var result *model.Result
 
 	// 根据模式选择不同的处理流程
-	if h.useQiniuMode {
+	switch {
+	case h.useQiniuMode && h.config.VideoGeneration.Qiniu.Mode == "per_shot":
+		// 七牛云逐镜头模式：每个镜头生成一个片段,再本地拼接
+		logger.Info("Using Qiniu per-shot mode", zap.String("task_id", taskID))
+
+		// 步骤3: 生成镜头片段
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusProcessing)
+
+		err := h.qiniuVideoService.GenerateShotClips(ctx, taskID, storyboard, t.Input.Options,
+			func(event model.PollEvent) {
+				h.taskManager.Publish(taskID, model.TaskEventPoll, model.StepGenerateImages, event)
+			},
+			func(shot *model.Shot, completed, total int) {
+				progress := (completed * 100) / total
+				t.Progress = progress
+				t.SetStepProgress(model.StepGenerateImages, progress, fmt.Sprintf("%d/%d clips", completed, total))
+				h.taskManager.Update(t)
+
+				h.taskManager.Publish(taskID, model.TaskEventShot, model.StepGenerateImages, model.ShotEvent{
+					ShotID:  shot.ID,
+					Current: completed,
+					Total:   total,
+				})
+			})
+		if err != nil {
+			h.failTask(ctx, taskID, model.StepGenerateImages, fmt.Sprintf("Failed to generate clips with Qiniu: %v", err))
+			return
+		}
+
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusCompleted)
+
+		// 步骤4: 拼接片段
+		if result = h.renderVideo(ctx, t, storyboard); result == nil {
+			return
+		}
+	case h.useQiniuMode:
 		// 七牛云模式：直接生成视频
 		logger.Info("Using Qiniu Video Generation mode", zap.String("task_id", taskID))
 
 		// 步骤3: 生成视频(跳过图像生成步骤)
-		t.UpdateStep(model.StepGenerateImages, model.StepStatusProcessing)
-		h.taskManager.Update(t)
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusProcessing)
 
-		videoPath, err := h.qiniuVideoService.GenerateFromStoryboard(ctx, taskID, storyboard)
+		videoPath, err := h.qiniuVideoService.GenerateFromStoryboard(ctx, taskID, storyboard, t.Input.Options, func(event model.PollEvent) {
+			t.SetStepProgress(model.StepGenerateImages, 0, fmt.Sprintf("qiniu: %s", event.Status))
+			h.taskManager.Update(t)
+			h.taskManager.Publish(taskID, model.TaskEventPoll, model.StepGenerateImages, event)
+		})
 		if err != nil {
-			h.failTask(taskID, model.StepGenerateImages, fmt.Sprintf("Failed to generate video with Qiniu: %v", err))
+			h.failTask(ctx, taskID, model.StepGenerateImages, fmt.Sprintf("Failed to generate video with Qiniu: %v", err))
 			return
 		}
 
-		t.UpdateStep(model.StepGenerateImages, model.StepStatusCompleted)
-		t.UpdateStep(model.StepRenderVideo, model.StepStatusCompleted) // 视频已经生成,跳过渲染步骤
-		h.taskManager.Update(t)
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusCompleted)
+		h.updateStep(t, model.StepRenderVideo, model.StepStatusCompleted) // 视频已经生成,跳过渲染步骤
 
 		// 获取文件大小
 		fileSize, _ := utils.GetFileSize(videoPath)
@@ -205,60 +499,81 @@ func (h *VideoHandler) processTask(taskID string) {
 		result = &model.Result{
 			VideoPath:  videoPath,
 			Duration:   storyboard.TotalDuration,
-			Resolution: "1920x1080",
+			Resolution: h.qiniuVideoService.Resolution(ctx, videoPath, t.Input.Options.AspectRatio),
 			FileSize:   fileSize,
 			ShotCount:  len(storyboard.Shots),
 		}
-	} else {
+	case h.config.VideoGeneration.Type == "hybrid":
+		// 混合模式：SD生成关键帧,七牛云图生视频,失败的镜头退回静态图
+		logger.Info("Using hybrid keyframe animation mode", zap.String("task_id", taskID))
+
+		// 步骤3: 生成关键帧并动画化(前半段进度为关键帧,后半段为动画)
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusProcessing)
+
+		if err := h.generateImages(ctx, t, storyboard, 0, 50); err != nil {
+			h.failTask(ctx, taskID, model.StepGenerateImages, fmt.Sprintf("Failed to generate images: %v", err))
+			return
+		}
+
+		err := h.qiniuVideoService.AnimateKeyframes(ctx, taskID, storyboard, t.Input.Options,
+			func(event model.PollEvent) {
+				h.taskManager.Publish(taskID, model.TaskEventPoll, model.StepGenerateImages, event)
+			},
+			func(shot *model.Shot, completed, total int) {
+				progress := 50 + (completed*50)/total
+				t.Progress = progress
+				t.SetStepProgress(model.StepGenerateImages, progress, fmt.Sprintf("%d/%d clips", completed, total))
+				h.taskManager.Update(t)
+
+				h.taskManager.Publish(taskID, model.TaskEventShot, model.StepGenerateImages, model.ShotEvent{
+					ShotID:    shot.ID,
+					Current:   completed,
+					Total:     total,
+					ImagePath: shot.ImagePath,
+				})
+			})
+		if err != nil {
+			h.failTask(ctx, taskID, model.StepGenerateImages, fmt.Sprintf("Failed to animate keyframes with Qiniu: %v", err))
+			return
+		}
+
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusCompleted)
+
+		// 步骤4: 渲染视频
+		if result = h.renderVideo(ctx, t, storyboard); result == nil {
+			return
+		}
+	default:
 		// SD模式：图像生成 + 渲染
 		logger.Info("Using SD + Render mode", zap.String("task_id", taskID))
 
 		// 步骤3: 生成图像
-		t.UpdateStep(model.StepGenerateImages, model.StepStatusProcessing)
-		h.taskManager.Update(t)
-
-		err = h.imageService.GenerateAll(ctx, taskID, storyboard, func(current, total int) {
-			// 更新进度
-			progress := (current * 100) / total
-			t.Progress = progress
-			t.SetStepProgress(model.StepGenerateImages, progress, fmt.Sprintf("%d/%d shots", current, total))
-			h.taskManager.Update(t)
-
-			logger.Info("Image generation progress",
-				zap.String("task_id", taskID),
-				zap.Int("current", current),
-				zap.Int("total", total),
-				zap.Int("progress", progress))
-		})
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusProcessing)
 
+		err := h.generateImages(ctx, t, storyboard, 0, 100)
 		if err != nil {
-			h.failTask(taskID, model.StepGenerateImages, fmt.Sprintf("Failed to generate images: %v", err))
+			h.failTask(ctx, taskID, model.StepGenerateImages, fmt.Sprintf("Failed to generate images: %v", err))
 			return
 		}
 
-		t.UpdateStep(model.StepGenerateImages, model.StepStatusCompleted)
-		h.taskManager.Update(t)
+		h.updateStep(t, model.StepGenerateImages, model.StepStatusCompleted)
 
 		// 步骤4: 渲染视频
-		t.UpdateStep(model.StepRenderVideo, model.StepStatusProcessing)
-		h.taskManager.Update(t)
-
-		var renderErr error
-		result, renderErr = h.renderService.RenderWithSubtitles(ctx, taskID, storyboard, t.Input.Options.BGM)
-		if renderErr != nil {
-			h.failTask(taskID, model.StepRenderVideo, fmt.Sprintf("Failed to render video: %v", renderErr))
+		if result = h.renderVideo(ctx, t, storyboard); result == nil {
 			return
 		}
+	}
 
-		t.UpdateStep(model.StepRenderVideo, model.StepStatusCompleted)
-		h.taskManager.Update(t)
+	// 任务在最后一步结束后才被取消,保留取消状态
+	if ctx.Err() != nil {
+		h.failTask(ctx, taskID, model.StepRenderVideo, "task cancelled")
+		return
 	}
 
 	// 任务完成
-	t.Status = model.TaskStatusCompleted
-	t.Progress = 100
-	t.Result = result
-	h.taskManager.Update(t)
+	if !h.completeTask(t, result) {
+		return
+	}
 
 	logger.Info("Task completed successfully",
 		zap.String("task_id", taskID),
@@ -266,22 +581,253 @@ func (h *VideoHandler) processTask(taskID string) {
 		zap.Int64("file_size", result.FileSize))
 }
 
-// failTask 标记任务失败
-func (h *VideoHandler) failTask(taskID, step, errMsg string) {
+// completeTask 标记任务完成并投递回调,任务已不在处理中(如已被取消)时返回false
+// 状态检查和修改在任务管理器的锁内完成,已被取消的任务保留取消状态
+func (h *VideoHandler) completeTask(t *model.Task, result *model.Result) bool {
+	if _, err := h.taskManager.Transition(t.ID, []string{model.TaskStatusProcessing}, func(t *model.Task) error {
+		t.Status = model.TaskStatusCompleted
+		t.Progress = 100
+		t.Result = result
+		return nil
+	}); err != nil {
+		logger.Info("Task not completed", zap.String("task_id", t.ID), zap.Error(err))
+		return false
+	}
+
+	h.notify(t)
+	return true
+}
+
+// generateImages 生成所有镜头图像,进度映射到 [from, from+span] 区间
+func (h *VideoHandler) generateImages(ctx context.Context, t *model.Task, storyboard *model.Storyboard, from, span int) error {
+	taskID := t.ID
+	return h.imageService.GenerateAll(ctx, taskID, storyboard, t.Input.Options, func(current, total int) {
+		// 更新进度
+		progress := from + (current*span)/total
+		t.Progress = progress
+		t.SetStepProgress(model.StepGenerateImages, progress, fmt.Sprintf("%d/%d shots", current, total))
+		h.taskManager.Update(t)
+
+		shot := storyboard.Shots[current-1]
+		h.taskManager.Publish(taskID, model.TaskEventShot, model.StepGenerateImages, model.ShotEvent{
+			ShotID:    shot.ID,
+			Current:   current,
+			Total:     total,
+			ImagePath: shot.ImagePath,
+		})
+
+		logger.Info("Image generation progress",
+			zap.String("task_id", taskID),
+			zap.Int("current", current),
+			zap.Int("total", total),
+			zap.Int("progress", progress))
+	})
+}
+
+// renderVideo 执行渲染步骤,失败时标记任务失败并返回nil
+func (h *VideoHandler) renderVideo(ctx context.Context, t *model.Task, storyboard *model.Storyboard) *model.Result {
+	h.updateStep(t, model.StepRenderVideo, model.StepStatusProcessing)
+
+	result, err := h.renderService.RenderWithSubtitles(ctx, t.ID, storyboard, t.Input.Options)
+	if err != nil {
+		h.failTask(ctx, t.ID, model.StepRenderVideo, fmt.Sprintf("Failed to render video: %v", err))
+		return nil
+	}
+
+	h.updateStep(t, model.StepRenderVideo, model.StepStatusCompleted)
+	return result
+}
+
+// updateStep 更新步骤状态并推送步骤事件
+func (h *VideoHandler) updateStep(t *model.Task, step, status string) {
+	t.UpdateStep(step, status)
+	h.taskManager.Update(t)
+	h.taskManager.Publish(t.ID, model.TaskEventStep, step, gin.H{"status": status})
+}
+
+// failTask 标记任务失败,任务已被取消时标记为取消
+// 状态检查和修改在任务管理器的锁内完成,不会覆盖同时发生的取消
+func (h *VideoHandler) failTask(ctx context.Context, taskID, step, errMsg string) {
+	cancelled := errors.Is(ctx.Err(), context.Canceled)
+	snapshot, err := h.taskManager.Transition(taskID,
+		[]string{model.TaskStatusProcessing, model.TaskStatusCancelled},
+		func(t *model.Task) error {
+			if cancelled || t.Status == model.TaskStatusCancelled {
+				t.Status = model.TaskStatusCancelled
+				t.Error = ""
+				t.UpdateStep(step, model.StepStatusCancelled)
+				return nil
+			}
+			t.Status = model.TaskStatusFailed
+			t.Error = errMsg
+			t.UpdateStep(step, model.StepStatusFailed)
+			return nil
+		})
+	if err != nil {
+		logger.Warn("Failed to mark task failed", zap.String("task_id", taskID), zap.Error(err))
+		return
+	}
+
+	if snapshot.Status == model.TaskStatusCancelled {
+		logger.Info("Task cancelled",
+			zap.String("task_id", taskID),
+			zap.String("step", step))
+		h.taskManager.Publish(taskID, model.TaskEventStep, step, gin.H{"status": model.StepStatusCancelled})
+		return
+	}
+
 	logger.Error("Task failed",
 		zap.String("task_id", taskID),
 		zap.String("step", step),
 		zap.String("error", errMsg))
 
-	t, ok := h.taskManager.Get(taskID)
-	if !ok {
+	h.taskManager.Publish(taskID, model.TaskEventStep, step, gin.H{"status": model.StepStatusFailed})
+	if t, ok := h.taskManager.Get(taskID); ok {
+		h.notify(t)
+	}
+}
+
+// notify 异步投递任务回调,投递记录保存到任务中
+func (h *VideoHandler) notify(t *model.Task) {
+	if t.Input.CallbackURL == "" {
 		return
 	}
 
-	t.UpdateStep(step, model.StepStatusFailed)
-	t.Status = model.TaskStatusFailed
-	t.Error = errMsg
-	h.taskManager.Update(t)
+	taskID := t.ID
+	callbackURL := t.Input.CallbackURL
+	secret := t.Input.CallbackSecret
+	snapshot := t.Snapshot()
+	snapshot.Callbacks = nil
+
+	if h.ctx.Err() != nil {
+		logger.Warn("Server shutting down, skipping webhook", zap.String("task_id", t.ID))
+		return
+	}
+
+	h.deliveries.Add(1)
+	go func() {
+		defer h.deliveries.Done()
+		err := h.webhookService.Deliver(h.ctx, callbackURL, secret, snapshot, func(attempt model.CallbackAttempt) {
+			if err := h.taskManager.AddCallback(taskID, attempt); err != nil {
+				logger.Warn("Failed to record webhook attempt", zap.String("task_id", taskID), zap.Error(err))
+			}
+		})
+		if err != nil {
+			logger.Error("Failed to deliver webhook",
+				zap.String("task_id", taskID),
+				zap.String("url", callbackURL),
+				zap.Error(err))
+		}
+	}()
+}
+
+// resumeArtifact 重试时加载已完成步骤保存的产物,加载失败返回nil以重新执行该步骤
+func resumeArtifact[T any](taskID, step string, load func(taskID string) (*T, error)) *T {
+	artifact, err := load(taskID)
+	if err != nil {
+		logger.Warn("Failed to load saved artifact, re-running step",
+			zap.String("task_id", taskID),
+			zap.String("step", step),
+			zap.Error(err))
+		return nil
+	}
+
+	logger.Info("Resuming from saved artifact",
+		zap.String("task_id", taskID),
+		zap.String("step", step))
+	return artifact
+}
+
+// errTaskStillRunning 任务已结束但工作协程尚未退出
+var errTaskStillRunning = errors.New("task is still running, try again later")
+
+// transitionFailed 返回任务状态转换失败的响应
+func (h *VideoHandler) transitionFailed(c *gin.Context, taskID, message string, err error) {
+	switch {
+	case errors.Is(err, task.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, model.APIResponse{
+			Code:      404,
+			Message:   "Task not found",
+			Error:     fmt.Sprintf("task %s does not exist", taskID),
+			Timestamp: time.Now(),
+		})
+	case errors.Is(err, errTaskStillRunning):
+		c.JSON(http.StatusConflict, model.APIResponse{
+			Code:      409,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+	default:
+		c.JSON(http.StatusBadRequest, model.APIResponse{
+			Code:      400,
+			Message:   message,
+			Error:     err.Error(),
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// RetryTask 从失败的步骤重试任务
+func (h *VideoHandler) RetryTask(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	// 状态检查和转换在任务管理器的锁内完成,并发的重试只有一个能成功
+	_, err := h.taskManager.Transition(taskID,
+		[]string{model.TaskStatusFailed, model.TaskStatusCancelled, model.TaskStatusInterrupted},
+		func(t *model.Task) error {
+			// 已取消的任务在工作协程退出前不能重新入队,否则同一任务会被两个工作协程执行
+			if h.taskQueue.Done(taskID) != nil {
+				return errTaskStillRunning
+			}
+
+			// 未完成的步骤重置为待处理,已完成的步骤在执行时复用保存的产物
+			t.ResetUnfinishedSteps()
+			t.Status = model.TaskStatusQueued
+			t.Error = ""
+			t.Result = nil
+			return nil
+		})
+	if err != nil {
+		h.transitionFailed(c, taskID, "Task cannot be retried", err)
+		return
+	}
+
+	position := h.taskQueue.Submit(taskID)
+
+	logger.Info("Task retry queued",
+		zap.String("task_id", taskID),
+		zap.Int("queue_position", position))
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:    0,
+		Message: "success",
+		Data: gin.H{
+			"task_id":        taskID,
+			"status":         model.TaskStatusQueued,
+			"queue_position": position,
+			"estimated_time": h.estimateTime(taskID),
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// CancelTask 取消任务
+func (h *VideoHandler) CancelTask(c *gin.Context) {
+	taskID := c.Param("task_id")
+
+	snapshot, err := h.cancelTask(taskID)
+	if err != nil {
+		h.transitionFailed(c, taskID, "Task cannot be cancelled", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "Task cancelled",
+		Data:      snapshot,
+		Timestamp: time.Now(),
+	})
 }
 
 // GetTask 获取任务状态
@@ -302,7 +848,7 @@ func (h *VideoHandler) GetTask(c *gin.Context) {
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:      0,
 		Message:   "success",
-		Data:      t,
+		Data:      h.taskSnapshot(t),
 		Timestamp: time.Now(),
 	})
 }
@@ -370,6 +916,63 @@ func (h *VideoHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
+	h.removeTask(taskID)
+
+	c.JSON(http.StatusOK, model.APIResponse{
+		Code:      0,
+		Message:   "Task deleted successfully",
+		Timestamp: time.Now(),
+	})
+}
+
+// cancellableStatuses 可以取消的任务状态
+var cancellableStatuses = []string{
+	model.TaskStatusQueued,
+	model.TaskStatusProcessing,
+	model.TaskStatusAwaitingReview,
+}
+
+// cancelTask 取消任务,返回取消后的任务快照
+// 排队中的任务直接移出队列,执行中的任务通过上下文中止;
+// 状态检查和修改在任务管理器的锁内完成,不会与工作协程的完成、重试或确认交错
+func (h *VideoHandler) cancelTask(taskID string) (*model.Task, error) {
+	snapshot, err := h.taskManager.Transition(taskID, cancellableStatuses, func(t *model.Task) error {
+		h.taskQueue.Cancel(taskID)
+		t.Status = model.TaskStatusCancelled
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 未在执行的分集不会再进入 processTask,由这里提交下一集
+	if h.taskQueue.Done(taskID) == nil {
+		h.submitNextEpisode(snapshot.Input.Episode)
+	}
+
+	logger.Info("Task cancel requested", zap.String("task_id", taskID))
+	return snapshot, nil
+}
+
+// removeTask 删除任务及其项目文件
+// 先取消仍在排队或执行的任务,等待其退出后再删除文件
+func (h *VideoHandler) removeTask(taskID string) {
+	var episode *model.Episode
+	if t, ok := h.taskManager.Get(taskID); ok {
+		episode = t.Input.Episode
+	}
+
+	if h.taskQueue.Cancel(taskID) {
+		if done := h.taskQueue.Done(taskID); done != nil {
+			select {
+			case <-done:
+			case <-time.After(cancelWaitTimeout):
+				logger.Warn("Timed out waiting for cancelled task to stop",
+					zap.String("task_id", taskID))
+			}
+		}
+	}
+
 	// 删除项目文件
 	projectDir := filepath.Join(h.config.Storage.DataDir, "projects", taskID)
 	if utils.FileExists(projectDir) {
@@ -387,18 +990,17 @@ func (h *VideoHandler) DeleteTask(c *gin.Context) {
 			zap.Error(err))
 	}
 
-	logger.Info("Task deleted", zap.String("task_id", taskID))
+	h.submitNextEpisode(episode)
 
-	c.JSON(http.StatusOK, model.APIResponse{
-		Code:      0,
-		Message:   "Task deleted successfully",
-		Timestamp: time.Now(),
-	})
+	logger.Info("Task deleted", zap.String("task_id", taskID))
 }
 
 // ListTasks 列出所有任务
 func (h *VideoHandler) ListTasks(c *gin.Context) {
 	tasks := h.taskManager.List()
+	for i, t := range tasks {
+		tasks[i] = h.taskSnapshot(t)
+	}
 
 	c.JSON(http.StatusOK, model.APIResponse{
 		Code:    0,
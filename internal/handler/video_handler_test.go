@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/service"
+)
+
+func TestCancelTask(t *testing.T) {
+	h := newTestHandler(t, nil)
+
+	for _, status := range []string{model.TaskStatusAwaitingReview, model.TaskStatusCompleted} {
+		task := model.NewTask(status, model.Input{Text: "x"})
+		task.Status = status
+		h.taskManager.Create(task)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "unknown task", path: "/api/tasks/missing/cancel", code: http.StatusNotFound},
+		{name: "completed task", path: "/api/tasks/completed/cancel", code: http.StatusBadRequest},
+		{name: "awaiting review", path: "/api/tasks/awaiting_review/cancel", code: http.StatusOK},
+		{name: "cancel twice", path: "/api/tasks/awaiting_review/cancel", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder, resp := serve(t, http.MethodPost, "/api/tasks/:task_id/cancel", tt.path, nil, h.CancelTask)
+			if recorder.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %+v", recorder.Code, tt.code, resp)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var snapshot model.Task
+			decodeData(t, resp, &snapshot)
+			if snapshot.Status != model.TaskStatusCancelled {
+				t.Errorf("returned status = %s, want cancelled", snapshot.Status)
+			}
+		})
+	}
+}
+
+func TestCancelRacesWorker(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(h *VideoHandler, task *model.Task) bool // 工作协程结束任务,返回是否生效
+		status string                                       // 工作协程先结束时的状态
+	}{
+		{
+			name: "completion",
+			finish: func(h *VideoHandler, task *model.Task) bool {
+				return h.completeTask(task, &model.Result{VideoPath: "out.mp4"})
+			},
+			status: model.TaskStatusCompleted,
+		},
+		{
+			name: "failure",
+			finish: func(h *VideoHandler, task *model.Task) bool {
+				h.failTask(context.Background(), task.ID, model.StepRenderVideo, "render failed")
+				current, _ := h.taskManager.Get(task.ID)
+				return current.Error != ""
+			},
+			status: model.TaskStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, nil)
+
+			// 取消和工作协程同时结束任务,只有先发生的一方生效
+			for range 50 {
+				task := model.NewTask("t", model.Input{Text: "x"})
+				task.Status = model.TaskStatusProcessing
+				h.taskManager.Create(task)
+
+				var wg sync.WaitGroup
+				var cancelErr error
+				var finished bool
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					_, cancelErr = h.cancelTask("t")
+				}()
+				go func() {
+					defer wg.Done()
+					finished = tt.finish(h, task)
+				}()
+				wg.Wait()
+
+				current, _ := h.taskManager.Get("t")
+				switch {
+				case cancelErr == nil && !finished:
+					if current.Status != model.TaskStatusCancelled || current.Result != nil {
+						t.Fatalf("cancelled task ended as %s, result %+v", current.Status, current.Result)
+					}
+				case cancelErr != nil && finished:
+					if current.Status != tt.status {
+						t.Fatalf("finished task ended as %s, want %s", current.Status, tt.status)
+					}
+				default:
+					t.Fatalf("cancel error = %v, worker finished = %v, status %s", cancelErr, finished, current.Status)
+				}
+
+				if err := h.taskManager.Delete("t"); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCloseWaitsForWebhooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		timeout time.Duration
+		success bool
+	}{
+		{name: "waits for delivery", status: http.StatusOK, timeout: 5 * time.Second, success: true},
+		// 首次失败后等待10秒重试,关闭超时后取消重试
+		{name: "cancels retries after timeout", status: http.StatusInternalServerError, timeout: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer hook.Close()
+
+			h := newTestHandler(t, nil)
+			h.webhookService = service.NewWebhookService(1, 3, 10)
+			task := model.NewTask("t", model.Input{Text: "x", CallbackURL: hook.URL})
+			task.Status = model.TaskStatusCompleted
+			h.taskManager.Create(task)
+			h.notify(task)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			start := time.Now()
+			h.Close(ctx)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Close() took %v", elapsed)
+			}
+
+			current, _ := h.taskManager.Get("t")
+			if len(current.Callbacks) != 1 || current.Callbacks[0].Success != tt.success {
+				t.Errorf("callbacks = %+v, want one attempt with success %v", current.Callbacks, tt.success)
+			}
+		})
+	}
+}
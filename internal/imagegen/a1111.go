@@ -0,0 +1,42 @@
+package imagegen
+
+import (
+	"context"
+
+	"github.com/Jancd/1504/internal/client"
+)
+
+// A1111Generator AUTOMATIC1111 WebUI 的 /sdapi/v1/txt2img 接口
+type A1111Generator struct {
+	client *client.SDClient
+}
+
+// NewA1111Generator 创建AUTOMATIC1111图像生成后端
+func NewA1111Generator(apiURL string, timeout int) *A1111Generator {
+	return &A1111Generator{
+		client: client.NewSDClient(apiURL, timeout),
+	}
+}
+
+// Generate 生成图像,参考图通过ControlNet reference_only 生效
+func (g *A1111Generator) Generate(ctx context.Context, req Request) ([]byte, error) {
+	sdReq := client.Txt2ImgRequest{
+		Prompt:         req.Prompt,
+		NegativePrompt: req.NegativePrompt,
+		Steps:          req.Steps,
+		CFGScale:       req.CFGScale,
+		Width:          req.Width,
+		Height:         req.Height,
+		SamplerName:    req.Sampler,
+		Seed:           req.Seed,
+	}
+	if len(req.ReferenceImage) > 0 {
+		sdReq.SetReferenceImage(req.ReferenceImage)
+	}
+	return g.client.GenerateImage(ctx, sdReq)
+}
+
+// CheckHealth 检查SD服务健康状态
+func (g *A1111Generator) CheckHealth(ctx context.Context) error {
+	return g.client.CheckHealth(ctx)
+}
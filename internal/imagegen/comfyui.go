@@ -0,0 +1,311 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// comfyUIPollInterval 查询ComfyUI执行结果的间隔
+const comfyUIPollInterval = time.Second
+
+// ComfyUIGenerator ComfyUI 工作流图像生成
+// 工作流为ComfyUI导出的API格式JSON,节点参数中的占位符在提交时替换:
+// {{prompt}} {{negative_prompt}} {{seed}} {{width}} {{height}} {{steps}} {{cfg}}
+// 值恰好为数值占位符的字符串会替换为数字,其余占位符按文本替换
+type ComfyUIGenerator struct {
+	apiURL   string
+	workflow map[string]interface{}
+	client   *http.Client
+	timeout  time.Duration
+}
+
+// NewComfyUIGenerator 创建ComfyUI图像生成后端并加载工作流
+func NewComfyUIGenerator(apiURL, workflowPath string, timeout int) (*ComfyUIGenerator, error) {
+	data, err := os.ReadFile(workflowPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comfyui workflow: %w", err)
+	}
+
+	var workflow map[string]interface{}
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		return nil, fmt.Errorf("failed to parse comfyui workflow: %w", err)
+	}
+
+	return &ComfyUIGenerator{
+		apiURL:   strings.TrimRight(apiURL, "/"),
+		workflow: workflow,
+		client:   &http.Client{},
+		timeout:  time.Duration(timeout) * time.Second,
+	}, nil
+}
+
+// comfyUIImage ComfyUI输出图像
+type comfyUIImage struct {
+	Filename  string `json:"filename"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+// comfyUIHistory ComfyUI执行记录
+type comfyUIHistory struct {
+	Outputs map[string]struct {
+		Images []comfyUIImage `json:"images"`
+	} `json:"outputs"`
+	Status struct {
+		StatusStr string `json:"status_str"`
+		Completed bool   `json:"completed"`
+	} `json:"status"`
+}
+
+// Generate 提交工作流并等待输出图像
+func (g *ComfyUIGenerator) Generate(ctx context.Context, req Request) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	seed := req.Seed
+	if seed < 0 {
+		// ComfyUI不支持-1随机种子
+		seed = rand.Int63n(1<<32 - 1)
+	}
+	steps := req.Steps
+	if steps <= 0 {
+		steps = 30
+	}
+	cfgScale := req.CFGScale
+	if cfgScale <= 0 {
+		cfgScale = 7.5
+	}
+
+	values := map[string]interface{}{
+		"{{prompt}}":          req.Prompt,
+		"{{negative_prompt}}": req.NegativePrompt,
+		"{{seed}}":            seed,
+		"{{width}}":           req.Width,
+		"{{height}}":          req.Height,
+		"{{steps}}":           steps,
+		"{{cfg}}":             cfgScale,
+	}
+
+	logger.Info("Generating image with ComfyUI",
+		zap.String("prompt", req.Prompt),
+		zap.Int("width", req.Width),
+		zap.Int("height", req.Height),
+		zap.Int64("seed", seed))
+
+	startTime := time.Now()
+
+	promptID, err := g.submit(ctx, fillPlaceholders(g.workflow, values))
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := g.waitForImage(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	imageData, err := g.download(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Image generated successfully",
+		zap.String("prompt_id", promptID),
+		zap.Duration("duration", time.Since(startTime)),
+		zap.Int("image_size", len(imageData)))
+
+	return imageData, nil
+}
+
+// submit 提交工作流,返回 prompt_id
+func (g *ComfyUIGenerator) submit(ctx context.Context, workflow interface{}) (string, error) {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"prompt":    workflow,
+		"client_id": uuid.New().String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", g.apiURL+"/prompt", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("comfyui api call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("comfyui api returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		PromptID string `json:"prompt_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.PromptID == "" {
+		return "", fmt.Errorf("no prompt_id in response")
+	}
+	return result.PromptID, nil
+}
+
+// waitForImage 轮询执行记录直到输出第一张图像
+func (g *ComfyUIGenerator) waitForImage(ctx context.Context, promptID string) (comfyUIImage, error) {
+	ticker := time.NewTicker(comfyUIPollInterval)
+	defer ticker.Stop()
+
+	for {
+		history, err := g.history(ctx, promptID)
+		if err != nil {
+			return comfyUIImage{}, err
+		}
+
+		if history != nil {
+			if history.Status.StatusStr == "error" {
+				return comfyUIImage{}, fmt.Errorf("comfyui workflow failed")
+			}
+			for _, output := range history.Outputs {
+				if len(output.Images) > 0 {
+					return output.Images[0], nil
+				}
+			}
+			if history.Status.Completed {
+				return comfyUIImage{}, fmt.Errorf("comfyui workflow produced no image")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return comfyUIImage{}, fmt.Errorf("waiting for comfyui: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// history 查询执行记录,尚未执行完成时返回nil
+func (g *ComfyUIGenerator) history(ctx context.Context, promptID string) (*comfyUIHistory, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", g.apiURL+"/history/"+url.PathEscape(promptID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comfyui history: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("comfyui history returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result map[string]comfyUIHistory
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode history: %w", err)
+	}
+
+	history, ok := result[promptID]
+	if !ok {
+		return nil, nil
+	}
+	return &history, nil
+}
+
+// download 下载输出图像
+func (g *ComfyUIGenerator) download(ctx context.Context, image comfyUIImage) ([]byte, error) {
+	query := url.Values{}
+	query.Set("filename", image.Filename)
+	query.Set("subfolder", image.Subfolder)
+	query.Set("type", image.Type)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", g.apiURL+"/view?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("comfyui view returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return data, nil
+}
+
+// CheckHealth 检查ComfyUI服务健康状态
+func (g *ComfyUIGenerator) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.apiURL+"/system_stats", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to ComfyUI: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ComfyUI health check failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// fillPlaceholders 复制工作流并替换占位符
+func fillPlaceholders(node interface{}, values map[string]interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			out[key] = fillPlaceholders(child, values)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = fillPlaceholders(child, values)
+		}
+		return out
+	case string:
+		if value, ok := values[v]; ok {
+			return value
+		}
+		for placeholder, value := range values {
+			if strings.Contains(v, placeholder) {
+				v = strings.ReplaceAll(v, placeholder, fmt.Sprint(value))
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,146 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFillPlaceholders(t *testing.T) {
+	workflow := map[string]interface{}{
+		"3": map[string]interface{}{
+			"inputs": map[string]interface{}{
+				"seed":    "{{seed}}",
+				"text":    "{{prompt}}, masterpiece",
+				"model":   []interface{}{"4", float64(0)},
+				"denoise": float64(1),
+			},
+		},
+	}
+	values := map[string]interface{}{
+		"{{prompt}}": "a cat",
+		"{{seed}}":   int64(42),
+	}
+
+	got := fillPlaceholders(workflow, values)
+	want := map[string]interface{}{
+		"3": map[string]interface{}{
+			"inputs": map[string]interface{}{
+				"seed":    int64(42),
+				"text":    "a cat, masterpiece",
+				"model":   []interface{}{"4", float64(0)},
+				"denoise": float64(1),
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fillPlaceholders() = %v, want %v", got, want)
+	}
+	if workflow["3"].(map[string]interface{})["inputs"].(map[string]interface{})["seed"] != "{{seed}}" {
+		t.Error("fillPlaceholders() modified the template")
+	}
+}
+
+// fakeComfyUI 模拟ComfyUI的提交、执行记录和下载接口
+func fakeComfyUI(t *testing.T, status string, submitted *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/prompt", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Prompt map[string]interface{} `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		*submitted = body.Prompt
+		json.NewEncoder(w).Encode(map[string]string{"prompt_id": "p1"})
+	})
+	mux.HandleFunc("/history/p1", func(w http.ResponseWriter, r *http.Request) {
+		history := map[string]interface{}{
+			"status":  map[string]interface{}{"status_str": status, "completed": true},
+			"outputs": map[string]interface{}{},
+		}
+		if status == "success" {
+			history["outputs"] = map[string]interface{}{
+				"9": map[string]interface{}{
+					"images": []map[string]string{{"filename": "comic_0001.png", "subfolder": "", "type": "output"}},
+				},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"p1": history})
+	})
+	mux.HandleFunc("/view", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filename") != "comic_0001.png" || r.URL.Query().Get("type") != "output" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("png"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestComfyUIGenerator(t *testing.T) {
+	workflow := filepath.Join("..", "..", "configs", "comfyui", "txt2img.json")
+
+	tests := []struct {
+		name    string
+		status  string
+		wantErr bool
+	}{
+		{name: "success", status: "success"},
+		{name: "workflow error", status: "error", wantErr: true},
+		{name: "no image", status: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var submitted map[string]interface{}
+			server := fakeComfyUI(t, tt.status, &submitted)
+
+			g, err := NewComfyUIGenerator(server.URL+"/", workflow, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := Request{Prompt: "a cat", NegativePrompt: "blurry", Width: 512, Height: 768, Seed: 42}
+			image, err := g.Generate(context.Background(), req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(image) != "png" {
+				t.Errorf("image = %q", image)
+			}
+
+			sampler := submitted["3"].(map[string]interface{})["inputs"].(map[string]interface{})
+			if sampler["seed"] != float64(42) || sampler["steps"] != float64(30) || sampler["cfg"] != 7.5 {
+				t.Errorf("sampler inputs = %v", sampler)
+			}
+			latent := submitted["5"].(map[string]interface{})["inputs"].(map[string]interface{})
+			if latent["width"] != float64(512) || latent["height"] != float64(768) {
+				t.Errorf("latent inputs = %v", latent)
+			}
+			positive := submitted["6"].(map[string]interface{})["inputs"].(map[string]interface{})
+			if positive["text"] != "a cat" {
+				t.Errorf("positive prompt = %v", positive["text"])
+			}
+		})
+	}
+}
+
+func TestNewComfyUIGeneratorInvalidWorkflow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflow.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewComfyUIGenerator("http://localhost:8188", path, 5); err == nil {
+		t.Error("NewComfyUIGenerator() accepted an invalid workflow")
+	}
+}
@@ -0,0 +1,55 @@
+package imagegen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Jancd/1504/pkg/config"
+)
+
+// Request 镜头图像生成请求
+type Request struct {
+	Prompt         string
+	NegativePrompt string
+	Description    string // 镜头描述,占位生成器绘制在画面上
+	Width          int
+	Height         int
+	Steps          int     // 0表示使用生成器默认值
+	CFGScale       float64 // 0表示使用生成器默认值
+	Sampler        string  // 为空表示使用生成器默认值
+	Seed           int64   // -1表示随机
+	ReferenceImage []byte  // 角色参考图,不支持的生成器忽略
+}
+
+// Generator 图像生成后端
+type Generator interface {
+	// Generate 生成一张图像,返回PNG数据
+	Generate(ctx context.Context, req Request) ([]byte, error)
+	// CheckHealth 检查后端是否可用
+	CheckHealth(ctx context.Context) error
+}
+
+// New 按配置创建图像生成后端
+// OpenAI兼容后端未单独配置密钥和地址时沿用 openai 配置
+func New(cfg config.LocalSDConfig, openaiCfg config.OpenAIConfig) (Generator, error) {
+	switch cfg.Backend {
+	case "a1111":
+		return NewA1111Generator(cfg.APIURL, cfg.Timeout), nil
+	case "comfyui":
+		return NewComfyUIGenerator(cfg.ComfyUI.APIURL, cfg.ComfyUI.Workflow, cfg.ComfyUI.Timeout)
+	case "openai":
+		apiKey := cfg.OpenAI.APIKey
+		if apiKey == "" {
+			apiKey = openaiCfg.APIKey
+		}
+		baseURL := cfg.OpenAI.BaseURL
+		if baseURL == "" {
+			baseURL = openaiCfg.BaseURL
+		}
+		return NewOpenAIGenerator(apiKey, baseURL, cfg.OpenAI.Model, cfg.OpenAI.Sizes, cfg.OpenAI.Timeout), nil
+	case "placeholder":
+		return NewPlaceholderGenerator(), nil
+	default:
+		return nil, fmt.Errorf("unknown image backend: %s", cfg.Backend)
+	}
+}
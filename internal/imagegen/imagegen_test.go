@@ -0,0 +1,130 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jancd/1504/internal/client"
+	"github.com/Jancd/1504/pkg/config"
+	"github.com/Jancd/1504/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestNew(t *testing.T) {
+	workflow := filepath.Join("..", "..", "configs", "comfyui", "txt2img.json")
+
+	tests := []struct {
+		name     string
+		backend  string
+		workflow string
+		want     Generator
+		wantErr  bool
+	}{
+		{name: "a1111", backend: "a1111", want: &A1111Generator{}},
+		{name: "comfyui", backend: "comfyui", workflow: workflow, want: &ComfyUIGenerator{}},
+		{name: "comfyui missing workflow", backend: "comfyui", workflow: "missing.json", wantErr: true},
+		{name: "openai", backend: "openai", want: &OpenAIGenerator{}},
+		{name: "placeholder", backend: "placeholder", want: &PlaceholderGenerator{}},
+		{name: "unknown", backend: "midjourney", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.LocalSDConfig{Backend: tt.backend, APIURL: "http://localhost:7860"}
+			cfg.ComfyUI.Workflow = tt.workflow
+
+			got, err := New(cfg, config.OpenAIConfig{APIKey: "key"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotType, wantType := typeName(got), typeName(tt.want); gotType != wantType {
+				t.Errorf("New() = %s, want %s", gotType, wantType)
+			}
+		})
+	}
+}
+
+// typeName 生成器的具体类型名
+func typeName(g Generator) string {
+	switch g.(type) {
+	case *A1111Generator:
+		return "a1111"
+	case *ComfyUIGenerator:
+		return "comfyui"
+	case *OpenAIGenerator:
+		return "openai"
+	case *PlaceholderGenerator:
+		return "placeholder"
+	default:
+		return "unknown"
+	}
+}
+
+func TestA1111Generator(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference []byte
+	}{
+		{name: "text only"},
+		{name: "reference image", reference: []byte("ref")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got client.Txt2ImgRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/sdapi/v1/txt2img" {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+				json.NewEncoder(w).Encode(client.Txt2ImgResponse{
+					Images: []string{base64.StdEncoding.EncodeToString([]byte("png"))},
+				})
+			}))
+			defer server.Close()
+
+			req := Request{
+				Prompt:         "a cat",
+				NegativePrompt: "blurry",
+				Width:          512,
+				Height:         768,
+				Steps:          20,
+				CFGScale:       6,
+				Sampler:        "Euler a",
+				Seed:           42,
+				ReferenceImage: tt.reference,
+			}
+			image, err := NewA1111Generator(server.URL, 5).Generate(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(image) != "png" {
+				t.Errorf("image = %q", image)
+			}
+
+			if got.Prompt != "a cat" || got.NegativePrompt != "blurry" || got.Width != 512 || got.Height != 768 ||
+				got.Steps != 20 || got.CFGScale != 6 || got.SamplerName != "Euler a" || got.Seed != 42 {
+				t.Errorf("txt2img request = %+v", got)
+			}
+			if _, ok := got.AlwaysOnScripts["controlnet"]; ok != (tt.reference != nil) {
+				t.Errorf("controlnet present = %v, want %v", ok, tt.reference != nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// OpenAIGenerator OpenAI兼容的 /v1/images/generations 图像生成
+type OpenAIGenerator struct {
+	client  *openai.Client
+	model   string
+	sizes   []string
+	timeout time.Duration
+}
+
+// NewOpenAIGenerator 创建OpenAI兼容图像生成后端
+// sizes 为接口支持的尺寸列表(如 1024x1024),生成时选择比例最接近的尺寸;为空时直接使用请求尺寸
+func NewOpenAIGenerator(apiKey, baseURL, modelName string, sizes []string, timeout int) *OpenAIGenerator {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &OpenAIGenerator{
+		client:  openai.NewClientWithConfig(config),
+		model:   modelName,
+		sizes:   sizes,
+		timeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+// Generate 生成图像
+// 接口不支持负面Prompt、种子和参考图,负面Prompt以文字形式附加到Prompt
+func (g *OpenAIGenerator) Generate(ctx context.Context, req Request) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	prompt := req.Prompt
+	if req.NegativePrompt != "" {
+		prompt += "\nAvoid: " + req.NegativePrompt
+	}
+
+	imageReq := openai.ImageRequest{
+		Prompt: prompt,
+		Model:  g.model,
+		N:      1,
+		Size:   g.size(req.Width, req.Height),
+	}
+	// gpt-image系列只返回base64,不接受 response_format 参数
+	if !strings.HasPrefix(g.model, "gpt-image") {
+		imageReq.ResponseFormat = openai.CreateImageResponseFormatB64JSON
+	}
+
+	logger.Info("Generating image with OpenAI images API",
+		zap.String("model", g.model),
+		zap.String("size", imageReq.Size),
+		zap.String("prompt", req.Prompt))
+
+	startTime := time.Now()
+	resp, err := g.client.CreateImage(ctx, imageReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai images api call failed: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no image generated")
+	}
+
+	var imageData []byte
+	if data := resp.Data[0]; data.B64JSON != "" {
+		imageData, err = base64.StdEncoding.DecodeString(data.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 image: %w", err)
+		}
+	} else if data.URL != "" {
+		imageData, err = downloadImage(ctx, data.URL)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("no image data in response")
+	}
+
+	logger.Info("Image generated successfully",
+		zap.Duration("duration", time.Since(startTime)),
+		zap.Int("image_size", len(imageData)))
+
+	return imageData, nil
+}
+
+// CheckHealth 检查接口是否可访问
+func (g *OpenAIGenerator) CheckHealth(ctx context.Context) error {
+	if _, err := g.client.ListModels(ctx); err != nil {
+		return fmt.Errorf("failed to connect to OpenAI images API: %w", err)
+	}
+	return nil
+}
+
+// size 选择与请求比例最接近的支持尺寸
+func (g *OpenAIGenerator) size(width, height int) string {
+	if len(g.sizes) == 0 {
+		return fmt.Sprintf("%dx%d", width, height)
+	}
+
+	target := float64(width) / float64(height)
+	best, bestDiff := g.sizes[0], math.Inf(1)
+	for _, size := range g.sizes {
+		var w, h int
+		if _, err := fmt.Sscanf(size, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+			continue
+		}
+		if diff := math.Abs(math.Log(float64(w) / float64(h) / target)); diff < bestDiff {
+			best, bestDiff = size, diff
+		}
+	}
+	return best
+}
+
+// downloadImage 下载接口返回的图像URL
+func downloadImage(ctx context.Context, imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return data, nil
+}
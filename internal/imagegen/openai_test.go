@@ -0,0 +1,93 @@
+package imagegen
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIGeneratorSize(t *testing.T) {
+	sizes := []string{"1024x1024", "1792x1024", "1024x1792", "bogus"}
+
+	tests := []struct {
+		name          string
+		sizes         []string
+		width, height int
+		want          string
+	}{
+		{name: "landscape", sizes: sizes, width: 1920, height: 1080, want: "1792x1024"},
+		{name: "portrait", sizes: sizes, width: 1080, height: 1920, want: "1024x1792"},
+		{name: "square", sizes: sizes, width: 800, height: 800, want: "1024x1024"},
+		{name: "no sizes configured", width: 640, height: 360, want: "640x360"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewOpenAIGenerator("key", "", "dall-e-3", tt.sizes, 5)
+			if got := g.size(tt.width, tt.height); got != tt.want {
+				t.Errorf("size(%d, %d) = %q, want %q", tt.width, tt.height, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIGenerator(t *testing.T) {
+	tests := []struct {
+		name           string
+		model          string
+		byURL          bool
+		responseFormat string
+	}{
+		{name: "base64 response", model: "dall-e-3", responseFormat: "b64_json"},
+		{name: "gpt-image omits response format", model: "gpt-image-1"},
+		{name: "url response", model: "dall-e-3", byURL: true, responseFormat: "b64_json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/images/generations":
+					json.NewDecoder(r.Body).Decode(&got)
+					item := map[string]string{"b64_json": base64.StdEncoding.EncodeToString([]byte("png"))}
+					if tt.byURL {
+						item = map[string]string{"url": server.URL + "/files/image.png"}
+					}
+					json.NewEncoder(w).Encode(map[string]interface{}{"created": 1, "data": []interface{}{item}})
+				case "/files/image.png":
+					w.Write([]byte("png"))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			g := NewOpenAIGenerator("key", server.URL, tt.model, []string{"1024x1024"}, 5)
+			image, err := g.Generate(context.Background(), Request{Prompt: "a cat", NegativePrompt: "blurry", Width: 512, Height: 512, Seed: 42})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(image) != "png" {
+				t.Errorf("image = %q", image)
+			}
+
+			prompt, _ := got["prompt"].(string)
+			if !strings.HasPrefix(prompt, "a cat") || !strings.Contains(prompt, "Avoid: blurry") {
+				t.Errorf("prompt = %q", prompt)
+			}
+			if got["model"] != tt.model || got["size"] != "1024x1024" {
+				t.Errorf("request = %v", got)
+			}
+			format, _ := got["response_format"].(string)
+			if format != tt.responseFormat {
+				t.Errorf("response_format = %q, want %q", format, tt.responseFormat)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// PlaceholderGenerator 占位图像生成
+// 在纯色画布上绘制镜头描述,相同请求总是生成相同图像,用于无GPU环境跑通完整流程
+type PlaceholderGenerator struct{}
+
+// NewPlaceholderGenerator 创建占位图像生成后端
+func NewPlaceholderGenerator() *PlaceholderGenerator {
+	return &PlaceholderGenerator{}
+}
+
+// Generate 生成占位图像
+func (g *PlaceholderGenerator) Generate(ctx context.Context, req Request) ([]byte, error) {
+	if req.Width <= 0 || req.Height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", req.Width, req.Height)
+	}
+
+	text := req.Description
+	if text == "" {
+		text = req.Prompt
+	}
+
+	background, foreground := placeholderColors(text)
+	img := image.NewRGBA(image.Rect(0, 0, req.Width, req.Height))
+	fill(img, img.Bounds(), background)
+
+	// 边框
+	border := max(2, min(req.Width, req.Height)/100)
+	inner := img.Bounds().Inset(border * 4)
+	fill(img, inner, foreground)
+	fill(img, inner.Inset(border), background)
+
+	drawText(img, inner.Inset(border*4), text, foreground)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode placeholder image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// CheckHealth 占位生成器始终可用
+func (g *PlaceholderGenerator) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// placeholderColors 由文本摘要确定背景色,前景色按背景亮度选择黑或白
+func placeholderColors(text string) (color.RGBA, color.RGBA) {
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	sum := h.Sum32()
+
+	background := color.RGBA{
+		R: uint8(64 + sum&0x7F),
+		G: uint8(64 + (sum>>8)&0x7F),
+		B: uint8(64 + (sum>>16)&0x7F),
+		A: 0xFF,
+	}
+
+	luminance := 0.299*float64(background.R) + 0.587*float64(background.G) + 0.114*float64(background.B)
+	if luminance > 128 {
+		return background, color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xFF}
+	}
+	return background, color.RGBA{R: 0xF0, G: 0xF0, B: 0xF0, A: 0xFF}
+}
+
+// drawText 在区域内按单词换行并居中绘制文本,超出区域的行被省略
+func drawText(img *image.RGBA, area image.Rectangle, text string, c color.RGBA) {
+	scale := max(1, min(area.Dx(), area.Dy())/100)
+	advance := (glyphWidth + 1) * scale
+	lineHeight := (glyphHeight + 2) * scale
+
+	perLine := area.Dx() / advance
+	maxLines := area.Dy() / lineHeight
+	if perLine <= 0 || maxLines <= 0 {
+		return
+	}
+
+	lines := wrapText(text, perLine)
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+
+	y := area.Min.Y + (area.Dy()-len(lines)*lineHeight)/2
+	for _, line := range lines {
+		runes := []rune(line)
+		x := area.Min.X + (area.Dx()-len(runes)*advance)/2
+		for _, r := range runes {
+			drawGlyph(img, x, y, glyph(r), scale, c)
+			x += advance
+		}
+		y += lineHeight
+	}
+}
+
+// drawGlyph 按比例放大绘制单个字形
+func drawGlyph(img *image.RGBA, x, y int, columns [glyphWidth]byte, scale int, c color.RGBA) {
+	for col, bits := range columns {
+		for row := 0; row < glyphHeight; row++ {
+			if bits&(1<<row) == 0 {
+				continue
+			}
+			px := x + col*scale
+			py := y + row*scale
+			fill(img, image.Rect(px, py, px+scale, py+scale), c)
+		}
+	}
+}
+
+// wrapText 按单词换行,超长单词(或无空格的中文)按字符截断
+func wrapText(text string, perLine int) []string {
+	var lines []string
+	var current []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > perLine {
+			if len(current) > 0 {
+				lines = append(lines, string(current))
+				current = nil
+			}
+			lines = append(lines, string(runes[:perLine]))
+			runes = runes[perLine:]
+		}
+
+		switch {
+		case len(current) == 0:
+			current = runes
+		case len(current)+1+len(runes) <= perLine:
+			current = append(append(current, ' '), runes...)
+		default:
+			lines = append(lines, string(current))
+			current = runes
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, string(current))
+	}
+	return lines
+}
+
+// fill 填充矩形区域
+func fill(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package imagegen
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestPlaceholderGenerator(t *testing.T) {
+	g := NewPlaceholderGenerator()
+
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{name: "landscape", req: Request{Description: "A hero stands at the gate", Width: 320, Height: 180}},
+		{name: "portrait uses prompt", req: Request{Prompt: "雨夜的街道", Width: 90, Height: 160}},
+		{name: "tiny canvas", req: Request{Description: "x", Width: 8, Height: 8}},
+		{name: "invalid size", req: Request{Description: "x", Width: 0, Height: 100}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := g.Generate(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("output is not a PNG: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.req.Width || b.Dy() != tt.req.Height {
+				t.Errorf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.req.Width, tt.req.Height)
+			}
+
+			again, err := g.Generate(context.Background(), tt.req)
+			if err != nil || !bytes.Equal(data, again) {
+				t.Error("same request produced a different image")
+			}
+		})
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		perLine int
+		want    []string
+	}{
+		{name: "fits", text: "a b c", perLine: 10, want: []string{"a b c"}},
+		{name: "wraps on words", text: "hello brave new world", perLine: 11, want: []string{"hello brave", "new world"}},
+		{name: "splits long words", text: "abcdefgh ij", perLine: 3, want: []string{"abc", "def", "gh", "ij"}},
+		{name: "cjk by rune", text: "雨夜的街道", perLine: 2, want: []string{"雨夜", "的街", "道"}},
+		{name: "empty", text: "  ", perLine: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapText(tt.text, tt.perLine); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// anthropicVersion Messages API 版本
+const anthropicVersion = "2023-06-01"
+
+// AnthropicProvider Anthropic风格的 /v1/messages 接口
+// 接口没有JSON模式,需要JSON时预填助手回复的开头 "{" 引导模型直接输出JSON对象
+type AnthropicProvider struct {
+	apiKey    string
+	baseURL   string
+	model     string
+	maxTokens int
+	client    *http.Client
+}
+
+// NewAnthropicProvider 创建Anthropic风格服务
+func NewAnthropicProvider(apiKey, baseURL, modelName string, maxTokens, timeout int) *AnthropicProvider {
+	return &AnthropicProvider{
+		apiKey:    apiKey,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		model:     modelName,
+		maxTokens: maxTokens,
+		client: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+	}
+}
+
+// anthropicMessage 对话消息
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicRequest 消息请求
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float32            `json:"temperature"`
+}
+
+// anthropicResponse 消息响应
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Name 服务和模型名称
+func (p *AnthropicProvider) Name() string {
+	return "anthropic/" + p.model
+}
+
+// Complete 调用消息接口
+func (p *AnthropicProvider) Complete(ctx context.Context, req Request) (string, error) {
+	var messages []anthropicMessage
+	for _, message := range req.messages() {
+		messages = append(messages, anthropicMessage{Role: message.Role, Content: message.Content})
+	}
+	prefill := ""
+	if req.JSON {
+		prefill = "{"
+		messages = append(messages, anthropicMessage{Role: RoleAssistant, Content: prefill})
+	}
+
+	jsonData, err := json.Marshal(anthropicRequest{
+		Model:       p.model,
+		MaxTokens:   p.maxTokens,
+		System:      req.System,
+		Messages:    messages,
+		Temperature: req.Temperature,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("anthropic api call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("anthropic api returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var content strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	record(ctx, req, content.String(), result.Usage.InputTokens, result.Usage.OutputTokens)
+	if content.Len() == 0 {
+		return "", fmt.Errorf("no response from anthropic")
+	}
+	if result.StopReason == "max_tokens" {
+		return "", fmt.Errorf("anthropic response truncated at max_tokens (%d)", p.maxTokens)
+	}
+
+	if req.JSON {
+		return ExtractJSON(prefill + content.String()), nil
+	}
+	return content.String(), nil
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       bool
+		status     int
+		text       string
+		stopReason string
+		want       string
+		wantErr    bool
+	}{
+		{name: "text", text: "hello", stopReason: "end_turn", want: "hello"},
+		{name: "json prefill", json: true, text: `"a":1}` + "\nthanks", stopReason: "end_turn", want: `{"a":1}`},
+		{name: "truncated", text: "partial", stopReason: "max_tokens", wantErr: true},
+		{name: "empty content", stopReason: "end_turn", wantErr: true},
+		{name: "api error", status: http.StatusTooManyRequests, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got anthropicRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") != anthropicVersion {
+					http.Error(w, "bad request", http.StatusBadRequest)
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+				if tt.status != 0 {
+					http.Error(w, `{"type":"error"}`, tt.status)
+					return
+				}
+
+				resp := map[string]interface{}{"stop_reason": tt.stopReason, "content": []interface{}{}}
+				if tt.text != "" {
+					resp["content"] = []map[string]string{{"type": "text", "text": tt.text}}
+				}
+				json.NewEncoder(w).Encode(resp)
+			}))
+			defer server.Close()
+
+			p := NewAnthropicProvider("key", server.URL+"/", "claude-test", 1024, 5)
+			content, err := p.Complete(context.Background(), Request{System: "sys", Prompt: "hi", Temperature: 0.5, JSON: tt.json})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if content != tt.want {
+				t.Errorf("Complete() = %q, want %q", content, tt.want)
+			}
+
+			if got.Model != "claude-test" || got.MaxTokens != 1024 || got.System != "sys" || got.Temperature != 0.5 {
+				t.Errorf("request = %+v", got)
+			}
+			wantMessages := 1
+			if tt.json {
+				wantMessages = 2
+			}
+			if len(got.Messages) != wantMessages || got.Messages[0].Content != "hi" {
+				t.Errorf("messages = %+v", got.Messages)
+			}
+			if tt.json && (got.Messages[1].Role != "assistant" || got.Messages[1].Content != "{") {
+				t.Errorf("prefill = %+v", got.Messages[1])
+			}
+		})
+	}
+}
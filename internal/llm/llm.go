@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Jancd/1504/pkg/config"
+)
+
+// 对话角色
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message 对话消息
+type Message struct {
+	Role    string // user, assistant
+	Content string
+}
+
+// Request 对话请求
+type Request struct {
+	System      string
+	History     []Message // Prompt之前的对话记录,如纠错时先前的请求和回复
+	Prompt      string
+	Temperature float32
+	JSON        bool // 要求返回单个JSON对象
+}
+
+// messages 按顺序返回对话记录和本次Prompt
+func (r Request) messages() []Message {
+	messages := make([]Message, 0, len(r.History)+1)
+	messages = append(messages, r.History...)
+	return append(messages, Message{Role: RoleUser, Content: r.Prompt})
+}
+
+// Provider 大语言模型服务
+type Provider interface {
+	// Complete 返回模型回复文本;JSON为true时返回内容已去除markdown代码块标记
+	Complete(ctx context.Context, req Request) (string, error)
+	// Name 服务和模型名称,用于日志
+	Name() string
+}
+
+// New 按步骤配置创建大语言模型服务
+// 步骤未指定模型时使用对应服务配置中的默认模型
+func New(step config.LLMStepConfig, cfg config.LLMConfig, openaiCfg config.OpenAIConfig) (Provider, error) {
+	switch step.Provider {
+	case "openai":
+		return NewOpenAIProvider(openaiCfg.APIKey, openaiCfg.BaseURL, modelOr(step.Model, openaiCfg.Model), openaiCfg.JSONMode, openaiCfg.Timeout), nil
+	case "anthropic":
+		return NewAnthropicProvider(cfg.Anthropic.APIKey, cfg.Anthropic.BaseURL, modelOr(step.Model, cfg.Anthropic.Model), cfg.Anthropic.MaxTokens, cfg.Anthropic.Timeout), nil
+	case "ollama":
+		return NewOllamaProvider(cfg.Ollama.BaseURL, modelOr(step.Model, cfg.Ollama.Model), cfg.Ollama.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider: %s", step.Provider)
+	}
+}
+
+// ExtractJSON 提取回复中的JSON对象,兼容模型添加的markdown代码块和前后说明文字
+func ExtractJSON(content string) string {
+	content = strings.TrimSpace(content)
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
+
+// modelOr 步骤模型为空时使用默认模型
+func modelOr(model, fallback string) string {
+	if model != "" {
+		return model
+	}
+	return fallback
+}
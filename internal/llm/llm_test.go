@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/Jancd/1504/pkg/config"
+)
+
+func TestNew(t *testing.T) {
+	cfg := config.LLMConfig{}
+	cfg.Anthropic.Model = "claude-default"
+	cfg.Ollama.Model = "qwen2.5"
+	openaiCfg := config.OpenAIConfig{APIKey: "key", Model: "gpt-4o-mini"}
+
+	tests := []struct {
+		name    string
+		step    config.LLMStepConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "openai default model", step: config.LLMStepConfig{Provider: "openai"}, want: "openai/gpt-4o-mini"},
+		{name: "openai step model", step: config.LLMStepConfig{Provider: "openai", Model: "gpt-4o"}, want: "openai/gpt-4o"},
+		{name: "anthropic default model", step: config.LLMStepConfig{Provider: "anthropic"}, want: "anthropic/claude-default"},
+		{name: "ollama step model", step: config.LLMStepConfig{Provider: "ollama", Model: "llama3"}, want: "ollama/llama3"},
+		{name: "unknown", step: config.LLMStepConfig{Provider: "gemini"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := New(tt.step, cfg, openaiCfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && provider.Name() != tt.want {
+				t.Errorf("Name() = %q, want %q", provider.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "plain object", content: `{"a":1}`, want: `{"a":1}`},
+		{name: "markdown fence", content: "```json\n{\"a\":1}\n```", want: `{"a":1}`},
+		{name: "surrounding text", content: "Here it is:\n{\"a\":{\"b\":2}}\nDone.", want: `{"a":{"b":2}}`},
+		{name: "no object", content: "  sorry  ", want: "sorry"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractJSON(tt.content); got != tt.want {
+				t.Errorf("ExtractJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaProvider 本地Ollama的 /api/chat 接口
+// 需要JSON时使用 format: "json" 约束输出
+type OllamaProvider struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// NewOllamaProvider 创建Ollama服务
+func NewOllamaProvider(baseURL, modelName string, timeout int) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   modelName,
+		client: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+	}
+}
+
+// ollamaMessage 对话消息
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaRequest 对话请求
+type ollamaRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Format   string                 `json:"format,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// ollamaResponse 对话响应
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Error           string        `json:"error"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// Name 服务和模型名称
+func (p *OllamaProvider) Name() string {
+	return "ollama/" + p.model
+}
+
+// Complete 调用对话接口
+func (p *OllamaProvider) Complete(ctx context.Context, req Request) (string, error) {
+	messages := []ollamaMessage{
+		{Role: "system", Content: req.System},
+	}
+	for _, message := range req.messages() {
+		messages = append(messages, ollamaMessage{Role: message.Role, Content: message.Content})
+	}
+
+	chatReq := ollamaRequest{
+		Model:    p.model,
+		Messages: messages,
+		Stream:   false,
+		Options: map[string]interface{}{
+			"temperature": req.Temperature,
+		},
+	}
+	if req.JSON {
+		chatReq.Format = "json"
+	}
+
+	jsonData, err := json.Marshal(chatReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("ollama api call failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama api returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("ollama returned error: %s", result.Error)
+	}
+	record(ctx, req, result.Message.Content, result.PromptEvalCount, result.EvalCount)
+	if result.Message.Content == "" {
+		return "", fmt.Errorf("no response from ollama")
+	}
+
+	if req.JSON {
+		return ExtractJSON(result.Message.Content), nil
+	}
+	return result.Message.Content, nil
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    bool
+		status  int
+		reply   ollamaResponse
+		want    string
+		wantErr bool
+	}{
+		{name: "text", reply: ollamaResponse{Message: ollamaMessage{Role: "assistant", Content: "hello"}}, want: "hello"},
+		{name: "json format", json: true, reply: ollamaResponse{Message: ollamaMessage{Content: "```json\n{\"a\":1}\n```"}}, want: `{"a":1}`},
+		{name: "model error", reply: ollamaResponse{Error: "model not found"}, wantErr: true},
+		{name: "empty reply", wantErr: true},
+		{name: "api error", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ollamaRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/chat" {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+				if tt.status != 0 {
+					http.Error(w, "boom", tt.status)
+					return
+				}
+				json.NewEncoder(w).Encode(tt.reply)
+			}))
+			defer server.Close()
+
+			p := NewOllamaProvider(server.URL+"/", "qwen2.5", 5)
+			content, err := p.Complete(context.Background(), Request{System: "sys", Prompt: "hi", Temperature: 0.3, JSON: tt.json})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if content != tt.want {
+				t.Errorf("Complete() = %q, want %q", content, tt.want)
+			}
+
+			if got.Model != "qwen2.5" || got.Stream || len(got.Messages) != 2 || got.Messages[0].Content != "sys" || got.Messages[1].Content != "hi" {
+				t.Errorf("request = %+v", got)
+			}
+			wantFormat := ""
+			if tt.json {
+				wantFormat = "json"
+			}
+			if got.Format != wantFormat {
+				t.Errorf("format = %q, want %q", got.Format, wantFormat)
+			}
+		})
+	}
+}
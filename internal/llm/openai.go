@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// OpenAIProvider OpenAI兼容的 /chat/completions 接口
+type OpenAIProvider struct {
+	client   *openai.Client
+	model    string
+	jsonMode bool
+	timeout  time.Duration
+}
+
+// NewOpenAIProvider 创建OpenAI兼容服务
+// jsonMode 为false时不发送 response_format,用于不支持JSON模式的兼容服务
+func NewOpenAIProvider(apiKey, baseURL, modelName string, jsonMode bool, timeout int) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &OpenAIProvider{
+		client:   openai.NewClientWithConfig(config),
+		model:    modelName,
+		jsonMode: jsonMode,
+		timeout:  time.Duration(timeout) * time.Second,
+	}
+}
+
+// Name 服务和模型名称
+func (p *OpenAIProvider) Name() string {
+	return "openai/" + p.model
+}
+
+// Complete 调用对话补全接口
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: req.System,
+		},
+	}
+	for _, message := range req.messages() {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+
+	chatReq := openai.ChatCompletionRequest{
+		Model:       p.model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+	}
+	if req.JSON && p.jsonMode {
+		chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+
+	resp, err := p.client.CreateChatCompletion(ctx, chatReq)
+	if err != nil {
+		return "", fmt.Errorf("openai api call failed: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from openai")
+	}
+
+	content := resp.Choices[0].Message.Content
+	record(ctx, req, content, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	if req.JSON {
+		content = ExtractJSON(content)
+	}
+	return content, nil
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       bool
+		jsonMode   bool
+		reply      string
+		noChoices  bool
+		want       string
+		wantFormat bool
+		wantErr    bool
+	}{
+		{name: "text", reply: "hello", want: "hello"},
+		{name: "json mode", json: true, jsonMode: true, reply: `{"a":1}`, want: `{"a":1}`, wantFormat: true},
+		{name: "json without json mode", json: true, reply: "```json\n{\"a\":1}\n```", want: `{"a":1}`},
+		{name: "no choices", noChoices: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/chat/completions" {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+
+				choices := []interface{}{
+					map[string]interface{}{
+						"index":         0,
+						"finish_reason": "stop",
+						"message":       map[string]string{"role": "assistant", "content": tt.reply},
+					},
+				}
+				if tt.noChoices {
+					choices = []interface{}{}
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"id": "1", "object": "chat.completion", "choices": choices})
+			}))
+			defer server.Close()
+
+			p := NewOpenAIProvider("key", server.URL, "gpt-test", tt.jsonMode, 5)
+			content, err := p.Complete(context.Background(), Request{System: "sys", Prompt: "hi", JSON: tt.json})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if content != tt.want {
+				t.Errorf("Complete() = %q, want %q", content, tt.want)
+			}
+
+			if got["model"] != "gpt-test" {
+				t.Errorf("model = %v", got["model"])
+			}
+			messages, _ := got["messages"].([]interface{})
+			if len(messages) != 2 {
+				t.Errorf("messages = %v", got["messages"])
+			}
+			if _, ok := got["response_format"]; ok != tt.wantFormat {
+				t.Errorf("response_format present = %v, want %v", ok, tt.wantFormat)
+			}
+		})
+	}
+}
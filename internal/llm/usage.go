@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+// Meter 统计一次操作中所有模型调用的token用量,可在并发调用中共享
+type Meter struct {
+	mu    sync.Mutex
+	usage model.TokenUsage
+}
+
+// meterKey ctx 中计量器的键
+type meterKey struct{}
+
+// WithMeter 返回带计量器的ctx,使用该ctx的模型调用都会计入用量
+func WithMeter(ctx context.Context) (context.Context, *Meter) {
+	meter := &Meter{}
+	return context.WithValue(ctx, meterKey{}, meter), meter
+}
+
+// Usage 当前累计用量
+func (m *Meter) Usage() model.TokenUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// record 将一次调用的用量计入ctx中的计量器
+// 服务未返回用量时按请求和回复的文本长度估算
+func record(ctx context.Context, req Request, content string, promptTokens, completionTokens int) {
+	meter, ok := ctx.Value(meterKey{}).(*Meter)
+	if !ok {
+		return
+	}
+
+	if promptTokens == 0 {
+		promptTokens = EstimateTokens(req.System)
+		for _, message := range req.messages() {
+			promptTokens += EstimateTokens(message.Content)
+		}
+	}
+	if completionTokens == 0 {
+		completionTokens = EstimateTokens(content)
+	}
+
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+	meter.usage.Add(model.TokenUsage{Calls: 1, PromptTokens: promptTokens, CompletionTokens: completionTokens})
+}
+
+// EstimateTokens 按文本长度粗略估算token数
+// 中文等非ASCII字符约每字1个token,ASCII字符约每4个1个token
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < 0x80 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return other + (ascii+3)/4
+}
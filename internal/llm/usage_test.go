@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "abcd", want: 1},
+		{text: "abcde", want: 2},
+		{text: "你好", want: 2},
+		{text: "你好 abc", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMeter(t *testing.T) {
+	req := Request{System: "abcd", Prompt: "你好"}
+
+	// 未挂载计量器时不记录
+	record(context.Background(), req, "ok", 10, 5)
+
+	ctx, meter := WithMeter(context.Background())
+	record(ctx, req, "ignored", 10, 5)
+	record(ctx, req, "你好", 0, 0)
+
+	want := model.TokenUsage{Calls: 2, PromptTokens: 10 + 3, CompletionTokens: 5 + 2}
+	if got := meter.Usage(); got != want {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// Plan 试运行计划
+// 只执行剧本解析和分镜生成,并估算正式生成的用量、耗时和费用;确认后可转为任务,转换时不再调用LLM解析和生成分镜
+type Plan struct {
+	ID         string        `json:"plan_id"`
+	Input      Input         `json:"input"`
+	Parsed     *ParsedScript `json:"parsed_script"`
+	Storyboard *Storyboard   `json:"storyboard"`
+	Estimate   PlanEstimate  `json:"estimate"`
+	TaskID     string        `json:"task_id,omitempty"` // 转为任务后的任务ID
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// PlanEstimate 生成用量、耗时和费用估算
+type PlanEstimate struct {
+	Mode          string     `json:"mode"`           // 视频生成方式: local_sd, hybrid, qiniu_single, qiniu_per_shot
+	PlanTokens    TokenUsage `json:"plan_tokens"`    // 试运行解析和分镜实际消耗的token
+	TaskTokens    TokenUsage `json:"task_tokens"`    // 转为任务后生成角色设定预计消耗的token
+	Images        int        `json:"images"`         // SD生成的图像数
+	Clips         int        `json:"clips"`          // 七牛云生成的视频片段数
+	VideoDuration float64    `json:"video_duration"` // 成片时长(秒)
+	RenderTime    int        `json:"render_time"`    // 转为任务后预计的执行耗时(秒),不含排队
+	PlanCost      float64    `json:"plan_cost"`      // 试运行已产生的费用
+	TaskCost      float64    `json:"task_cost"`      // 转为任务后预计的费用
+	Currency      string     `json:"currency"`
+}
+
+// TokenUsage 大语言模型token用量
+type TokenUsage struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Add 累加用量
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+}
@@ -0,0 +1,123 @@
+package model
+
+import "time"
+
+// Series 剧集,一次提交的长篇故事拆分为多集,每集为一个独立任务
+type Series struct {
+	ID         string          `json:"series_id"`
+	Status     string          `json:"status"`   // 由各集任务状态汇总,取值同任务状态
+	Progress   int             `json:"progress"` // 各集进度的平均值
+	Input      SeriesInput     `json:"input"`
+	Episodes   []SeriesEpisode `json:"episodes"`
+	Characters []Character     `json:"characters,omitempty"` // 各集共享的角色设定,先生成的分集提取的角色在后续分集中复用
+	Result     *Result         `json:"result,omitempty"`     // 合并后的完整视频,首次下载时生成
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// SeriesInput 剧集输入参数
+type SeriesInput struct {
+	Text        string      `json:"text" binding:"required"`
+	InputFormat string      `json:"input_format,omitempty"` // text(默认) 或 markdown
+	Episodes    int         `json:"episodes"`               // 集数,0表示按配置的每集字数估算
+	Options     Options     `json:"options"`                // 各集共用的生成选项,duration_target 为每集时长
+	Characters  []Character `json:"characters,omitempty"`
+}
+
+// SeriesEpisode 剧集中的一集
+type SeriesEpisode struct {
+	Number    int    `json:"number"`
+	TaskID    string `json:"task_id"`
+	Status    string `json:"status"`
+	Progress  int    `json:"progress"`
+	Submitted bool   `json:"submitted"` // 是否已提交到任务队列,上一集生成角色设定后才提交
+}
+
+// Episode 分集任务的剧集上下文
+type Episode struct {
+	SeriesID string `json:"series_id"`
+	Number   int    `json:"number"` // 集数,从1开始
+	Total    int    `json:"total"`
+	Recap    string `json:"recap,omitempty"` // 上一集结尾原文,用于开头的前情回顾镜头
+}
+
+// IsFinal 是否为最后一集
+func (e *Episode) IsFinal() bool {
+	return e.Number >= e.Total
+}
+
+// Aggregate 按各集任务的最新状态汇总剧集状态和进度,返回是否有变化
+// tasks 中缺失的分集视为失败
+func (s *Series) Aggregate(tasks map[string]*Task) bool {
+	counts := make(map[string]int)
+	total := 0
+	changed := false
+	for i := range s.Episodes {
+		episode := &s.Episodes[i]
+		status, progress := TaskStatusFailed, episode.Progress
+		if t, ok := tasks[episode.TaskID]; ok {
+			status, progress = t.Status, t.Progress
+		}
+		if status == TaskStatusInterrupted {
+			status = TaskStatusFailed
+		}
+		if episode.Status != status || episode.Progress != progress {
+			episode.Status, episode.Progress = status, progress
+			changed = true
+		}
+		counts[status]++
+		total += progress
+	}
+
+	var status string
+	active := counts[TaskStatusQueued] + counts[TaskStatusProcessing]
+	switch {
+	case len(s.Episodes) == 0:
+		status = TaskStatusFailed
+	case counts[TaskStatusQueued] == len(s.Episodes):
+		status = TaskStatusQueued
+	case active > 0:
+		status = TaskStatusProcessing
+	case counts[TaskStatusFailed] > 0:
+		status = TaskStatusFailed
+	case counts[TaskStatusCancelled] > 0:
+		status = TaskStatusCancelled
+	case counts[TaskStatusAwaitingReview] > 0:
+		status = TaskStatusAwaitingReview
+	default:
+		status = TaskStatusCompleted
+	}
+
+	progress := 0
+	if len(s.Episodes) > 0 {
+		progress = total / len(s.Episodes)
+	}
+	if status == TaskStatusCompleted {
+		progress = 100
+	}
+
+	if s.Status != status || s.Progress != progress {
+		s.Status, s.Progress = status, progress
+		changed = true
+	}
+	if status != TaskStatusCompleted && s.Result != nil {
+		s.Result = nil
+		changed = true
+	}
+	if changed {
+		s.UpdatedAt = time.Now()
+	}
+	return changed
+}
+
+// Snapshot 复制剧集当前状态,用于对外输出
+func (s *Series) Snapshot() *Series {
+	snapshot := *s
+	snapshot.Episodes = append([]SeriesEpisode(nil), s.Episodes...)
+	snapshot.Characters = append([]Character(nil), s.Characters...)
+	if s.Result != nil {
+		result := *s.Result
+		snapshot.Result = &result
+	}
+	return &snapshot
+}
@@ -0,0 +1,97 @@
+package model
+
+import "testing"
+
+func TestSeriesAggregate(t *testing.T) {
+	task := func(status string, progress int) *Task {
+		return &Task{Status: status, Progress: progress}
+	}
+
+	tests := []struct {
+		name     string
+		tasks    map[string]*Task
+		status   string
+		progress int
+	}{
+		{
+			name:   "all queued",
+			tasks:  map[string]*Task{"e1": task(TaskStatusQueued, 0), "e2": task(TaskStatusQueued, 0)},
+			status: TaskStatusQueued,
+		},
+		{
+			name:     "one running",
+			tasks:    map[string]*Task{"e1": task(TaskStatusCompleted, 100), "e2": task(TaskStatusProcessing, 40)},
+			status:   TaskStatusProcessing,
+			progress: 70,
+		},
+		{
+			name:     "failure after all stopped",
+			tasks:    map[string]*Task{"e1": task(TaskStatusCompleted, 100), "e2": task(TaskStatusInterrupted, 30)},
+			status:   TaskStatusFailed,
+			progress: 65,
+		},
+		{
+			name:     "missing episode counts as failed",
+			tasks:    map[string]*Task{"e1": task(TaskStatusCompleted, 100)},
+			status:   TaskStatusFailed,
+			progress: 50,
+		},
+		{
+			name:     "cancelled",
+			tasks:    map[string]*Task{"e1": task(TaskStatusCompleted, 100), "e2": task(TaskStatusCancelled, 20)},
+			status:   TaskStatusCancelled,
+			progress: 60,
+		},
+		{
+			name:     "awaiting review",
+			tasks:    map[string]*Task{"e1": task(TaskStatusAwaitingReview, 30), "e2": task(TaskStatusCompleted, 100)},
+			status:   TaskStatusAwaitingReview,
+			progress: 65,
+		},
+		{
+			name:     "all completed",
+			tasks:    map[string]*Task{"e1": task(TaskStatusCompleted, 100), "e2": task(TaskStatusCompleted, 99)},
+			status:   TaskStatusCompleted,
+			progress: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Series{
+				Episodes: []SeriesEpisode{{Number: 1, TaskID: "e1"}, {Number: 2, TaskID: "e2"}},
+				Result:   &Result{VideoPath: "series.mp4"},
+			}
+
+			if !s.Aggregate(tt.tasks) {
+				t.Error("Aggregate() reported no change")
+			}
+			if s.Status != tt.status || s.Progress != tt.progress {
+				t.Errorf("series = %s %d%%, want %s %d%%", s.Status, s.Progress, tt.status, tt.progress)
+			}
+			if (s.Result != nil) != (tt.status == TaskStatusCompleted) {
+				t.Errorf("result kept = %v for status %s", s.Result != nil, s.Status)
+			}
+			if s.Aggregate(tt.tasks) {
+				t.Error("second Aggregate() reported a change")
+			}
+		})
+	}
+}
+
+func TestEpisodeIsFinal(t *testing.T) {
+	tests := []struct {
+		episode Episode
+		want    bool
+	}{
+		{episode: Episode{Number: 1, Total: 3}, want: false},
+		{episode: Episode{Number: 3, Total: 3}, want: true},
+		{episode: Episode{Number: 1, Total: 1}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.episode.IsFinal(); got != tt.want {
+			t.Errorf("%+v IsFinal() = %v, want %v", tt.episode, got, tt.want)
+		}
+	}
+}
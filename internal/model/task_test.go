@@ -0,0 +1,42 @@
+package model
+
+import "testing"
+
+func TestResetUnfinishedSteps(t *testing.T) {
+	task := NewTask("t", Input{Text: "x"})
+	task.UpdateStep(StepParseScript, StepStatusCompleted)
+	task.UpdateStep(StepGenerateStoryboard, StepStatusFailed)
+	task.SetStepProgress(StepGenerateStoryboard, 40, "2/5")
+
+	task.ResetUnfinishedSteps()
+
+	tests := []struct {
+		step      string
+		status    string
+		completed bool
+	}{
+		{step: StepParseScript, status: StepStatusCompleted, completed: true},
+		{step: StepGenerateStoryboard, status: StepStatusPending},
+		{step: StepGenerateImages, status: StepStatusPending},
+		{step: StepRenderVideo, status: StepStatusPending},
+		{step: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.step, func(t *testing.T) {
+			if got := task.IsStepCompleted(tt.step); got != tt.completed {
+				t.Errorf("IsStepCompleted() = %v, want %v", got, tt.completed)
+			}
+			for _, step := range task.Steps {
+				if step.Name != tt.step {
+					continue
+				}
+				if step.Status != tt.status {
+					t.Errorf("status = %s, want %s", step.Status, tt.status)
+				}
+				if step.Status == StepStatusPending && (step.Progress != 0 || step.Current != "" || step.StartAt != nil) {
+					t.Errorf("reset step kept progress: %+v", step)
+				}
+			}
+		})
+	}
+}
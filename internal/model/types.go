@@ -1,44 +1,72 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Task 任务
 type Task struct {
-	ID          string    `json:"task_id"`
-	Status      string    `json:"status"` // queued, processing, completed, failed
-	Progress    int       `json:"progress"` // 0-100
-	CurrentStep string    `json:"current_step"`
-	Steps       []Step    `json:"steps"`
-	Input       Input     `json:"input"`
-	Result      *Result   `json:"result,omitempty"`
-	Error       string    `json:"error,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            string            `json:"task_id"`
+	Status        string            `json:"status"`                   // queued, processing, awaiting_review, completed, failed, cancelled, interrupted
+	Progress      int               `json:"progress"`                 // 0-100
+	QueuePosition int               `json:"queue_position,omitempty"` // 排队位置,从1开始
+	CurrentStep   string            `json:"current_step"`
+	Steps         []Step            `json:"steps"`
+	Input         Input             `json:"input"`
+	Result        *Result           `json:"result,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Callbacks     []CallbackAttempt `json:"callbacks,omitempty"` // 回调投递记录
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
 }
 
 // Step 处理步骤
 type Step struct {
-	Name     string  `json:"name"`
-	Status   string  `json:"status"` // pending, processing, completed, failed
-	Progress int     `json:"progress,omitempty"`
-	Current  string  `json:"current,omitempty"` // 当前进度描述
-	Duration float64 `json:"duration,omitempty"` // 耗时(秒)
+	Name     string     `json:"name"`
+	Status   string     `json:"status"` // pending, processing, completed, failed, cancelled
+	Progress int        `json:"progress,omitempty"`
+	Current  string     `json:"current,omitempty"`  // 当前进度描述
+	Duration float64    `json:"duration,omitempty"` // 耗时(秒)
 	StartAt  *time.Time `json:"start_at,omitempty"`
 	EndAt    *time.Time `json:"end_at,omitempty"`
 }
 
 // Input 输入参数
 type Input struct {
-	Text    string  `json:"text" binding:"required"`
-	Options Options `json:"options"`
+	Text           string      `json:"text" binding:"required"`
+	InputFormat    string      `json:"input_format,omitempty"` // text(默认), fountain, markdown, json
+	Options        Options     `json:"options"`
+	CallbackURL    string      `json:"callback_url,omitempty"`    // 任务完成或失败时POST通知的地址
+	CallbackSecret string      `json:"callback_secret,omitempty"` // 回调签名密钥,对外输出时隐藏
+	Characters     []Character `json:"characters,omitempty"`      // 预设角色外貌,覆盖自动提取的同名角色
+	Episode        *Episode    `json:"episode,omitempty"`         // 剧集中的分集信息,由 /api/series 创建的任务才有
+}
+
+// RegenerateShotRequest 单镜头重新生成请求
+type RegenerateShotRequest struct {
+	Prompt   string `json:"prompt"`   // 自定义Prompt,为空时使用原Prompt
+	Seed     int64  `json:"seed"`     // 图像生成种子,0表示沿用镜头原有的种子
+	Rerender bool   `json:"rerender"` // 是否使用更新后的分镜重新渲染视频
+}
+
+// CallbackAttempt 回调投递记录
+type CallbackAttempt struct {
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
 }
 
 // Options 生成选项
 type Options struct {
-	Style          string `json:"style"`
-	DurationTarget int    `json:"duration_target"`
-	AspectRatio    string `json:"aspect_ratio"`
-	BGM            string `json:"bgm"`
+	Style            string `json:"style"`
+	DurationTarget   int    `json:"duration_target"`
+	AspectRatio      string `json:"aspect_ratio"`
+	BGM              string `json:"bgm"`
+	ReviewStoryboard bool   `json:"review_storyboard"` // 生成分镜后暂停,等待人工审核
 }
 
 // Result 生成结果
@@ -89,15 +117,19 @@ type Storyboard struct {
 
 // Shot 镜头
 type Shot struct {
-	ID          int       `json:"id"`
-	Type        string    `json:"type"` // closeup, medium, long
-	Description string    `json:"description"`
-	Characters  []string  `json:"characters"`
-	Duration    float64   `json:"duration"`
-	Transition  string    `json:"transition"` // cut, fade, dissolve
-	Dialogue    *Dialogue `json:"dialogue,omitempty"`
-	ImagePath   string    `json:"image_path,omitempty"`
-	Prompt      string    `json:"prompt,omitempty"`
+	ID                 int       `json:"id"`
+	Type               string    `json:"type"` // closeup, medium, long
+	Description        string    `json:"description"`
+	Characters         []string  `json:"characters"`
+	Duration           float64   `json:"duration"`
+	Transition         string    `json:"transition"`                    // cut, fade, dissolve; 从上一镜头进入本镜头的转场
+	TransitionDuration float64   `json:"transition_duration,omitempty"` // 转场时长(秒),0使用默认值
+	Dialogue           *Dialogue `json:"dialogue,omitempty"`
+	ImagePath          string    `json:"image_path,omitempty"`
+	VideoPath          string    `json:"video_path,omitempty"` // 七牛云逐镜头模式生成的视频片段
+	Prompt             string    `json:"prompt,omitempty"`
+	Motion             string    `json:"motion,omitempty"` // 镜头运动预设,为空时按镜头类型选择
+	Seed               int64     `json:"seed,omitempty"`   // 图像生成种子,0表示随机
 }
 
 // Metadata 元数据
@@ -108,6 +140,94 @@ type Metadata struct {
 	WordCount         int     `json:"word_count"`
 }
 
+// Character 角色设定,外貌字段使用英文描述以便直接拼入绘图Prompt
+type Character struct {
+	Name           string `json:"name"`
+	Appearance     string `json:"appearance,omitempty"`      // 整体外貌(体型、五官等)
+	Hair           string `json:"hair,omitempty"`            // 发型发色
+	Clothing       string `json:"clothing,omitempty"`        // 服装
+	Age            string `json:"age,omitempty"`             // 年龄
+	Palette        string `json:"palette,omitempty"`         // 角色主色调
+	Seed           int64  `json:"seed,omitempty"`            // 该角色镜头的固定种子,0表示不固定
+	ReferenceImage string `json:"reference_image,omitempty"` // 参考图路径,SD模式下作为reference参考
+	Voice          string `json:"voice,omitempty"`           // 配音音色,为空时使用配置中的映射
+}
+
+// CharacterSheet 角色设定表,保存为 characters.json
+type CharacterSheet struct {
+	Characters []Character `json:"characters"`
+}
+
+// Find 按名称查找角色
+func (s *CharacterSheet) Find(name string) *Character {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Characters {
+		if s.Characters[i].Name == name {
+			return &s.Characters[i]
+		}
+	}
+	return nil
+}
+
+// Describe 生成镜头中出场角色的外貌描述
+func (s *CharacterSheet) Describe(names []string) string {
+	var parts []string
+	for _, name := range names {
+		c := s.Find(name)
+		if c == nil {
+			continue
+		}
+
+		var traits []string
+		for _, trait := range []string{c.Age, c.Appearance, c.Hair, c.Clothing, c.Palette} {
+			if trait != "" {
+				traits = append(traits, trait)
+			}
+		}
+		if len(traits) > 0 {
+			parts = append(parts, fmt.Sprintf("%s (%s)", c.Name, strings.Join(traits, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Primary 镜头中第一个出场且有设定的角色
+func (s *CharacterSheet) Primary(names []string) *Character {
+	for _, name := range names {
+		if c := s.Find(name); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// TaskEvent 任务事件,通过 /api/tasks/:task_id/events 推送
+type TaskEvent struct {
+	Type      string      `json:"type"` // task, step, shot, poll
+	TaskID    string      `json:"task_id"`
+	Step      string      `json:"step,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// ShotEvent 单个镜头完成事件
+type ShotEvent struct {
+	ShotID    int    `json:"shot_id"`
+	Current   int    `json:"current"`
+	Total     int    `json:"total"`
+	ImagePath string `json:"image_path,omitempty"`
+}
+
+// PollEvent 外部服务轮询状态事件
+type PollEvent struct {
+	RemoteID   string `json:"remote_id"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	CheckCount int    `json:"check_count"`
+}
+
 // APIResponse 统一API响应
 type APIResponse struct {
 	Code      int         `json:"code"`
@@ -120,18 +240,22 @@ type APIResponse struct {
 
 // StepName 步骤名称常量
 const (
-	StepParseScript       = "parse_script"
+	StepParseScript        = "parse_script"
+	StepGenerateCharacters = "generate_characters"
 	StepGenerateStoryboard = "generate_storyboard"
-	StepGenerateImages    = "generate_images"
-	StepRenderVideo       = "render_video"
+	StepGenerateImages     = "generate_images"
+	StepRenderVideo        = "render_video"
 )
 
 // TaskStatus 任务状态常量
 const (
-	TaskStatusQueued     = "queued"
-	TaskStatusProcessing = "processing"
-	TaskStatusCompleted  = "completed"
-	TaskStatusFailed     = "failed"
+	TaskStatusQueued         = "queued"
+	TaskStatusProcessing     = "processing"
+	TaskStatusCompleted      = "completed"
+	TaskStatusFailed         = "failed"
+	TaskStatusCancelled      = "cancelled"
+	TaskStatusAwaitingReview = "awaiting_review" // 分镜等待人工审核
+	TaskStatusInterrupted    = "interrupted"     // 服务重启时未完成
 )
 
 // StepStatus 步骤状态常量
@@ -140,6 +264,23 @@ const (
 	StepStatusProcessing = "processing"
 	StepStatusCompleted  = "completed"
 	StepStatusFailed     = "failed"
+	StepStatusCancelled  = "cancelled"
+)
+
+// TaskEventType 任务事件类型常量
+const (
+	TaskEventTask = "task" // 任务完整状态
+	TaskEventStep = "step" // 步骤状态变化
+	TaskEventShot = "shot" // 单个镜头完成
+	TaskEventPoll = "poll" // 外部服务轮询状态
+)
+
+// InputFormat 输入格式常量
+const (
+	InputFormatText     = "text"     // 小说等自由文本,由LLM解析
+	InputFormatFountain = "fountain" // Fountain格式剧本
+	InputFormatMarkdown = "markdown" // 约定格式的Markdown剧本
+	InputFormatJSON     = "json"     // 已结构化的 ParsedScript JSON,跳过LLM解析
 )
 
 // ShotType 镜头类型常量
@@ -156,6 +297,25 @@ const (
 	TransitionDissolve = "dissolve"
 )
 
+// AspectRatio 画面比例常量
+const (
+	AspectRatioLandscape = "16:9"
+	AspectRatioPortrait  = "9:16"
+	AspectRatioSquare    = "1:1"
+	AspectRatioClassic   = "4:3"
+)
+
+// Motion 镜头运动预设常量
+const (
+	MotionNone     = "none"
+	MotionZoomIn   = "zoom_in"
+	MotionZoomOut  = "zoom_out"
+	MotionPanLeft  = "pan_left"
+	MotionPanRight = "pan_right"
+	MotionTiltUp   = "tilt_up"
+	MotionTiltDown = "tilt_down"
+)
+
 // NewTask 创建新任务
 func NewTask(id string, input Input) *Task {
 	now := time.Now()
@@ -166,6 +326,7 @@ func NewTask(id string, input Input) *Task {
 		CurrentStep: "queued",
 		Steps: []Step{
 			{Name: StepParseScript, Status: StepStatusPending},
+			{Name: StepGenerateCharacters, Status: StepStatusPending},
 			{Name: StepGenerateStoryboard, Status: StepStatusPending},
 			{Name: StepGenerateImages, Status: StepStatusPending},
 			{Name: StepRenderVideo, Status: StepStatusPending},
@@ -184,7 +345,7 @@ func (t *Task) UpdateStep(stepName, status string) {
 			t.Steps[i].Status = status
 			if status == StepStatusProcessing {
 				t.Steps[i].StartAt = &now
-			} else if status == StepStatusCompleted || status == StepStatusFailed {
+			} else if status == StepStatusCompleted || status == StepStatusFailed || status == StepStatusCancelled {
 				t.Steps[i].EndAt = &now
 				if t.Steps[i].StartAt != nil {
 					t.Steps[i].Duration = now.Sub(*t.Steps[i].StartAt).Seconds()
@@ -208,3 +369,60 @@ func (t *Task) SetStepProgress(stepName string, progress int, current string) {
 	}
 	t.UpdatedAt = time.Now()
 }
+
+// IsStepCompleted 步骤是否已完成
+func (t *Task) IsStepCompleted(stepName string) bool {
+	for _, step := range t.Steps {
+		if step.Name == stepName {
+			return step.Status == StepStatusCompleted
+		}
+	}
+	return false
+}
+
+// ResetUnfinishedSteps 将未完成的步骤重置为待处理
+func (t *Task) ResetUnfinishedSteps() {
+	for i := range t.Steps {
+		if t.Steps[i].Status != StepStatusCompleted {
+			t.Steps[i] = Step{Name: t.Steps[i].Name, Status: StepStatusPending}
+		}
+	}
+	t.UpdatedAt = time.Now()
+}
+
+// ResetSteps 将指定步骤重置为待处理
+func (t *Task) ResetSteps(stepNames ...string) {
+	for i := range t.Steps {
+		for _, name := range stepNames {
+			if t.Steps[i].Name == name {
+				t.Steps[i] = Step{Name: name, Status: StepStatusPending}
+			}
+		}
+	}
+	t.UpdatedAt = time.Now()
+}
+
+// IsFinished 任务是否已结束(不会再有状态变化)
+func (t *Task) IsFinished() bool {
+	switch t.Status {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled, TaskStatusInterrupted:
+		return true
+	}
+	return false
+}
+
+// Snapshot 复制任务当前状态,用于在其他协程中安全序列化和对外输出
+// 回调密钥不会出现在副本中
+func (t *Task) Snapshot() *Task {
+	snapshot := *t
+	snapshot.Steps = append([]Step(nil), t.Steps...)
+	snapshot.Callbacks = append([]CallbackAttempt(nil), t.Callbacks...)
+	if snapshot.Input.CallbackSecret != "" {
+		snapshot.Input.CallbackSecret = "******"
+	}
+	if t.Result != nil {
+		result := *t.Result
+		snapshot.Result = &result
+	}
+	return &snapshot
+}
@@ -0,0 +1,191 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// shotTypeAliases 模型常用的镜头类型写法
+var shotTypeAliases = map[string]string{
+	"closeup":         ShotTypeCloseup,
+	"close-up":        ShotTypeCloseup,
+	"close up":        ShotTypeCloseup,
+	"extreme closeup": ShotTypeCloseup,
+	"特写":              ShotTypeCloseup,
+	"medium":          ShotTypeMedium,
+	"medium shot":     ShotTypeMedium,
+	"mid":             ShotTypeMedium,
+	"mid shot":        ShotTypeMedium,
+	"中景":              ShotTypeMedium,
+	"long":            ShotTypeLong,
+	"long shot":       ShotTypeLong,
+	"wide":            ShotTypeLong,
+	"wide shot":       ShotTypeLong,
+	"establishing":    ShotTypeLong,
+	"远景":              ShotTypeLong,
+	"全景":              ShotTypeLong,
+}
+
+// transitionAliases 模型常用的转场写法,空值视为直切
+var transitionAliases = map[string]string{
+	"":               TransitionCut,
+	"cut":            TransitionCut,
+	"hard cut":       TransitionCut,
+	"直切":             TransitionCut,
+	"fade":           TransitionFade,
+	"fade in":        TransitionFade,
+	"fade out":       TransitionFade,
+	"fade-in":        TransitionFade,
+	"fade-out":       TransitionFade,
+	"淡入淡出":           TransitionFade,
+	"dissolve":       TransitionDissolve,
+	"cross dissolve": TransitionDissolve,
+	"crossfade":      TransitionDissolve,
+	"溶解":             TransitionDissolve,
+}
+
+// Normalize 修正剧本解析结果中可自动修复的问题,返回无法修复的问题
+// 场景ID按顺序重新编号,场景角色去重,对白和场景中出现的角色补入角色列表,空对白被移除,元数据按实际内容重新统计
+func (p *ParsedScript) Normalize() []string {
+	var problems []string
+	if len(p.Scenes) == 0 {
+		problems = append(problems, "scenes must contain at least one scene")
+	}
+
+	characters := newNameSet(p.Characters)
+	for i := range p.Scenes {
+		scene := &p.Scenes[i]
+		scene.ID = i + 1
+
+		if strings.TrimSpace(scene.Location) == "" {
+			problems = append(problems, fmt.Sprintf("scene %d has no location", scene.ID))
+		}
+
+		sceneCharacters := newNameSet(nil)
+		for _, name := range scene.Characters {
+			sceneCharacters.add(characters.add(name))
+		}
+		scene.Characters = sceneCharacters.names
+
+		dialogues := scene.Dialogues[:0]
+		for _, dialogue := range scene.Dialogues {
+			if strings.TrimSpace(dialogue.Text) == "" {
+				continue
+			}
+			if strings.TrimSpace(dialogue.Character) == "" {
+				problems = append(problems, fmt.Sprintf("scene %d has a dialogue without character: %q", scene.ID, dialogue.Text))
+			} else {
+				dialogue.Character = characters.add(dialogue.Character)
+			}
+			dialogues = append(dialogues, dialogue)
+		}
+		scene.Dialogues = dialogues
+	}
+
+	p.Characters = characters.names
+	p.Metadata.TotalScenes = len(p.Scenes)
+
+	return problems
+}
+
+// Normalize 修正分镜中可自动修复的问题,返回无法修复的问题
+// 镜头类型和转场的常见写法统一为标准值,镜头ID按顺序重新编号,空对白被移除,总时长按镜头时长重新计算;
+// characters 非空时对白角色必须在其中(忽略大小写和首尾空格)
+func (s *Storyboard) Normalize(characters []string) []string {
+	var problems []string
+	if len(s.Shots) == 0 {
+		problems = append(problems, "shots must contain at least one shot")
+	}
+
+	known := newNameSet(characters)
+	total := 0.0
+	for i := range s.Shots {
+		shot := &s.Shots[i]
+		shot.ID = i + 1
+
+		if shotType, ok := shotTypeAliases[normalizeKey(shot.Type)]; ok {
+			shot.Type = shotType
+		} else {
+			problems = append(problems, fmt.Sprintf("shot %d has invalid type %q, must be one of: closeup, medium, long", shot.ID, shot.Type))
+		}
+
+		if transition, ok := transitionAliases[normalizeKey(shot.Transition)]; ok {
+			shot.Transition = transition
+		} else {
+			problems = append(problems, fmt.Sprintf("shot %d has invalid transition %q, must be one of: cut, fade, dissolve", shot.ID, shot.Transition))
+		}
+
+		if shot.Duration <= 0 || math.IsNaN(shot.Duration) {
+			problems = append(problems, fmt.Sprintf("shot %d has invalid duration %v, must be positive", shot.ID, shot.Duration))
+		} else {
+			total += shot.Duration
+		}
+
+		if strings.TrimSpace(shot.Description) == "" {
+			problems = append(problems, fmt.Sprintf("shot %d has no description", shot.ID))
+		}
+
+		for j, name := range shot.Characters {
+			if canonical, ok := known.find(name); ok {
+				shot.Characters[j] = canonical
+			}
+		}
+
+		if shot.Dialogue != nil && strings.TrimSpace(shot.Dialogue.Text) == "" {
+			shot.Dialogue = nil
+		}
+		if shot.Dialogue != nil && len(characters) > 0 {
+			if canonical, ok := known.find(shot.Dialogue.Character); ok {
+				shot.Dialogue.Character = canonical
+			} else {
+				problems = append(problems, fmt.Sprintf("shot %d dialogue character %q is not one of the script characters %v",
+					shot.ID, shot.Dialogue.Character, characters))
+			}
+		}
+	}
+
+	s.TotalDuration = math.Round(total*10) / 10
+
+	return problems
+}
+
+// nameSet 按忽略大小写和首尾空格匹配的角色名集合,保持首次出现顺序和写法
+type nameSet struct {
+	names []string
+	index map[string]string
+}
+
+// newNameSet 创建角色名集合
+func newNameSet(names []string) *nameSet {
+	set := &nameSet{index: make(map[string]string)}
+	for _, name := range names {
+		set.add(name)
+	}
+	return set
+}
+
+// add 加入角色名,返回集合中的标准写法
+func (s *nameSet) add(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return name
+	}
+	if canonical, ok := s.find(name); ok {
+		return canonical
+	}
+	s.index[strings.ToLower(name)] = name
+	s.names = append(s.names, name)
+	return name
+}
+
+// find 查找角色名的标准写法
+func (s *nameSet) find(name string) (string, bool) {
+	canonical, ok := s.index[strings.ToLower(strings.TrimSpace(name))]
+	return canonical, ok
+}
+
+// normalizeKey 统一大小写、首尾空格和下划线,用于匹配别名
+func normalizeKey(value string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(value)), "_", " ")
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsedScriptNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     ParsedScript
+		characters []string
+		scenes     []Scene
+		problems   int
+	}{
+		{
+			name:     "no scenes",
+			script:   ParsedScript{},
+			problems: 1,
+		},
+		{
+			name: "renumbers scenes and collects characters",
+			script: ParsedScript{
+				Characters: []string{"Alice"},
+				Scenes: []Scene{
+					{
+						ID:         7,
+						Location:   "kitchen",
+						Characters: []string{" alice ", "Bob", "BOB"},
+						Dialogues: []Dialogue{
+							{Character: "bob", Text: "Hi"},
+							{Character: "Carol", Text: "Hello"},
+						},
+					},
+					{ID: 3, Location: "park"},
+				},
+			},
+			characters: []string{"Alice", "Bob", "Carol"},
+			scenes: []Scene{
+				{
+					ID:         1,
+					Location:   "kitchen",
+					Characters: []string{"Alice", "Bob"},
+					Dialogues: []Dialogue{
+						{Character: "Bob", Text: "Hi"},
+						{Character: "Carol", Text: "Hello"},
+					},
+				},
+				{ID: 2, Location: "park"},
+			},
+		},
+		{
+			name: "drops empty dialogues and reports unfixable problems",
+			script: ParsedScript{
+				Scenes: []Scene{
+					{
+						Location: " ",
+						Dialogues: []Dialogue{
+							{Character: "Alice", Text: "  "},
+							{Character: "", Text: "Who said this?"},
+						},
+					},
+				},
+			},
+			scenes: []Scene{
+				{
+					ID:        1,
+					Location:  " ",
+					Dialogues: []Dialogue{{Text: "Who said this?"}},
+				},
+			},
+			problems: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := tt.script.Normalize()
+			if len(problems) != tt.problems {
+				t.Errorf("problems = %q, want %d", problems, tt.problems)
+			}
+			if !reflect.DeepEqual(tt.script.Characters, tt.characters) {
+				t.Errorf("characters = %q, want %q", tt.script.Characters, tt.characters)
+			}
+			if !reflect.DeepEqual(tt.script.Scenes, tt.scenes) {
+				t.Errorf("scenes = %+v, want %+v", tt.script.Scenes, tt.scenes)
+			}
+			if tt.script.Metadata.TotalScenes != len(tt.scenes) {
+				t.Errorf("total scenes = %d, want %d", tt.script.Metadata.TotalScenes, len(tt.scenes))
+			}
+		})
+	}
+}
+
+func TestStoryboardNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		shots      []Shot
+		characters []string
+		want       []Shot
+		total      float64
+		problems   int
+	}{
+		{
+			name:     "no shots",
+			problems: 1,
+		},
+		{
+			name: "aliases and ids are normalized",
+			shots: []Shot{
+				{ID: 5, Type: "Close_Up", Transition: "", Description: "a", Duration: 1.25},
+				{ID: 5, Type: "远景", Transition: "Cross Dissolve", Description: "b", Duration: 2},
+				{ID: 9, Type: "mid shot", Transition: "FADE-IN", Description: "c", Duration: 0.5},
+			},
+			want: []Shot{
+				{ID: 1, Type: ShotTypeCloseup, Transition: TransitionCut, Description: "a", Duration: 1.25},
+				{ID: 2, Type: ShotTypeLong, Transition: TransitionDissolve, Description: "b", Duration: 2},
+				{ID: 3, Type: ShotTypeMedium, Transition: TransitionFade, Description: "c", Duration: 0.5},
+			},
+			total: 3.8,
+		},
+		{
+			name:       "character names use script spelling",
+			characters: []string{"Alice"},
+			shots: []Shot{
+				{Type: "medium", Description: "a", Duration: 2, Characters: []string{"alice", "Extra"},
+					Dialogue: &Dialogue{Character: " ALICE ", Text: "Hi"}},
+			},
+			want: []Shot{
+				{ID: 1, Type: ShotTypeMedium, Transition: TransitionCut, Description: "a", Duration: 2,
+					Characters: []string{"Alice", "Extra"}, Dialogue: &Dialogue{Character: "Alice", Text: "Hi"}},
+			},
+			total: 2,
+		},
+		{
+			name:       "empty dialogue is removed before character check",
+			characters: []string{"Alice"},
+			shots: []Shot{
+				{Type: "medium", Description: "a", Duration: 2, Dialogue: &Dialogue{Character: "Nobody", Text: " "}},
+			},
+			want: []Shot{
+				{ID: 1, Type: ShotTypeMedium, Transition: TransitionCut, Description: "a", Duration: 2},
+			},
+			total: 2,
+		},
+		{
+			name:       "unfixable problems are reported",
+			characters: []string{"Alice"},
+			shots: []Shot{
+				{Type: "dutch", Transition: "wipe", Description: "", Duration: -1,
+					Dialogue: &Dialogue{Character: "Nobody", Text: "Hi"}},
+			},
+			want: []Shot{
+				{ID: 1, Type: "dutch", Transition: "wipe", Duration: -1,
+					Dialogue: &Dialogue{Character: "Nobody", Text: "Hi"}},
+			},
+			problems: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storyboard := &Storyboard{Shots: tt.shots}
+			problems := storyboard.Normalize(tt.characters)
+			if len(problems) != tt.problems {
+				t.Errorf("problems = %q, want %d", problems, tt.problems)
+			}
+			if !reflect.DeepEqual(storyboard.Shots, tt.want) {
+				t.Errorf("shots = %+v, want %+v", storyboard.Shots, tt.want)
+			}
+			if storyboard.TotalDuration != tt.total {
+				t.Errorf("total = %v, want %v", storyboard.TotalDuration, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package screenplay
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+var (
+	// fountainNote 注释 [[...]]
+	fountainNote = regexp.MustCompile(`\[\[[^\]]*\]\]`)
+	// fountainBoneyard 被注释掉的内容 /* ... */
+	fountainBoneyard = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// fountainTitleKey 标题页字段,如 "Title: xxx"
+	fountainTitleKey = regexp.MustCompile(`^[A-Za-z][A-Za-z ]*:`)
+	// fountainExtension 角色名后的扩展标记,如 (V.O.)、(CONT'D)、（画外音）
+	fountainExtension = regexp.MustCompile(`\s*[(（][^)）]*[)）]\s*$`)
+	// fountainEmphasis 强调标记 *斜体*、**粗体**、_下划线_
+	fountainEmphasis = regexp.MustCompile(`\*{1,3}|_`)
+)
+
+// ParseFountain 解析 Fountain 格式剧本
+// 支持场景标题(INT./EXT./内景/外景开头或以 . 强制)、角色(全大写、不含标点的中文短名或以 @ 强制)、括号注释(作为情绪)、对白和动作;
+// 标题页、转场、章节(#)、梗概(=)、注释([[ ]])和 /* */ 中的内容被忽略
+func ParseFountain(text string) (*model.ParsedScript, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = fountainBoneyard.ReplaceAllStringFunc(text, func(s string) string {
+		return strings.Repeat("\n", strings.Count(s, "\n")) // 保留行号
+	})
+	lines := strings.Split(text, "\n")
+
+	b := &sceneBuilder{}
+	i := skipTitlePage(lines)
+	for i < len(lines) {
+		line := cleanFountainLine(lines[i])
+		trimmed := strings.TrimSpace(line)
+		lineNo := i + 1
+
+		prevBlank := i == 0 || strings.TrimSpace(lines[i-1]) == ""
+		nextBlank := i+1 >= len(lines) || strings.TrimSpace(lines[i+1]) == ""
+
+		switch {
+		case trimmed == "" || trimmed == "===":
+			i++
+
+		case strings.HasPrefix(trimmed, "#") || (strings.HasPrefix(trimmed, "=") && !strings.HasPrefix(trimmed, "==")):
+			// 章节和梗概
+			i++
+
+		case isFountainHeading(trimmed):
+			b.startScene(strings.TrimPrefix(trimmed, "."))
+			i++
+
+		case isFountainTransition(trimmed):
+			i++
+
+		case prevBlank && !nextBlank && isFountainCharacter(trimmed):
+			character := fountainCharacter(trimmed)
+
+			// 角色名之后直到空行为止是对白,括号行为情绪说明
+			var dialogue []string
+			emotion := ""
+			i++
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				part := strings.TrimSpace(cleanFountainLine(lines[i]))
+				if inner, ok := parenthetical(part); ok {
+					if emotion == "" {
+						emotion = inner
+					}
+					continue
+				}
+				if part != "" {
+					dialogue = append(dialogue, part)
+				}
+			}
+			if len(dialogue) > 0 {
+				if err := b.addDialogue(lineNo, character, strings.Join(dialogue, " "), emotion); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			// 动作段落: 连续的非空行
+			var action []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				part := strings.TrimSpace(cleanFountainLine(lines[i]))
+				part = strings.TrimPrefix(part, "!")
+				if strings.HasPrefix(part, ">") && strings.HasSuffix(part, "<") {
+					part = strings.TrimSpace(part[1 : len(part)-1])
+				}
+				if part != "" {
+					action = append(action, part)
+				}
+			}
+			if len(action) > 0 {
+				if err := b.addAction(lineNo, strings.Join(action, " ")); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return b.result()
+}
+
+// skipTitlePage 跳过开头的标题页,返回正文第一行的下标
+func skipTitlePage(lines []string) int {
+	if len(lines) == 0 || !fountainTitleKey.MatchString(strings.TrimSpace(lines[0])) {
+		return 0
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// cleanFountainLine 去除注释和强调标记
+func cleanFountainLine(line string) string {
+	line = fountainNote.ReplaceAllString(line, "")
+	return fountainEmphasis.ReplaceAllString(line, "")
+}
+
+// isFountainHeading 是否为场景标题
+func isFountainHeading(line string) bool {
+	if strings.HasPrefix(line, ".") {
+		return len(line) > 1 && line[1] != '.'
+	}
+	return scenePrefix.MatchString(line) && strings.TrimSpace(scenePrefix.ReplaceAllString(line, "")) != ""
+}
+
+// isFountainTransition 是否为转场,如 "CUT TO:"、"> FADE OUT"
+func isFountainTransition(line string) bool {
+	if strings.HasPrefix(line, ">") {
+		return !strings.HasSuffix(line, "<")
+	}
+	return strings.HasSuffix(line, "TO:") && isUpper(line)
+}
+
+// maxCJKNameLength 不带 @ 的中日韩角色名最多字数
+const maxCJKNameLength = 8
+
+// isFountainCharacter 是否为角色名行
+// 中日韩文字没有大小写,不带 @ 时按不超过 maxCJKNameLength 字且不含标点的短行识别
+func isFountainCharacter(line string) bool {
+	if strings.HasPrefix(line, "@") {
+		return len(line) > 1
+	}
+	name := fountainExtension.ReplaceAllString(strings.TrimSuffix(line, "^"), "")
+	return name != "" && (isUpper(name) || isCJKName(name))
+}
+
+// isCJKName 是否为中日韩文字组成的短名字,允许间隔号,如 "李雷"、"安娜·卡列尼娜"
+func isCJKName(s string) bool {
+	count := 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		case r == '·' || r == '・' || r == 'ー':
+		default:
+			return false
+		}
+		count++
+	}
+	return count > 0 && count <= maxCJKNameLength
+}
+
+// parenthetical 括号行的内容,支持全角括号
+func parenthetical(line string) (string, bool) {
+	for _, pair := range [][2]string{{"(", ")"}, {"（", "）"}} {
+		if strings.HasPrefix(line, pair[0]) && strings.HasSuffix(line, pair[1]) && len(line) >= len(pair[0])+len(pair[1]) {
+			return strings.TrimSpace(line[len(pair[0]) : len(line)-len(pair[1])]), true
+		}
+	}
+	return "", false
+}
+
+// fountainCharacter 角色名,去除强制标记、扩展标记和双人对白标记
+func fountainCharacter(line string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(line, "@"), "^")
+	return strings.TrimSpace(fountainExtension.ReplaceAllString(strings.TrimSpace(name), ""))
+}
+
+// isUpper 是否包含字母且所有字母都是大写
+func isUpper(s string) bool {
+	hasLetter := false
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			if !unicode.IsUpper(r) {
+				return false
+			}
+			hasLetter = true
+		}
+	}
+	return hasLetter
+}
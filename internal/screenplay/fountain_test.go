@@ -0,0 +1,124 @@
+package screenplay
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestParseFountain(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		location  string
+		time      string
+		dialogues []model.Dialogue
+		actions   []string
+	}{
+		{
+			name:     "english dialogue with parenthetical",
+			text:     "INT. KITCHEN - NIGHT\n\nJOHN\n(angry)\nWhere were you?\n\nMary enters.",
+			location: "KITCHEN",
+			time:     "NIGHT",
+			dialogues: []model.Dialogue{
+				{Character: "JOHN", Text: "Where were you?", Emotion: "angry"},
+			},
+			actions: []string{"Mary enters."},
+		},
+		{
+			name:     "chinese character without @",
+			text:     "内景 咖啡馆 - 傍晚\n\n李雷\n（紧张）\n你来了。\n\n韩梅梅推门进来。",
+			location: "咖啡馆",
+			time:     "傍晚",
+			dialogues: []model.Dialogue{
+				{Character: "李雷", Text: "你来了。", Emotion: "紧张"},
+			},
+			actions: []string{"韩梅梅推门进来。"},
+		},
+		{
+			name:     "chinese character with extension and middle dot",
+			text:     "外景 车站 - 夜\n\n安娜·卡列尼娜（画外音）\n我在这里。",
+			location: "车站",
+			time:     "夜",
+			dialogues: []model.Dialogue{
+				{Character: "安娜·卡列尼娜", Text: "我在这里。"},
+			},
+		},
+		{
+			name:     "forced character",
+			text:     ".天台\n\n@小樱\n你好",
+			location: "天台",
+			dialogues: []model.Dialogue{
+				{Character: "小樱", Text: "你好"},
+			},
+		},
+		{
+			name:     "chinese action paragraph is not a character",
+			text:     "内景 书房 - 夜\n\n他走进房间，四处张望。\n桌上有一封信。",
+			location: "书房",
+			time:     "夜",
+			actions:  []string{"他走进房间，四处张望。 桌上有一封信。"},
+		},
+		{
+			name:     "title page, boneyard, notes and transitions are ignored",
+			text:     "Title: Test\nAuthor: Me\n\nEXT. PARK - DAY\n\n/* cut\nscene */\n\nCUT TO:\n\nBOB\nHi [[note]] there",
+			location: "PARK",
+			time:     "DAY",
+			dialogues: []model.Dialogue{
+				{Character: "BOB", Text: "Hi  there"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseFountain(tt.text)
+			if err != nil {
+				t.Fatalf("ParseFountain() error = %v", err)
+			}
+			if len(parsed.Scenes) != 1 {
+				t.Fatalf("got %d scenes, want 1", len(parsed.Scenes))
+			}
+
+			scene := parsed.Scenes[0]
+			if scene.Location != tt.location || scene.Time != tt.time {
+				t.Errorf("heading = (%q, %q), want (%q, %q)", scene.Location, scene.Time, tt.location, tt.time)
+			}
+			if !reflect.DeepEqual(scene.Dialogues, tt.dialogues) {
+				t.Errorf("dialogues = %+v, want %+v", scene.Dialogues, tt.dialogues)
+			}
+			if got := actionTexts(scene); !reflect.DeepEqual(got, tt.actions) {
+				t.Errorf("actions = %q, want %q", got, tt.actions)
+			}
+		})
+	}
+}
+
+func TestParseFountainErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "no scene heading", text: "Just some action."},
+		{name: "dialogue before heading", text: "JOHN\nHello\n\nINT. ROOM - DAY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFountain(tt.text); !errors.Is(err, ErrInvalidScript) {
+				t.Errorf("ParseFountain() error = %v, want ErrInvalidScript", err)
+			}
+		})
+	}
+}
+
+// actionTexts 场景中动作描述的文本
+func actionTexts(scene model.Scene) []string {
+	var texts []string
+	for _, action := range scene.Actions {
+		texts = append(texts, action.Description)
+	}
+	return texts
+}
@@ -0,0 +1,125 @@
+package screenplay
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+var (
+	// markdownHeading 标题行,二级及以下标题为场景
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*$`)
+	// markdownDialogue 对白行,如 "**小樱**: 你好"、"小樱(紧张)：你好"
+	// 角色名不能以数字开头,避免把 "10:30 ..." 这样的时间当作对白
+	markdownDialogue = regexp.MustCompile(`^(?:\*\*([^*]+)\*\*|([^\s\d:：(（*>#-][^:：(（]{0,19}?))\s*(?:[(（]([^)）]+)[)）])?\s*[:：]\s*(.+)$`)
+	// markdownListMarker 列表和引用标记
+	markdownListMarker = regexp.MustCompile(`^(?:[-*+]|\d+\.|>)\s+`)
+	// markdownComment 单行HTML注释
+	markdownComment = regexp.MustCompile(`<!--.*?-->`)
+)
+
+// markdownLabels 形如 "时间：傍晚" 的场景说明字段,不是对白
+// 时间和地点字段在场景标题没有给出时补充到场景,其余字段忽略
+var markdownLabels = map[string]string{
+	"时间": "time", "time": "time",
+	"地点": "location", "location": "location", "setting": "location",
+	"场景": "", "scene": "",
+	"人物": "", "出场人物": "", "角色": "", "characters": "", "cast": "",
+	"日期": "", "date": "",
+	"天气": "", "weather": "",
+	"备注": "", "注": "", "说明": "", "note": "", "notes": "",
+	"道具": "", "props": "",
+}
+
+// ParseMarkdown 解析 Markdown 格式剧本
+// 约定: "## 地点 - 时间" 为场景标题(一级标题为剧名,忽略);"角色: 台词" 或 "**角色**: 台词" 为对白,
+// 角色名后可用括号注明情绪,如 "小樱(紧张): 台词";"时间：傍晚" 等场景说明字段不作为对白;其余段落、列表和引用为动作描述
+func ParseMarkdown(text string) (*model.ParsedScript, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	b := &sceneBuilder{}
+	for i, line := range lines {
+		lineNo := i + 1
+		line = strings.TrimSpace(markdownComment.ReplaceAllString(line, ""))
+		if line == "" || strings.Trim(line, "-*_ ") == "" {
+			continue
+		}
+
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			if len(m[1]) > 1 {
+				b.startScene(m[2])
+			}
+			continue
+		}
+
+		if m := markdownDialogue.FindStringSubmatch(line); m != nil {
+			character := strings.TrimSpace(m[1] + m[2])
+			if field, ok := markdownLabels[strings.ToLower(character)]; ok {
+				b.setSceneField(field, stripQuotes(m[4]))
+				continue
+			}
+			if err := b.addDialogue(lineNo, character, stripQuotes(m[4]), strings.TrimSpace(m[3])); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		action := strings.TrimSpace(markdownListMarker.ReplaceAllString(line, ""))
+		action = strings.NewReplacer("**", "", "__", "").Replace(action)
+		if err := b.addAction(lineNo, action); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.result()
+}
+
+// setSceneField 用场景说明字段补充当前场景缺少的时间或地点
+func (b *sceneBuilder) setSceneField(field, value string) {
+	if b.scene == nil {
+		return
+	}
+	switch field {
+	case "time":
+		if b.scene.Time == "" {
+			b.scene.Time = value
+		}
+	case "location":
+		if b.scene.Location == "" {
+			b.scene.Location = value
+		}
+	}
+}
+
+// stripQuotes 去除台词两端的引号
+func stripQuotes(text string) string {
+	text = strings.TrimSpace(text)
+	for _, pair := range [][2]string{{`"`, `"`}, {"“", "”"}, {"「", "」"}, {"『", "』"}} {
+		if strings.HasPrefix(text, pair[0]) && strings.HasSuffix(text, pair[1]) && len(text) > len(pair[0])+len(pair[1]) {
+			return strings.TrimSpace(text[len(pair[0]) : len(text)-len(pair[1])])
+		}
+	}
+	return text
+}
+
+// ContinueMarkdown 拆分后的Markdown剧本片段不以场景标题开头时,补上前一片段最后的场景标题
+func ContinueMarkdown(previous, text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if m := markdownHeading.FindStringSubmatch(line); m != nil && len(m[1]) > 1 {
+				return text
+			}
+			break
+		}
+	}
+
+	lines := strings.Split(previous, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if m := markdownHeading.FindStringSubmatch(line); m != nil && len(m[1]) > 1 {
+			return line + "\n" + text
+		}
+	}
+	return text
+}
@@ -0,0 +1,135 @@
+package screenplay
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		location  string
+		time      string
+		dialogues []model.Dialogue
+		actions   []string
+	}{
+		{
+			name:     "dialogue forms and actions",
+			text:     "# 剧名\n\n## 咖啡馆 - 傍晚\n\n小樱(紧张)：你好\n**小明**: “嗨”\n- 小樱坐下。",
+			location: "咖啡馆",
+			time:     "傍晚",
+			dialogues: []model.Dialogue{
+				{Character: "小樱", Text: "你好", Emotion: "紧张"},
+				{Character: "小明", Text: "嗨"},
+			},
+			actions: []string{"小樱坐下。"},
+		},
+		{
+			name:     "time label fills the scene and clock time is an action",
+			text:     "## 卧室\n时间：清晨\n10:30 闹钟响了。\n小樱：早。",
+			location: "卧室",
+			time:     "清晨",
+			dialogues: []model.Dialogue{
+				{Character: "小樱", Text: "早。"},
+			},
+			actions: []string{"10:30 闹钟响了。"},
+		},
+		{
+			name:     "labels do not override the heading",
+			text:     "## 咖啡馆 - 夜\n**时间**：傍晚\n地点：书店\n人物：小樱、小明",
+			location: "咖啡馆",
+			time:     "夜",
+		},
+		{
+			name:     "comments and horizontal rules are skipped",
+			text:     "## Park | Day\n<!-- draft -->\n---\n> Birds sing.\nBob: \"Hi\"",
+			location: "Park",
+			time:     "Day",
+			dialogues: []model.Dialogue{
+				{Character: "Bob", Text: "Hi"},
+			},
+			actions: []string{"Birds sing."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseMarkdown(tt.text)
+			if err != nil {
+				t.Fatalf("ParseMarkdown() error = %v", err)
+			}
+			if len(parsed.Scenes) != 1 {
+				t.Fatalf("got %d scenes, want 1", len(parsed.Scenes))
+			}
+
+			scene := parsed.Scenes[0]
+			if scene.Location != tt.location || scene.Time != tt.time {
+				t.Errorf("heading = (%q, %q), want (%q, %q)", scene.Location, scene.Time, tt.location, tt.time)
+			}
+			if !reflect.DeepEqual(scene.Dialogues, tt.dialogues) {
+				t.Errorf("dialogues = %+v, want %+v", scene.Dialogues, tt.dialogues)
+			}
+			if got := actionTexts(scene); !reflect.DeepEqual(got, tt.actions) {
+				t.Errorf("actions = %q, want %q", got, tt.actions)
+			}
+		})
+	}
+}
+
+func TestParseMarkdownErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "no scene heading", text: "# 剧名\n小樱：你好"},
+		{name: "action before heading", text: "开场白\n## 咖啡馆"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMarkdown(tt.text); !errors.Is(err, ErrInvalidScript) {
+				t.Errorf("ParseMarkdown() error = %v, want ErrInvalidScript", err)
+			}
+		})
+	}
+}
+
+func TestContinueMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		text     string
+		want     string
+	}{
+		{
+			name:     "prepends last heading",
+			previous: "## 客厅\n小樱：你好\n## 厨房\n小明：嗯",
+			text:     "小樱：吃饭了",
+			want:     "## 厨房\n小樱：吃饭了",
+		},
+		{
+			name:     "keeps text starting with heading",
+			previous: "## 客厅",
+			text:     "\n## 卧室\n小樱：晚安",
+			want:     "\n## 卧室\n小樱：晚安",
+		},
+		{
+			name:     "no heading in previous",
+			previous: "# 剧名",
+			text:     "小樱：你好",
+			want:     "小樱：你好",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContinueMarkdown(tt.previous, tt.text); got != tt.want {
+				t.Errorf("ContinueMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
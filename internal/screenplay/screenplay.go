@@ -0,0 +1,156 @@
+package screenplay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+// ErrInvalidScript 结构化剧本内容不合法
+var ErrInvalidScript = errors.New("invalid script")
+
+// IsStructured 输入格式是否无需LLM即可解析
+func IsStructured(format string) bool {
+	switch format {
+	case model.InputFormatFountain, model.InputFormatMarkdown, model.InputFormatJSON:
+		return true
+	}
+	return false
+}
+
+// Parse 按输入格式确定性地解析剧本
+func Parse(format, text string) (*model.ParsedScript, error) {
+	var parsed *model.ParsedScript
+	var err error
+	switch format {
+	case model.InputFormatFountain:
+		parsed, err = ParseFountain(text)
+	case model.InputFormatMarkdown:
+		parsed, err = ParseMarkdown(text)
+	case model.InputFormatJSON:
+		parsed, err = ParseJSON(text)
+	default:
+		return nil, fmt.Errorf("%w: unsupported input format %q", ErrInvalidScript, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if problems := parsed.Normalize(); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidScript, strings.Join(problems, "; "))
+	}
+	if parsed.Metadata.WordCount == 0 {
+		parsed.Metadata.WordCount = utf8.RuneCountInString(text)
+	}
+	return parsed, nil
+}
+
+// ParseJSON 解析已经结构化的 ParsedScript JSON
+func ParseJSON(text string) (*model.ParsedScript, error) {
+	var parsed model.ParsedScript
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScript, err)
+	}
+	return &parsed, nil
+}
+
+// scenePrefix 场景标题的内外景前缀
+var scenePrefix = regexp.MustCompile(`(?i)^(int\.?/ext|ext\.?/int|i/e|int|ext|est|内外景|内景|外景)(?:[.。:：]|\s)+`)
+
+// headingSeparators 场景标题中地点和时间的分隔符,取最后一个
+var headingSeparators = []string{" - ", " – ", " — ", "|", "｜", "，"}
+
+// splitHeading 将场景标题拆分为地点和时间,如 "INT. KITCHEN - NIGHT"、"咖啡馆 | 傍晚"
+func splitHeading(heading string) (location, time string) {
+	heading = strings.TrimSpace(scenePrefix.ReplaceAllString(strings.TrimSpace(heading), ""))
+
+	cut := -1
+	sepLen := 0
+	for _, sep := range headingSeparators {
+		if i := strings.LastIndex(heading, sep); i > cut {
+			cut, sepLen = i, len(sep)
+		}
+	}
+	if cut < 0 {
+		return heading, ""
+	}
+	return strings.TrimSpace(heading[:cut]), strings.TrimSpace(heading[cut+sepLen:])
+}
+
+// sceneBuilder 按行构建场景,记录当前场景和已出现的角色
+type sceneBuilder struct {
+	parsed model.ParsedScript
+	scene  *model.Scene
+}
+
+// startScene 开始新场景
+func (b *sceneBuilder) startScene(heading string) {
+	location, time := splitHeading(heading)
+	b.parsed.Scenes = append(b.parsed.Scenes, model.Scene{
+		ID:       len(b.parsed.Scenes) + 1,
+		Location: location,
+		Time:     time,
+	})
+	b.scene = &b.parsed.Scenes[len(b.parsed.Scenes)-1]
+}
+
+// addCharacter 记录场景中出场的角色
+func (b *sceneBuilder) addCharacter(name string) {
+	b.parsed.Characters = append(b.parsed.Characters, name)
+	b.scene.Characters = append(b.scene.Characters, name)
+}
+
+// addDialogue 添加对白
+func (b *sceneBuilder) addDialogue(line int, character, text, emotion string) error {
+	if b.scene == nil {
+		return fmt.Errorf("%w: line %d: dialogue before the first scene heading", ErrInvalidScript, line)
+	}
+	b.addCharacter(character)
+	b.scene.Dialogues = append(b.scene.Dialogues, model.Dialogue{
+		Character: character,
+		Text:      text,
+		Emotion:   emotion,
+	})
+	return nil
+}
+
+// addAction 添加动作描述,所属角色在全部解析完成后补充
+func (b *sceneBuilder) addAction(line int, text string) error {
+	if b.scene == nil {
+		return fmt.Errorf("%w: line %d: action before the first scene heading", ErrInvalidScript, line)
+	}
+	b.scene.Actions = append(b.scene.Actions, model.Action{Description: text})
+	return nil
+}
+
+// result 返回解析结果,角色列表和场景角色由 Normalize 去重
+// 以角色名开头的动作描述归属该角色(忽略大小写,优先匹配较长的名字)
+func (b *sceneBuilder) result() (*model.ParsedScript, error) {
+	if len(b.parsed.Scenes) == 0 {
+		return nil, fmt.Errorf("%w: no scene headings found", ErrInvalidScript)
+	}
+
+	for i := range b.parsed.Scenes {
+		scene := &b.parsed.Scenes[i]
+		for j := range scene.Actions {
+			action := &scene.Actions[j]
+			description := strings.ToLower(action.Description)
+			for _, name := range b.parsed.Characters {
+				if len(name) > len(action.Character) && strings.HasPrefix(description, strings.ToLower(name)) {
+					action.Character = name
+				}
+			}
+			if action.Character != "" {
+				scene.Characters = append(scene.Characters, action.Character)
+			}
+		}
+	}
+	return &b.parsed, nil
+}
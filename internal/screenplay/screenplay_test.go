@@ -0,0 +1,89 @@
+package screenplay
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		text      string
+		scenes    int
+		wordCount int
+		wantErr   bool
+	}{
+		{
+			name:   "fountain",
+			format: model.InputFormatFountain,
+			text:   "INT. KITCHEN - NIGHT\n\nJOHN\nHi.",
+			scenes: 1,
+		},
+		{
+			name:   "markdown",
+			format: model.InputFormatMarkdown,
+			text:   "## 客厅\n小樱：你好\n## 厨房\n小明：嗯",
+			scenes: 2,
+		},
+		{
+			name:      "json keeps word count",
+			format:    model.InputFormatJSON,
+			text:      `{"scenes":[{"id":1,"location":"park","characters":["Bob"],"dialogues":[{"character":"Bob","text":"Hi"}]}],"metadata":{"word_count":42}}`,
+			scenes:    1,
+			wordCount: 42,
+		},
+		{name: "json unknown field", format: model.InputFormatJSON, text: `{"scenes":[],"shots":[]}`, wantErr: true},
+		{name: "json without scenes", format: model.InputFormatJSON, text: `{"scenes":[]}`, wantErr: true},
+		{name: "plain text is not structured", format: model.InputFormatText, text: "hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := Parse(tt.format, tt.text)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidScript) {
+					t.Errorf("Parse() error = %v, want ErrInvalidScript", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(parsed.Scenes) != tt.scenes || parsed.Metadata.TotalScenes != tt.scenes {
+				t.Errorf("scenes = %d (metadata %d), want %d", len(parsed.Scenes), parsed.Metadata.TotalScenes, tt.scenes)
+			}
+			if tt.wordCount != 0 && parsed.Metadata.WordCount != tt.wordCount {
+				t.Errorf("word count = %d, want %d", parsed.Metadata.WordCount, tt.wordCount)
+			}
+			if parsed.Metadata.WordCount == 0 {
+				t.Error("word count not filled")
+			}
+		})
+	}
+}
+
+func TestSplitHeading(t *testing.T) {
+	tests := []struct {
+		heading  string
+		location string
+		time     string
+	}{
+		{heading: "INT. KITCHEN - NIGHT", location: "KITCHEN", time: "NIGHT"},
+		{heading: "EXT./INT. CAR - DAY", location: "CAR", time: "DAY"},
+		{heading: "内景 咖啡馆 - 傍晚", location: "咖啡馆", time: "傍晚"},
+		{heading: "咖啡馆 | 傍晚", location: "咖啡馆", time: "傍晚"},
+		{heading: "ROOF", location: "ROOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.heading, func(t *testing.T) {
+			location, time := splitHeading(tt.heading)
+			if location != tt.location || time != tt.time {
+				t.Errorf("splitHeading(%q) = (%q, %q), want (%q, %q)", tt.heading, location, time, tt.location, tt.time)
+			}
+		})
+	}
+}
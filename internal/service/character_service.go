@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Jancd/1504/internal/client"
+	"github.com/Jancd/1504/internal/llm"
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidCharacterSheet 角色设定表内容不合法
+var ErrInvalidCharacterSheet = errors.New("invalid character sheet")
+
+const (
+	// characterPromptTokens 角色设定请求中除原文外的提示词token数
+	characterPromptTokens = 600
+	// characterOutputTokens 每个角色设定回复的token数
+	characterOutputTokens = 80
+)
+
+// CharacterService 角色设定服务
+type CharacterService struct {
+	llmClient     *client.LLMClient
+	dataDir       string
+	maxTextLength int // 发送给LLM的原文最大字数,超过时只发送提到角色的段落;0表示不限制
+}
+
+// NewCharacterService 创建角色设定服务
+func NewCharacterService(llmClient *client.LLMClient, dataDir string, maxTextLength int) *CharacterService {
+	return &CharacterService{
+		llmClient:     llmClient,
+		dataDir:       dataDir,
+		maxTextLength: maxTextLength,
+	}
+}
+
+// Generate 生成角色设定表
+// 先由LLM根据原文提取外貌设定,再用请求中预设的同名角色字段覆盖;未指定种子的角色分配固定种子
+func (s *CharacterService) Generate(ctx context.Context, taskID, text string, parsed *model.ParsedScript, presets []model.Character) (*model.CharacterSheet, error) {
+	names := characterNames(parsed, presets)
+
+	logger.Info("Starting character sheet generation",
+		zap.String("task_id", taskID),
+		zap.Int("characters", len(names)))
+
+	sheet := &model.CharacterSheet{Characters: make([]model.Character, 0, len(names))}
+	for _, name := range names {
+		sheet.Characters = append(sheet.Characters, model.Character{Name: name})
+	}
+
+	if len(names) > 0 {
+		extracted, err := s.llmClient.ExtractCharacters(ctx, characterExcerpts(text, names, s.maxTextLength), names)
+		if err != nil {
+			logger.Error("Failed to extract characters", zap.String("task_id", taskID), zap.Error(err))
+			return nil, fmt.Errorf("failed to extract characters: %w", err)
+		}
+
+		// 只保留角色列表中的角色,避免LLM凭空增加
+		for _, c := range extracted {
+			if target := sheet.Find(c.Name); target != nil {
+				mergeCharacter(target, c)
+			}
+		}
+	}
+
+	for _, preset := range presets {
+		if target := sheet.Find(preset.Name); target != nil {
+			mergeCharacter(target, preset)
+		}
+	}
+
+	for i := range sheet.Characters {
+		if sheet.Characters[i].Seed == 0 {
+			sheet.Characters[i].Seed = rand.Int63n(1<<31-1) + 1
+		}
+	}
+
+	if err := s.Save(taskID, sheet); err != nil {
+		return nil, err
+	}
+
+	return sheet, nil
+}
+
+// EstimateUsage 估算生成角色设定表的token用量,不调用LLM
+func (s *CharacterService) EstimateUsage(text string, parsed *model.ParsedScript, presets []model.Character) model.TokenUsage {
+	names := characterNames(parsed, presets)
+	if len(names) == 0 {
+		return model.TokenUsage{}
+	}
+
+	return model.TokenUsage{
+		Calls:            1,
+		PromptTokens:     characterPromptTokens + llm.EstimateTokens(characterExcerpts(text, names, s.maxTextLength)),
+		CompletionTokens: characterOutputTokens * len(names),
+	}
+}
+
+// Save 校验并保存角色设定表
+func (s *CharacterService) Save(taskID string, sheet *model.CharacterSheet) error {
+	projectDir := filepath.Join(s.dataDir, "projects", taskID)
+
+	seen := make(map[string]bool)
+	for _, c := range sheet.Characters {
+		if c.Name == "" {
+			return fmt.Errorf("%w: character name cannot be empty", ErrInvalidCharacterSheet)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%w: duplicate character %q", ErrInvalidCharacterSheet, c.Name)
+		}
+		seen[c.Name] = true
+
+		if c.Seed < 0 {
+			return fmt.Errorf("%w: character %q has negative seed", ErrInvalidCharacterSheet, c.Name)
+		}
+		if c.ReferenceImage != "" {
+			path, err := ResolveReferenceImage(projectDir, c.ReferenceImage)
+			if err != nil {
+				return fmt.Errorf("%w: character %q: %v", ErrInvalidCharacterSheet, c.Name, err)
+			}
+			if !utils.FileExists(path) {
+				return fmt.Errorf("%w: reference image for %q not found: %s", ErrInvalidCharacterSheet, c.Name, c.ReferenceImage)
+			}
+		}
+	}
+
+	if err := utils.EnsureDir(projectDir); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	sheetPath := filepath.Join(projectDir, "characters.json")
+	if err := utils.SaveJSON(sheetPath, sheet); err != nil {
+		return fmt.Errorf("failed to save character sheet: %w", err)
+	}
+
+	logger.Info("Character sheet saved",
+		zap.String("task_id", taskID),
+		zap.Int("characters", len(sheet.Characters)))
+
+	return nil
+}
+
+// Load 加载已保存的角色设定表
+func (s *CharacterService) Load(taskID string) (*model.CharacterSheet, error) {
+	sheetPath := filepath.Join(s.dataDir, "projects", taskID, "characters.json")
+
+	var sheet model.CharacterSheet
+	if err := utils.LoadJSON(sheetPath, &sheet); err != nil {
+		return nil, fmt.Errorf("failed to load character sheet: %w", err)
+	}
+	return &sheet, nil
+}
+
+// LoadReferenceImage 读取角色参考图
+func (s *CharacterService) LoadReferenceImage(taskID string, c *model.Character) ([]byte, error) {
+	path, err := ResolveReferenceImage(filepath.Join(s.dataDir, "projects", taskID), c.ReferenceImage)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reference image: %w", err)
+	}
+	return data, nil
+}
+
+// ResolveReferenceImage 参考图路径以任务项目目录为基准
+// 只接受项目目录内的相对路径,绝对路径和包含 .. 的路径返回错误,避免读取项目目录以外的文件
+func ResolveReferenceImage(projectDir, path string) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("reference image must be a relative path inside the project directory: %s", path)
+	}
+	return filepath.Join(projectDir, path), nil
+}
+
+// characterNames 汇总剧本和预设中出现的角色名(保持首次出现顺序)
+func characterNames(parsed *model.ParsedScript, presets []model.Character) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range parsed.Characters {
+		add(name)
+	}
+	for _, scene := range parsed.Scenes {
+		for _, name := range scene.Characters {
+			add(name)
+		}
+	}
+	for _, preset := range presets {
+		add(preset.Name)
+	}
+	return names
+}
+
+// characterExcerpts 长文本只保留提到角色的段落
+// 按原文顺序轮流为每个角色挑选提到该角色的段落,优先保证每个角色都有描写,总字数不超过 limit
+func characterExcerpts(text string, names []string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+
+	selected := make(map[int]bool)
+	next := make([]int, len(names)) // 每个角色下一个待检查的段落
+	total := 0
+	for progress := true; progress; {
+		progress = false
+		for i, name := range names {
+			for next[i] < len(paragraphs) {
+				j := next[i]
+				next[i]++
+				if selected[j] || !strings.Contains(paragraphs[j], name) {
+					continue
+				}
+				length := utf8.RuneCountInString(paragraphs[j])
+				if total+length > limit {
+					continue
+				}
+				selected[j] = true
+				total += length
+				progress = true
+				break
+			}
+		}
+	}
+
+	var excerpts []string
+	for j, paragraph := range paragraphs {
+		if selected[j] {
+			excerpts = append(excerpts, paragraph)
+		}
+	}
+	return strings.Join(excerpts, "\n")
+}
+
+// mergeCharacter 用非空字段覆盖角色设定
+func mergeCharacter(dst *model.Character, src model.Character) {
+	if src.Appearance != "" {
+		dst.Appearance = src.Appearance
+	}
+	if src.Hair != "" {
+		dst.Hair = src.Hair
+	}
+	if src.Clothing != "" {
+		dst.Clothing = src.Clothing
+	}
+	if src.Age != "" {
+		dst.Age = src.Age
+	}
+	if src.Palette != "" {
+		dst.Palette = src.Palette
+	}
+	if src.Seed != 0 {
+		dst.Seed = src.Seed
+	}
+	if src.ReferenceImage != "" {
+		dst.ReferenceImage = src.ReferenceImage
+	}
+	if src.Voice != "" {
+		dst.Voice = src.Voice
+	}
+}
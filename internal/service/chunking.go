@@ -0,0 +1,109 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// chapterHeading 章节标题行,如 "第十二章 雨夜"、"Chapter 3"、markdown标题
+var chapterHeading = regexp.MustCompile(`^\s*(第[0-9零一二三四五六七八九十百千万]+[章节回卷幕]|(?i:chapter|part)\s+[0-9ivxlc]+\b|#{1,6}\s)`)
+
+// sentenceEnd 句末标点,超长段落在句末处切分
+var sentenceEnd = regexp.MustCompile(`[。！？!?…]+["”’」』]?|[.]+["'”’]?\s`)
+
+// SplitText 按章节和段落边界将文本切分为不超过 chunkSize 字的片段
+// 章节标题在当前片段已超过一半长度时开始新片段;超长段落按句子切分,仍超长的句子直接截断
+func SplitText(text string, chunkSize int) []string {
+	if chunkSize <= 0 || utf8.RuneCountInString(text) <= chunkSize {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	add := func(piece string) {
+		pieceLen := utf8.RuneCountInString(piece)
+		if currentLen > 0 && currentLen+pieceLen+1 > chunkSize {
+			flush()
+		}
+		if currentLen > 0 {
+			current.WriteString("\n")
+			currentLen++
+		}
+		current.WriteString(piece)
+		currentLen += pieceLen
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if chapterHeading.MatchString(line) && currentLen > chunkSize/2 {
+			flush()
+		}
+
+		for _, piece := range splitParagraph(line, chunkSize) {
+			add(piece)
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// splitParagraph 将超长段落按句子切分为不超过 chunkSize 字的片段
+func splitParagraph(paragraph string, chunkSize int) []string {
+	if utf8.RuneCountInString(paragraph) <= chunkSize {
+		return []string{paragraph}
+	}
+
+	var pieces []string
+	var current strings.Builder
+	currentLen := 0
+
+	start := 0
+	ends := sentenceEnd.FindAllStringIndex(paragraph, -1)
+	ends = append(ends, []int{len(paragraph), len(paragraph)})
+	for _, end := range ends {
+		sentence := paragraph[start:end[1]]
+		start = end[1]
+		if sentence == "" {
+			continue
+		}
+
+		sentenceLen := utf8.RuneCountInString(sentence)
+		if currentLen > 0 && currentLen+sentenceLen > chunkSize {
+			pieces = append(pieces, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+
+		// 没有句末标点的超长句子按字数截断
+		for sentenceLen > chunkSize {
+			runes := []rune(sentence)
+			pieces = append(pieces, string(runes[:chunkSize]))
+			sentence = string(runes[chunkSize:])
+			sentenceLen -= chunkSize
+		}
+
+		current.WriteString(sentence)
+		currentLen += sentenceLen
+	}
+	if currentLen > 0 {
+		pieces = append(pieces, current.String())
+	}
+
+	return pieces
+}
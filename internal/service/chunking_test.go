@@ -0,0 +1,70 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		chunkSize int
+		want      []string
+	}{
+		{
+			name:      "short text is kept",
+			text:      "  短文本  ",
+			chunkSize: 10,
+			want:      []string{"  短文本  "},
+		},
+		{
+			name:      "non-positive chunk size is kept",
+			text:      "一二三四五六",
+			chunkSize: 0,
+			want:      []string{"一二三四五六"},
+		},
+		{
+			name:      "paragraphs are packed and blank lines dropped",
+			text:      "aaaa\n\nbbbb\r\ncccc",
+			chunkSize: 10,
+			want:      []string{"aaaa\nbbbb", "cccc"},
+		},
+		{
+			name:      "chapter heading starts a new chunk past half size",
+			text:      "第一章 雨\n甲甲甲甲甲甲甲\n第二章 风\n乙乙乙乙乙",
+			chunkSize: 20,
+			want:      []string{"第一章 雨\n甲甲甲甲甲甲甲", "第二章 风\n乙乙乙乙乙"},
+		},
+		{
+			name:      "long paragraph is split at sentence ends",
+			text:      "一二三。四五六。七八。",
+			chunkSize: 6,
+			want:      []string{"一二三。", "四五六。", "七八。"},
+		},
+		{
+			name:      "sentence without punctuation is truncated",
+			text:      "一二三四五六七",
+			chunkSize: 3,
+			want:      []string{"一二三", "四五六", "七"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitText(tt.text, tt.chunkSize)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitText() = %q, want %q", got, tt.want)
+			}
+			if tt.chunkSize <= 0 {
+				return
+			}
+			for _, chunk := range got {
+				if n := utf8.RuneCountInString(chunk); n > tt.chunkSize {
+					t.Errorf("chunk %q has %d runes, limit %d", chunk, n, tt.chunkSize)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// episodePieceSize 拆分剧集时的最小片段字数,避免把段落切得过碎
+const episodePieceSize = 200
+
+// SplitEpisodes 按章节和段落边界将文本拆分为不超过 count 集,各集字数尽量均衡
+// 分集位置前后 1/3 集长度内有章节标题时在最近的标题前切分,否则在最近的段落边界切分
+func SplitEpisodes(text string, count int) []string {
+	text = strings.TrimSpace(text)
+	total := utf8.RuneCountInString(text)
+	if count <= 1 || total == 0 {
+		return []string{text}
+	}
+
+	// 以段落为片段,超长段落按句子切分;章节标题单独成段
+	var pieces []string
+	var offsets []int // 每个片段开始处的累计字数
+	offset := 0
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimRight(line, " \t\r"); strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, piece := range splitParagraph(line, max(total/(count*8), episodePieceSize)) {
+			pieces = append(pieces, piece)
+			offsets = append(offsets, offset)
+			offset += utf8.RuneCountInString(piece)
+		}
+	}
+
+	target := total / count
+	window := target / 3
+	cuts := []int{0} // 每集第一个片段的下标
+	for k := 1; k < count; k++ {
+		boundary := k * target
+		best, bestChapter := -1, false
+		for i := cuts[len(cuts)-1] + 1; i < len(pieces); i++ {
+			distance := abs(offsets[i] - boundary)
+			chapter := chapterHeading.MatchString(pieces[i]) && distance <= window
+			switch {
+			case best < 0,
+				chapter && !bestChapter,
+				chapter == bestChapter && distance < abs(offsets[best]-boundary):
+				best, bestChapter = i, chapter
+			}
+			if offsets[i] > boundary+window {
+				break
+			}
+		}
+		if best < 0 {
+			break
+		}
+		cuts = append(cuts, best)
+	}
+	cuts = append(cuts, len(pieces))
+
+	episodes := make([]string, 0, len(cuts)-1)
+	for k := 0; k+1 < len(cuts); k++ {
+		episodes = append(episodes, strings.Join(pieces[cuts[k]:cuts[k+1]], "\n"))
+	}
+	return episodes
+}
+
+// abs 整数绝对值
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// TailText 文本最后不超过 limit 字的内容,从句子开头截取
+func TailText(text string, limit int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= limit {
+		return string(runes)
+	}
+
+	tail := string(runes[len(runes)-limit:])
+	if loc := sentenceEnd.FindStringIndex(tail); loc != nil && loc[1] < len(tail) {
+		tail = tail[loc[1]:]
+	}
+	return strings.TrimSpace(tail)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTailText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{name: "short text", text: " 第一句。 ", limit: 10, want: "第一句。"},
+		{name: "starts at sentence", text: "第一句。第二句。第三句。", limit: 5, want: "第三句。"},
+		{name: "no sentence end", text: "一二三四五六", limit: 3, want: "四五六"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TailText(tt.text, tt.limit); got != tt.want {
+				t.Errorf("TailText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitEpisodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		count int
+		want  []string
+	}{
+		{
+			name:  "single episode",
+			text:  "  甲\n乙  ",
+			count: 1,
+			want:  []string{"甲\n乙"},
+		},
+		{
+			name:  "empty text",
+			text:  " ",
+			count: 3,
+			want:  []string{""},
+		},
+		{
+			name:  "balanced at paragraph boundaries",
+			text:  strings.Join([]string{repeat("甲", 150), repeat("乙", 150), repeat("丙", 150), repeat("丁", 150)}, "\n"),
+			count: 2,
+			want: []string{
+				repeat("甲", 150) + "\n" + repeat("乙", 150),
+				repeat("丙", 150) + "\n" + repeat("丁", 150),
+			},
+		},
+		{
+			name:  "chapter heading near boundary is preferred",
+			text:  strings.Join([]string{repeat("甲", 200), "第二章 风", repeat("乙", 60), repeat("丙", 200)}, "\n"),
+			count: 2,
+			want: []string{
+				repeat("甲", 200),
+				"第二章 风\n" + repeat("乙", 60) + "\n" + repeat("丙", 200),
+			},
+		},
+		{
+			name:  "fewer paragraphs than episodes",
+			text:  "甲\n\n乙",
+			count: 5,
+			want:  []string{"甲", "乙"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitEpisodes(tt.text, tt.count); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitEpisodes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// repeat 生成由 n 个 s 组成的段落
+func repeat(s string, n int) string {
+	return strings.Repeat(s, n)
+}
@@ -2,45 +2,74 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
-	"github.com/Jancd/1504/internal/client"
+	"github.com/Jancd/1504/internal/imagegen"
 	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/style"
 	"github.com/Jancd/1504/pkg/logger"
 	"github.com/Jancd/1504/pkg/utils"
 	"go.uber.org/zap"
 )
 
+// ErrShotNotFound 分镜中不存在指定镜头
+var ErrShotNotFound = errors.New("shot not found")
+
 // ImageService 图像生成服务
 type ImageService struct {
-	sdClient           *client.SDClient
-	storyboardService  *StoryboardService
-	dataDir            string
-	defaultWidth       int
-	defaultHeight      int
+	generator         imagegen.Generator
+	storyboardService *StoryboardService
+	characterService  *CharacterService
+	styles            *style.Registry
+	dataDir           string
+	baseWidth         int // 基准输出分辨率,按画面比例换算
+	baseHeight        int
+	imageSize         int // 生成图像的长边像素,0表示与输出分辨率一致
 }
 
 // NewImageService 创建图像生成服务
-func NewImageService(sdClient *client.SDClient, storyboardService *StoryboardService, dataDir string, width, height int) *ImageService {
+func NewImageService(generator imagegen.Generator, storyboardService *StoryboardService, characterService *CharacterService, styles *style.Registry, dataDir string, width, height, imageSize int) *ImageService {
 	return &ImageService{
-		sdClient:          sdClient,
+		generator:         generator,
 		storyboardService: storyboardService,
+		characterService:  characterService,
+		styles:            styles,
 		dataDir:           dataDir,
-		defaultWidth:      width,
-		defaultHeight:     height,
+		baseWidth:         width,
+		baseHeight:        height,
+		imageSize:         imageSize,
+	}
+}
+
+// ImageSize 画面比例对应的图像生成尺寸
+func (s *ImageService) ImageSize(aspectRatio string) (int, int) {
+	width, height, err := utils.FitAspectRatio(s.baseWidth, s.baseHeight, aspectRatio)
+	if err != nil {
+		width, height = s.baseWidth, s.baseHeight
+	}
+	if s.imageSize <= 0 {
+		return width, height
 	}
+	// SD要求宽高为8的整数倍
+	return utils.ScaleToLongSide(width, height, s.imageSize, 8)
 }
 
 // ProgressCallback 进度回调函数
 type ProgressCallback func(current, total int)
 
 // GenerateAll 生成所有镜头图像
-func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboard *model.Storyboard, progressCallback ProgressCallback) error {
+// 已存在的镜头图像(如重试前已生成的)会被直接复用
+func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboard *model.Storyboard, options model.Options, progressCallback ProgressCallback) error {
+	width, height := s.ImageSize(options.AspectRatio)
+
 	logger.Info("Starting image generation for all shots",
 		zap.String("task_id", taskID),
-		zap.Int("total_shots", len(storyboard.Shots)))
+		zap.Int("total_shots", len(storyboard.Shots)),
+		zap.Int("width", width),
+		zap.Int("height", height))
 
 	// 创建图像目录
 	imagesDir := filepath.Join(s.dataDir, "projects", taskID, "images")
@@ -48,9 +77,7 @@ func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboar
 		return fmt.Errorf("failed to create images directory: %w", err)
 	}
 
-	// 生成负面Prompt
-	negativePrompt := s.storyboardService.GenerateNegativePrompt()
-
+	characters := s.loadCharacters(taskID)
 	totalShots := len(storyboard.Shots)
 
 	// 串行生成图像 (MVP简化,避免GPU内存不足)
@@ -64,6 +91,22 @@ func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboar
 		default:
 		}
 
+		// 复用已生成的图像
+		imagePath := filepath.Join(imagesDir, fmt.Sprintf("shot_%03d.png", shot.ID))
+		if utils.FileExists(imagePath) {
+			shot.ImagePath = imagePath
+
+			logger.Info("Reusing existing image",
+				zap.String("task_id", taskID),
+				zap.Int("shot_id", shot.ID),
+				zap.String("image_path", imagePath))
+
+			if progressCallback != nil {
+				progressCallback(i+1, totalShots)
+			}
+			continue
+		}
+
 		logger.Info("Generating image",
 			zap.String("task_id", taskID),
 			zap.Int("shot_id", shot.ID),
@@ -72,7 +115,7 @@ func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboar
 			zap.String("description", shot.Description))
 
 		// 生成图像
-		imageData, err := s.sdClient.GenerateImage(ctx, shot.Prompt, negativePrompt, s.defaultWidth, s.defaultHeight)
+		imageData, err := s.generator.Generate(ctx, s.imageRequest(taskID, shot, characters, options.Style, width, height))
 		if err != nil {
 			logger.Error("Failed to generate image",
 				zap.String("task_id", taskID),
@@ -81,9 +124,12 @@ func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboar
 			return fmt.Errorf("failed to generate image for shot %d: %w", shot.ID, err)
 		}
 
-		// 保存图像
-		imagePath := filepath.Join(imagesDir, fmt.Sprintf("shot_%03d.png", shot.ID))
-		if err := os.WriteFile(imagePath, imageData, 0644); err != nil {
+		// 保存图像(先写临时文件,避免中断时留下不完整的图像被重试复用)
+		tmpPath := imagePath + ".tmp"
+		if err := os.WriteFile(tmpPath, imageData, 0644); err != nil {
+			return fmt.Errorf("failed to save image: %w", err)
+		}
+		if err := os.Rename(tmpPath, imagePath); err != nil {
 			return fmt.Errorf("failed to save image: %w", err)
 		}
 
@@ -116,7 +162,8 @@ func (s *ImageService) GenerateAll(ctx context.Context, taskID string, storyboar
 }
 
 // RegenerateShot 重新生成单个镜头
-func (s *ImageService) RegenerateShot(ctx context.Context, taskID string, shotID int, customPrompt string) (string, error) {
+// customPrompt 非空时替换镜头Prompt,seed 非0时使用指定种子;更新后的镜头会写回storyboard.json
+func (s *ImageService) RegenerateShot(ctx context.Context, taskID string, shotID int, options model.Options, customPrompt string, seed int64) (*model.Shot, error) {
 	logger.Info("Regenerating single shot",
 		zap.String("task_id", taskID),
 		zap.Int("shot_id", shotID))
@@ -127,7 +174,7 @@ func (s *ImageService) RegenerateShot(ctx context.Context, taskID string, shotID
 
 	var storyboard model.Storyboard
 	if err := utils.LoadJSON(storyboardPath, &storyboard); err != nil {
-		return "", fmt.Errorf("failed to load storyboard: %w", err)
+		return nil, fmt.Errorf("failed to load storyboard: %w", err)
 	}
 
 	// 查找镜头
@@ -140,27 +187,49 @@ func (s *ImageService) RegenerateShot(ctx context.Context, taskID string, shotID
 	}
 
 	if shot == nil {
-		return "", fmt.Errorf("shot %d not found", shotID)
+		return nil, fmt.Errorf("%w: %d", ErrShotNotFound, shotID)
 	}
 
-	// 使用自定义Prompt或原始Prompt
-	prompt := shot.Prompt
+	// 使用自定义Prompt和种子,未指定时沿用原有的
 	if customPrompt != "" {
-		prompt = customPrompt
+		shot.Prompt = customPrompt
+	}
+	if seed != 0 {
+		shot.Seed = seed
 	}
 
 	// 生成图像
-	negativePrompt := s.storyboardService.GenerateNegativePrompt()
-	imageData, err := s.sdClient.GenerateImage(ctx, prompt, negativePrompt, s.defaultWidth, s.defaultHeight)
+	width, height := s.ImageSize(options.AspectRatio)
+	imageData, err := s.generator.Generate(ctx, s.imageRequest(taskID, shot, s.loadCharacters(taskID), options.Style, width, height))
 	if err != nil {
-		return "", fmt.Errorf("failed to generate image: %w", err)
+		return nil, fmt.Errorf("failed to generate image: %w", err)
 	}
 
 	// 保存图像
 	imagesDir := filepath.Join(projectDir, "images")
+	if err := utils.EnsureDir(imagesDir); err != nil {
+		return nil, fmt.Errorf("failed to create images directory: %w", err)
+	}
 	imagePath := filepath.Join(imagesDir, fmt.Sprintf("shot_%03d.png", shotID))
-	if err := os.WriteFile(imagePath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to save image: %w", err)
+	tmpPath := imagePath + ".tmp"
+	if err := os.WriteFile(tmpPath, imageData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	if err := os.Rename(tmpPath, imagePath); err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	shot.ImagePath = imagePath
+
+	// 关键帧已变化,删除由旧关键帧生成的动画片段(混合模式)
+	clipPath := filepath.Join(projectDir, "clips", fmt.Sprintf("shot_%03d.mp4", shotID))
+	if err := os.Remove(clipPath); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove outdated clip", zap.String("path", clipPath), zap.Error(err))
+	}
+	shot.VideoPath = ""
+
+	// 保存更新后的分镜脚本
+	if err := utils.SaveJSON(storyboardPath, &storyboard); err != nil {
+		return nil, fmt.Errorf("failed to save storyboard: %w", err)
 	}
 
 	logger.Info("Shot regenerated successfully",
@@ -168,5 +237,65 @@ func (s *ImageService) RegenerateShot(ctx context.Context, taskID string, shotID
 		zap.Int("shot_id", shotID),
 		zap.String("image_path", imagePath))
 
-	return imagePath, nil
+	return shot, nil
+}
+
+// imageRequest 按风格预设和角色设定构建镜头的图像生成请求
+// 出场角色的外貌描述拼入Prompt;镜头未指定种子时使用主要角色的固定种子和参考图
+func (s *ImageService) imageRequest(taskID string, shot *model.Shot, characters *model.CharacterSheet, styleName string, width, height int) imagegen.Request {
+	preset := s.styles.Get(styleName)
+	req := imagegen.Request{
+		Prompt:         shot.Prompt,
+		NegativePrompt: preset.NegativePrompt,
+		Description:    shot.Description,
+		Steps:          preset.Steps,
+		CFGScale:       preset.CFGScale,
+		Width:          width,
+		Height:         height,
+		Sampler:        preset.Sampler,
+		Seed:           sdSeed(shot.Seed),
+	}
+
+	if desc := characters.Describe(shot.Characters); desc != "" {
+		req.Prompt += ", " + desc
+	}
+
+	primary := characters.Primary(shot.Characters)
+	if primary == nil {
+		return req
+	}
+	if shot.Seed == 0 && primary.Seed != 0 {
+		req.Seed = primary.Seed
+	}
+	if primary.ReferenceImage != "" {
+		image, err := s.characterService.LoadReferenceImage(taskID, primary)
+		if err != nil {
+			logger.Warn("Skipping character reference image",
+				zap.String("task_id", taskID),
+				zap.String("character", primary.Name),
+				zap.Error(err))
+		} else {
+			req.ReferenceImage = image
+		}
+	}
+
+	return req
+}
+
+// loadCharacters 加载任务的角色设定表,不存在时返回nil
+func (s *ImageService) loadCharacters(taskID string) *model.CharacterSheet {
+	characters, err := s.characterService.Load(taskID)
+	if err != nil {
+		logger.Debug("No character sheet for task", zap.String("task_id", taskID), zap.Error(err))
+		return nil
+	}
+	return characters
+}
+
+// sdSeed 转换为生成器种子参数,0表示随机(-1)
+func sdSeed(seed int64) int64 {
+	if seed == 0 {
+		return -1
+	}
+	return seed
 }
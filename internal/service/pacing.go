@@ -0,0 +1,110 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+const (
+	// dialoguePadding 对白镜头在朗读时长之外的留白(秒)
+	dialoguePadding = 0.5
+	// maxPacedShotDuration 补足目标时长时镜头最多拉伸到的时长(秒)
+	maxPacedShotDuration = 8.0
+)
+
+// Pacer 镜头节奏调整
+// 对白镜头至少保留读完(或播完配音)所需的时长,其余镜头重新分配,使总时长接近目标时长
+type Pacer struct {
+	readingSpeed    float64 // 字幕阅读速度(字/秒)
+	minShotDuration float64 // 镜头最短时长(秒)
+}
+
+// NewPacer 创建镜头节奏调整器
+func NewPacer(readingSpeed, minShotDuration float64) *Pacer {
+	return &Pacer{
+		readingSpeed:    readingSpeed,
+		minShotDuration: minShotDuration,
+	}
+}
+
+// Apply 调整分镜镜头时长
+// voiceLengths 为镜头ID对应的配音时长(秒),没有配音的对白按阅读速度估算;target<=0时只保证最短时长
+func (p *Pacer) Apply(storyboard *model.Storyboard, voiceLengths map[int]float64, target float64) {
+	if len(storyboard.Shots) == 0 {
+		return
+	}
+
+	required := make([]float64, len(storyboard.Shots))
+	total := 0.0
+	for i := range storyboard.Shots {
+		shot := &storyboard.Shots[i]
+		required[i] = roundUp(p.requiredDuration(shot, voiceLengths))
+		if shot.Duration < required[i] {
+			shot.Duration = required[i]
+		}
+		total += shot.Duration
+	}
+
+	if target > 0 {
+		switch {
+		case total > target:
+			// 超出目标: 按可压缩余量等比缩短,不低于各镜头最短时长
+			p.redistribute(storyboard, total-target, func(i int) float64 {
+				return storyboard.Shots[i].Duration - required[i]
+			}, -1)
+		case total < target:
+			// 不足目标: 按可拉伸余量等比延长
+			p.redistribute(storyboard, target-total, func(i int) float64 {
+				return math.Max(0, maxPacedShotDuration-storyboard.Shots[i].Duration)
+			}, 1)
+		}
+	}
+
+	total = 0
+	for i := range storyboard.Shots {
+		shot := &storyboard.Shots[i]
+		shot.Duration = math.Max(math.Round(shot.Duration*10)/10, required[i])
+		total += shot.Duration
+	}
+	storyboard.TotalDuration = math.Round(total*10) / 10
+}
+
+// redistribute 按各镜头余量等比分配 amount 秒,sign 为-1时缩短,为1时延长
+func (p *Pacer) redistribute(storyboard *model.Storyboard, amount float64, room func(i int) float64, sign float64) {
+	rooms := make([]float64, len(storyboard.Shots))
+	totalRoom := 0.0
+	for i := range storyboard.Shots {
+		rooms[i] = room(i)
+		totalRoom += rooms[i]
+	}
+	if totalRoom <= 0 {
+		return
+	}
+
+	ratio := math.Min(amount, totalRoom) / totalRoom
+	for i := range storyboard.Shots {
+		storyboard.Shots[i].Duration += sign * rooms[i] * ratio
+	}
+}
+
+// requiredDuration 镜头最短时长: 配音时长或按阅读速度估算的字幕时长,加上留白
+func (p *Pacer) requiredDuration(shot *model.Shot, voiceLengths map[int]float64) float64 {
+	required := p.minShotDuration
+	if shot.Dialogue == nil || strings.TrimSpace(shot.Dialogue.Text) == "" {
+		return required
+	}
+
+	speech, ok := voiceLengths[shot.ID]
+	if !ok && p.readingSpeed > 0 {
+		speech = float64(utf8.RuneCountInString(shot.Dialogue.Text)) / p.readingSpeed
+	}
+	return math.Max(required, speech+dialoguePadding)
+}
+
+// roundUp 向上取整到0.1秒
+func roundUp(d float64) float64 {
+	return math.Ceil(d*10-1e-9) / 10
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestPacerApply(t *testing.T) {
+	line := &model.Dialogue{Character: "小樱", Text: "你好你好你好你好你好"}
+
+	tests := []struct {
+		name   string
+		shots  []model.Shot
+		voices map[int]float64
+		target float64
+		want   []float64
+		total  float64
+	}{
+		{
+			name:  "minimum duration without target",
+			shots: []model.Shot{{ID: 1, Duration: 1}, {ID: 2, Duration: 3}},
+			want:  []float64{1.5, 3},
+			total: 4.5,
+		},
+		{
+			name:  "dialogue estimated from reading speed",
+			shots: []model.Shot{{ID: 1, Duration: 1, Dialogue: line}},
+			want:  []float64{2.5},
+			total: 2.5,
+		},
+		{
+			name:   "voice length overrides reading speed",
+			shots:  []model.Shot{{ID: 1, Duration: 1, Dialogue: line}},
+			voices: map[int]float64{1: 4.23},
+			want:   []float64{4.8},
+			total:  4.8,
+		},
+		{
+			name:  "blank dialogue uses minimum",
+			shots: []model.Shot{{ID: 1, Duration: 1, Dialogue: &model.Dialogue{Text: "  "}}},
+			want:  []float64{1.5},
+			total: 1.5,
+		},
+		{
+			name:   "compress proportionally to target",
+			shots:  []model.Shot{{ID: 1, Duration: 4}, {ID: 2, Duration: 4}},
+			target: 5,
+			want:   []float64{2.5, 2.5},
+			total:  5,
+		},
+		{
+			name:   "compression keeps required durations",
+			shots:  []model.Shot{{ID: 1, Duration: 4}, {ID: 2, Duration: 3, Dialogue: line}},
+			target: 1,
+			want:   []float64{1.5, 2.5},
+			total:  4,
+		},
+		{
+			name:   "stretch proportionally to target",
+			shots:  []model.Shot{{ID: 1, Duration: 2}, {ID: 2, Duration: 6}},
+			target: 10,
+			want:   []float64{3.5, 6.5},
+			total:  10,
+		},
+		{
+			name:   "stretch is capped per shot",
+			shots:  []model.Shot{{ID: 1, Duration: 2}},
+			target: 20,
+			want:   []float64{maxPacedShotDuration},
+			total:  maxPacedShotDuration,
+		},
+		{
+			name:   "empty storyboard",
+			target: 10,
+		},
+	}
+
+	pacer := NewPacer(5, 1.5)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storyboard := &model.Storyboard{Shots: tt.shots}
+			pacer.Apply(storyboard, tt.voices, tt.target)
+
+			if len(storyboard.Shots) != len(tt.want) {
+				t.Fatalf("got %d shots, want %d", len(storyboard.Shots), len(tt.want))
+			}
+			for i, shot := range storyboard.Shots {
+				if math.Abs(shot.Duration-tt.want[i]) > 1e-9 {
+					t.Errorf("shot %d duration = %v, want %v", shot.ID, shot.Duration, tt.want[i])
+				}
+			}
+			if math.Abs(storyboard.TotalDuration-tt.total) > 1e-9 {
+				t.Errorf("total = %v, want %v", storyboard.TotalDuration, tt.total)
+			}
+		})
+	}
+}
+
+func TestPacerRedistribute(t *testing.T) {
+	tests := []struct {
+		name   string
+		shots  []float64
+		rooms  []float64
+		amount float64
+		sign   float64
+		want   []float64
+	}{
+		{
+			name:   "shrink by room ratio",
+			shots:  []float64{4, 6},
+			rooms:  []float64{1, 3},
+			amount: 2,
+			sign:   -1,
+			want:   []float64{3.5, 4.5},
+		},
+		{
+			name:   "amount larger than room uses all room",
+			shots:  []float64{2, 2},
+			rooms:  []float64{1, 2},
+			amount: 10,
+			sign:   1,
+			want:   []float64{3, 4},
+		},
+		{
+			name:   "no room leaves shots unchanged",
+			shots:  []float64{2, 3},
+			rooms:  []float64{0, 0},
+			amount: 1,
+			sign:   1,
+			want:   []float64{2, 3},
+		},
+	}
+
+	pacer := NewPacer(5, 1.5)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storyboard := &model.Storyboard{}
+			for i, d := range tt.shots {
+				storyboard.Shots = append(storyboard.Shots, model.Shot{ID: i + 1, Duration: d})
+			}
+
+			pacer.redistribute(storyboard, tt.amount, func(i int) float64 { return tt.rooms[i] }, tt.sign)
+
+			for i, shot := range storyboard.Shots {
+				if math.Abs(shot.Duration-tt.want[i]) > 1e-9 {
+					t.Errorf("shot %d duration = %v, want %v", shot.ID, shot.Duration, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/Jancd/1504/internal/client"
 	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/screenplay"
 	"github.com/Jancd/1504/pkg/logger"
 	"github.com/Jancd/1504/pkg/utils"
 	"go.uber.org/zap"
@@ -14,24 +17,47 @@ import (
 
 // ParserService 剧本解析服务
 type ParserService struct {
-	openaiClient *client.OpenAIClient
-	dataDir      string
+	llmClient   *client.LLMClient
+	dataDir     string
+	chunkSize   int // 单次解析的最大字数,超过时分段解析;0表示不分段
+	maxInFlight int // 同时解析的分段数
 }
 
 // NewParserService 创建剧本解析服务
-func NewParserService(openaiClient *client.OpenAIClient, dataDir string) *ParserService {
+func NewParserService(llmClient *client.LLMClient, dataDir string, chunkSize, maxInFlight int) *ParserService {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
 	return &ParserService{
-		openaiClient: openaiClient,
-		dataDir:      dataDir,
+		llmClient:   llmClient,
+		dataDir:     dataDir,
+		chunkSize:   chunkSize,
+		maxInFlight: maxInFlight,
 	}
 }
 
+// ChunkCallback 分段解析进度回调
+type ChunkCallback func(completed, total int)
+
 // Parse 解析剧本
-func (s *ParserService) Parse(ctx context.Context, taskID, text string) (*model.ParsedScript, error) {
-	logger.Info("Starting script parsing", zap.String("task_id", taskID), zap.Int("text_length", len(text)))
+// Fountain、Markdown和JSON格式直接按格式解析,不调用LLM;
+// 自由文本超过分段长度时按章节和段落切分后并发解析,再合并为一个剧本
+func (s *ParserService) Parse(ctx context.Context, taskID, text, format string, chunkCallback ChunkCallback) (*model.ParsedScript, error) {
+	logger.Info("Starting script parsing",
+		zap.String("task_id", taskID),
+		zap.String("input_format", format),
+		zap.Int("text_length", len(text)))
 
-	// 调用OpenAI解析
-	parsed, err := s.openaiClient.ParseScript(ctx, text)
+	var parsed *model.ParsedScript
+	var err error
+	if screenplay.IsStructured(format) {
+		parsed, err = screenplay.Parse(format, text)
+	} else if chunks := SplitText(text, s.chunkSize); len(chunks) > 1 {
+		parsed, err = s.parseChunks(ctx, taskID, chunks, chunkCallback)
+	} else {
+		// 调用LLM解析
+		parsed, err = s.llmClient.ParseScript(ctx, text)
+	}
 	if err != nil {
 		logger.Error("Failed to parse script", zap.String("task_id", taskID), zap.Error(err))
 		return nil, fmt.Errorf("failed to parse script: %w", err)
@@ -71,3 +97,183 @@ func (s *ParserService) Parse(ctx context.Context, taskID, text string) (*model.
 
 	return parsed, nil
 }
+
+// parseChunks 并发解析各分段并合并结果
+// 同时解析的分段数不超过 maxInFlight,任一分段失败时取消其余分段
+func (s *ParserService) parseChunks(ctx context.Context, taskID string, chunks []string, chunkCallback ChunkCallback) (*model.ParsedScript, error) {
+	logger.Info("Parsing script in chunks",
+		zap.String("task_id", taskID),
+		zap.Int("chunks", len(chunks)),
+		zap.Int("max_in_flight", s.maxInFlight))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		firstErr  error
+		completed int
+		results   = make([]*model.ParsedScript, len(chunks))
+		sem       = make(chan struct{}, s.maxInFlight)
+	)
+
+submit:
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break submit
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			parsed, err := s.llmClient.ParseScript(ctx, chunk)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to parse chunk %d/%d: %w", i+1, len(chunks), err)
+					cancel()
+				}
+				return
+			}
+
+			results[i] = parsed
+			completed++
+			logger.Info("Script chunk parsed",
+				zap.String("task_id", taskID),
+				zap.Int("chunk", i+1),
+				zap.Int("completed", completed),
+				zap.Int("total", len(chunks)))
+			if chunkCallback != nil {
+				chunkCallback(completed, len(chunks))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	merged := mergeParsedScripts(results)
+
+	// 不同分段可能用不同称呼指代同一角色
+	if len(merged.Characters) > 1 {
+		aliases, err := s.llmClient.ResolveAliases(ctx, merged.Characters, dialogueSamples(merged, 2))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve character aliases: %w", err)
+		}
+		if len(aliases) > 0 {
+			logger.Info("Merging character aliases",
+				zap.String("task_id", taskID),
+				zap.Any("aliases", aliases))
+			applyAliases(merged, aliases)
+		}
+	}
+
+	merged.Normalize()
+	return merged, nil
+}
+
+// mergeParsedScripts 按分段顺序合并场景和角色,场景ID由 Normalize 重新编号
+// 分段边界切断的场景(前一分段最后一个场景与后一分段第一个场景地点和时间相同)合并为一个
+func mergeParsedScripts(parts []*model.ParsedScript) *model.ParsedScript {
+	merged := &model.ParsedScript{}
+	for _, part := range parts {
+		scenes := part.Scenes
+		if n := len(merged.Scenes); n > 0 && len(scenes) > 0 && sameScene(merged.Scenes[n-1], scenes[0]) {
+			last := &merged.Scenes[n-1]
+			last.Characters = append(last.Characters, scenes[0].Characters...)
+			last.Dialogues = append(last.Dialogues, scenes[0].Dialogues...)
+			last.Actions = append(last.Actions, scenes[0].Actions...)
+			scenes = scenes[1:]
+		}
+		merged.Scenes = append(merged.Scenes, scenes...)
+		merged.Characters = append(merged.Characters, part.Characters...)
+	}
+	merged.Normalize()
+	return merged
+}
+
+// sameScene 两个场景的地点和时间是否相同
+func sameScene(a, b model.Scene) bool {
+	return strings.EqualFold(strings.TrimSpace(a.Location), strings.TrimSpace(b.Location)) &&
+		strings.EqualFold(strings.TrimSpace(a.Time), strings.TrimSpace(b.Time))
+}
+
+// dialogueSamples 收集每个角色的前几句台词,帮助识别别名
+func dialogueSamples(parsed *model.ParsedScript, limit int) map[string][]string {
+	samples := make(map[string][]string)
+	for _, scene := range parsed.Scenes {
+		for _, dialogue := range scene.Dialogues {
+			if len(samples[dialogue.Character]) < limit {
+				samples[dialogue.Character] = append(samples[dialogue.Character], dialogue.Text)
+			}
+		}
+	}
+	return samples
+}
+
+// applyAliases 将别名替换为标准名,支持别名链(A->B->C)
+func applyAliases(parsed *model.ParsedScript, aliases map[string]string) {
+	resolve := func(name string) string {
+		for i := 0; i < len(aliases); i++ {
+			canonical, ok := aliases[name]
+			if !ok {
+				break
+			}
+			name = canonical
+		}
+		return name
+	}
+
+	for i := range parsed.Scenes {
+		scene := &parsed.Scenes[i]
+		names := make([]string, 0, len(scene.Characters))
+		seen := make(map[string]bool)
+		for _, name := range scene.Characters {
+			if name = resolve(name); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		scene.Characters = names
+
+		for j := range scene.Dialogues {
+			scene.Dialogues[j].Character = resolve(scene.Dialogues[j].Character)
+		}
+		for j := range scene.Actions {
+			scene.Actions[j].Character = resolve(scene.Actions[j].Character)
+		}
+	}
+
+	characters := make([]string, 0, len(parsed.Characters))
+	for _, name := range parsed.Characters {
+		if _, ok := aliases[name]; !ok {
+			characters = append(characters, name)
+		}
+	}
+	parsed.Characters = characters
+}
+
+// Load 加载已保存的解析结果
+func (s *ParserService) Load(taskID string) (*model.ParsedScript, error) {
+	parsedPath := filepath.Join(s.dataDir, "projects", taskID, "parsed.json")
+
+	var parsed model.ParsedScript
+	if err := utils.LoadJSON(parsedPath, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to load parsed script: %w", err)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestMergeParsedScripts(t *testing.T) {
+	tests := []struct {
+		name       string
+		parts      []*model.ParsedScript
+		locations  []string
+		dialogues  []int
+		characters []string
+	}{
+		{
+			name: "scene cut by chunk boundary is merged",
+			parts: []*model.ParsedScript{
+				{
+					Characters: []string{"Alice"},
+					Scenes: []model.Scene{
+						{ID: 1, Location: "Park", Time: "Day"},
+						{ID: 2, Location: "Cafe", Time: "Night", Dialogues: []model.Dialogue{{Character: "Alice", Text: "Hi"}}},
+					},
+				},
+				{
+					Characters: []string{"alice", "Bob"},
+					Scenes: []model.Scene{
+						{ID: 1, Location: " cafe ", Time: "NIGHT", Dialogues: []model.Dialogue{{Character: "Bob", Text: "Hello"}}},
+						{ID: 2, Location: "Street", Time: "Night"},
+					},
+				},
+			},
+			locations:  []string{"Park", "Cafe", "Street"},
+			dialogues:  []int{0, 2, 0},
+			characters: []string{"Alice", "Bob"},
+		},
+		{
+			name: "different time keeps scenes apart",
+			parts: []*model.ParsedScript{
+				{Scenes: []model.Scene{{Location: "Cafe", Time: "Day"}}},
+				{Scenes: []model.Scene{{Location: "Cafe", Time: "Night"}}},
+			},
+			locations: []string{"Cafe", "Cafe"},
+			dialogues: []int{0, 0},
+		},
+		{
+			name: "empty part is skipped",
+			parts: []*model.ParsedScript{
+				{Scenes: []model.Scene{{Location: "Cafe"}}},
+				{},
+				{Scenes: []model.Scene{{Location: "Cafe"}}},
+			},
+			locations: []string{"Cafe"},
+			dialogues: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeParsedScripts(tt.parts)
+
+			var locations []string
+			var dialogues []int
+			for i, scene := range merged.Scenes {
+				if scene.ID != i+1 {
+					t.Errorf("scene %d has ID %d", i, scene.ID)
+				}
+				locations = append(locations, scene.Location)
+				dialogues = append(dialogues, len(scene.Dialogues))
+			}
+			if !reflect.DeepEqual(locations, tt.locations) {
+				t.Errorf("locations = %q, want %q", locations, tt.locations)
+			}
+			if !reflect.DeepEqual(dialogues, tt.dialogues) {
+				t.Errorf("dialogues = %v, want %v", dialogues, tt.dialogues)
+			}
+			if !reflect.DeepEqual(merged.Characters, tt.characters) {
+				t.Errorf("characters = %q, want %q", merged.Characters, tt.characters)
+			}
+			if merged.Metadata.TotalScenes != len(tt.locations) {
+				t.Errorf("total scenes = %d, want %d", merged.Metadata.TotalScenes, len(tt.locations))
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/config"
+	"github.com/Jancd/1504/pkg/utils"
+)
+
+// 视频生成方式
+const (
+	ModeLocalSD      = "local_sd"
+	ModeHybrid       = "hybrid"
+	ModeQiniuSingle  = "qiniu_single"
+	ModeQiniuPerShot = "qiniu_per_shot"
+)
+
+// PlanService 试运行计划服务,估算正式生成的用量、耗时和费用
+type PlanService struct {
+	characterService *CharacterService
+	prices           config.EstimateConfig
+	mode             string
+	maxInFlight      int // 七牛云同时进行的片段任务数
+	dataDir          string
+}
+
+// NewPlanService 创建试运行计划服务
+func NewPlanService(characterService *CharacterService, prices config.EstimateConfig, mode string, maxInFlight int, dataDir string) *PlanService {
+	return &PlanService{
+		characterService: characterService,
+		prices:           prices,
+		mode:             mode,
+		maxInFlight:      max(maxInFlight, 1),
+		dataDir:          dataDir,
+	}
+}
+
+// VideoMode 按配置返回视频生成方式
+func VideoMode(cfg config.VideoGenerationConfig) string {
+	switch cfg.Type {
+	case "qiniu":
+		if cfg.Qiniu.Mode == "per_shot" {
+			return ModeQiniuPerShot
+		}
+		return ModeQiniuSingle
+	case "hybrid":
+		return ModeHybrid
+	default:
+		return ModeLocalSD
+	}
+}
+
+// Estimate 根据解析结果、分镜和试运行实际消耗的token估算正式生成
+func (s *PlanService) Estimate(plan *model.Plan, planTokens model.TokenUsage) model.PlanEstimate {
+	shots := len(plan.Storyboard.Shots)
+	duration := plan.Storyboard.TotalDuration
+	clipWaves := float64((shots + s.maxInFlight - 1) / s.maxInFlight)
+
+	estimate := model.PlanEstimate{
+		Mode:          s.mode,
+		PlanTokens:    planTokens,
+		TaskTokens:    s.characterService.EstimateUsage(plan.Input.Text, plan.Parsed, plan.Input.Characters),
+		VideoDuration: duration,
+		Currency:      s.prices.Currency,
+	}
+
+	// 图像串行生成;片段按并发数分批生成;除七牛云整体生成外都需要本地渲染
+	seconds := 0.0
+	switch s.mode {
+	case ModeQiniuSingle:
+		estimate.Clips = 1
+		seconds = s.prices.ClipTime
+	case ModeQiniuPerShot:
+		estimate.Clips = shots
+		seconds = clipWaves*s.prices.ClipTime + duration*s.prices.RenderSpeed
+	case ModeHybrid:
+		estimate.Images = shots
+		estimate.Clips = shots
+		seconds = float64(shots)*s.prices.ImageTime + clipWaves*s.prices.ClipTime + duration*s.prices.RenderSpeed
+	default:
+		estimate.Images = shots
+		seconds = float64(shots)*s.prices.ImageTime + duration*s.prices.RenderSpeed
+	}
+	estimate.RenderTime = int(math.Ceil(seconds))
+
+	estimate.PlanCost = roundCost(s.tokenCost(estimate.PlanTokens))
+	estimate.TaskCost = roundCost(s.tokenCost(estimate.TaskTokens) +
+		float64(estimate.Images)*s.prices.ImagePrice +
+		float64(estimate.Clips)*s.prices.ClipPrice)
+
+	return estimate
+}
+
+// tokenCost token用量的费用
+func (s *PlanService) tokenCost(usage model.TokenUsage) float64 {
+	return float64(usage.PromptTokens)/1000*s.prices.InputTokenPrice +
+		float64(usage.CompletionTokens)/1000*s.prices.OutputTokenPrice
+}
+
+// roundCost 费用保留4位小数
+func roundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
+
+// Save 保存计划
+// 解析结果和分镜已由解析和分镜服务保存在同一项目目录,计划文件中不重复保存
+func (s *PlanService) Save(plan *model.Plan) error {
+	record := *plan
+	record.Parsed, record.Storyboard = nil, nil
+
+	planPath := filepath.Join(s.dataDir, "projects", plan.ID, "plan.json")
+	if err := utils.SaveJSON(planPath, &record); err != nil {
+		return fmt.Errorf("failed to save plan: %w", err)
+	}
+	return nil
+}
+
+// Load 加载计划及其解析结果和分镜
+func (s *PlanService) Load(planID string) (*model.Plan, error) {
+	projectDir := filepath.Join(s.dataDir, "projects", planID)
+
+	var plan model.Plan
+	if err := utils.LoadJSON(filepath.Join(projectDir, "plan.json"), &plan); err != nil {
+		return nil, fmt.Errorf("failed to load plan: %w", err)
+	}
+
+	plan.Parsed = &model.ParsedScript{}
+	if err := utils.LoadJSON(filepath.Join(projectDir, "parsed.json"), plan.Parsed); err != nil {
+		return nil, fmt.Errorf("failed to load parsed script: %w", err)
+	}
+	plan.Storyboard = &model.Storyboard{}
+	if err := utils.LoadJSON(filepath.Join(projectDir, "storyboard.json"), plan.Storyboard); err != nil {
+		return nil, fmt.Errorf("failed to load storyboard: %w", err)
+	}
+	return &plan, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/config"
+)
+
+func TestVideoMode(t *testing.T) {
+	tests := []struct {
+		cfg  config.VideoGenerationConfig
+		want string
+	}{
+		{cfg: config.VideoGenerationConfig{Type: "local_sd"}, want: ModeLocalSD},
+		{cfg: config.VideoGenerationConfig{Type: "hybrid"}, want: ModeHybrid},
+		{cfg: config.VideoGenerationConfig{Type: "qiniu"}, want: ModeQiniuSingle},
+		{cfg: config.VideoGenerationConfig{Type: "qiniu", Qiniu: config.QiniuConfig{Mode: "per_shot"}}, want: ModeQiniuPerShot},
+	}
+
+	for _, tt := range tests {
+		if got := VideoMode(tt.cfg); got != tt.want {
+			t.Errorf("VideoMode(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestPlanServiceEstimate(t *testing.T) {
+	prices := config.EstimateConfig{
+		Currency:         "CNY",
+		InputTokenPrice:  1,
+		OutputTokenPrice: 2,
+		ImagePrice:       0.1,
+		ClipPrice:        1,
+		ImageTime:        10,
+		ClipTime:         60,
+		RenderSpeed:      1,
+	}
+	plan := &model.Plan{
+		Input:  model.Input{Text: "x"},
+		Parsed: &model.ParsedScript{},
+		Storyboard: &model.Storyboard{
+			Shots:         make([]model.Shot, 3),
+			TotalDuration: 9,
+		},
+	}
+	planTokens := model.TokenUsage{Calls: 2, PromptTokens: 1000, CompletionTokens: 500}
+
+	tests := []struct {
+		mode       string
+		images     int
+		clips      int
+		renderTime int
+		taskCost   float64
+	}{
+		// 3张图 + 渲染9秒
+		{mode: ModeLocalSD, images: 3, renderTime: 39, taskCost: 0.3},
+		// 整体生成一个片段
+		{mode: ModeQiniuSingle, clips: 1, renderTime: 60, taskCost: 1},
+		// 并发2: 两批片段 + 渲染9秒
+		{mode: ModeQiniuPerShot, clips: 3, renderTime: 129, taskCost: 3},
+		{mode: ModeHybrid, images: 3, clips: 3, renderTime: 159, taskCost: 3.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			s := NewPlanService(NewCharacterService(nil, t.TempDir(), 0), prices, tt.mode, 2, t.TempDir())
+			got := s.Estimate(plan, planTokens)
+
+			if got.Mode != tt.mode || got.Images != tt.images || got.Clips != tt.clips {
+				t.Errorf("estimate = %+v", got)
+			}
+			if got.RenderTime != tt.renderTime {
+				t.Errorf("render time = %d, want %d", got.RenderTime, tt.renderTime)
+			}
+			if got.PlanCost != 2 {
+				t.Errorf("plan cost = %v, want 2", got.PlanCost)
+			}
+			if got.TaskCost != tt.taskCost {
+				t.Errorf("task cost = %v, want %v", got.TaskCost, tt.taskCost)
+			}
+			if got.TaskTokens != (model.TokenUsage{}) {
+				t.Errorf("task tokens = %+v, want none without characters", got.TaskTokens)
+			}
+			if got.VideoDuration != 9 || got.Currency != "CNY" {
+				t.Errorf("estimate = %+v", got)
+			}
+		})
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/Jancd/1504/internal/client"
 	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/style"
+	"github.com/Jancd/1504/pkg/ffmpeg"
 	"github.com/Jancd/1504/pkg/logger"
 	"github.com/Jancd/1504/pkg/utils"
 	"go.uber.org/zap"
@@ -16,28 +19,69 @@ import (
 
 // QiniuVideoService 七牛云视频生成服务
 type QiniuVideoService struct {
-	qiniuClient *client.QiniuVideoClient
-	dataDir     string
-	maxWaitTime time.Duration
+	qiniuClient      *client.QiniuVideoClient
+	characterService *CharacterService
+	styles           *style.Registry
+	ffmpeg           *ffmpeg.FFmpeg
+	dataDir          string
+	maxWaitTime      time.Duration
+	baseWidth        int // 基准输出分辨率,无法探测视频时按画面比例换算
+	baseHeight       int
+	maxInFlight      int // 逐镜头模式同时进行的生成任务数
+	clipLength       int // 单次生成的视频长度(秒)
 }
 
 // NewQiniuVideoService 创建七牛云视频生成服务
-func NewQiniuVideoService(qiniuClient *client.QiniuVideoClient, dataDir string, maxWaitTimeSec int) *QiniuVideoService {
+func NewQiniuVideoService(qiniuClient *client.QiniuVideoClient, characterService *CharacterService, styles *style.Registry, dataDir string, maxWaitTimeSec, width, height, maxInFlight, clipLength int) *QiniuVideoService {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+
 	return &QiniuVideoService{
-		qiniuClient: qiniuClient,
-		dataDir:     dataDir,
-		maxWaitTime: time.Duration(maxWaitTimeSec) * time.Second,
+		qiniuClient:      qiniuClient,
+		characterService: characterService,
+		styles:           styles,
+		ffmpeg:           ffmpeg.New(),
+		dataDir:          dataDir,
+		maxWaitTime:      time.Duration(maxWaitTimeSec) * time.Second,
+		baseWidth:        width,
+		baseHeight:       height,
+		maxInFlight:      maxInFlight,
+		clipLength:       clipLength,
 	}
 }
 
+// Resolution 获取生成视频的实际分辨率
+// 优先用ffprobe读取,ffprobe不可用时按画面比例估算
+func (s *QiniuVideoService) Resolution(ctx context.Context, videoPath, aspectRatio string) string {
+	width, height, err := s.ffmpeg.ProbeResolution(ctx, videoPath)
+	if err == nil {
+		return fmt.Sprintf("%dx%d", width, height)
+	}
+	logger.Debug("Failed to probe video resolution, estimating from aspect ratio", zap.Error(err))
+
+	width, height, err = utils.FitAspectRatio(s.baseWidth, s.baseHeight, aspectRatio)
+	if err != nil {
+		width, height = s.baseWidth, s.baseHeight
+	}
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+// PollCallback 七牛云任务轮询状态回调
+type PollCallback func(event model.PollEvent)
+
 // GenerateFromStoryboard 从分镜脚本生成视频
-func (s *QiniuVideoService) GenerateFromStoryboard(ctx context.Context, taskID string, storyboard *model.Storyboard) (string, error) {
+func (s *QiniuVideoService) GenerateFromStoryboard(ctx context.Context, taskID string, storyboard *model.Storyboard, options model.Options, pollCallback PollCallback) (string, error) {
 	logger.Info("Starting video generation with Qiniu",
 		zap.String("task_id", taskID),
 		zap.Int("shots", len(storyboard.Shots)))
 
 	// 将分镜转换为视频生成prompt
-	prompt := s.buildVideoPrompt(storyboard)
+	characters, err := s.characterService.Load(taskID)
+	if err != nil {
+		logger.Debug("No character sheet for task", zap.String("task_id", taskID), zap.Error(err))
+	}
+	prompt := s.buildVideoPrompt(storyboard, characters, options)
 
 	logger.Debug("Generated prompt for video",
 		zap.String("task_id", taskID),
@@ -46,7 +90,7 @@ func (s *QiniuVideoService) GenerateFromStoryboard(ctx context.Context, taskID s
 	// 调用七牛云API生成视频
 	// 注意：七牛云Veo API当前只支持8秒视频
 	videoDuration := 8
-	result, err := s.qiniuClient.GenerateVideo(ctx, prompt, videoDuration)
+	result, err := s.qiniuClient.GenerateVideo(ctx, prompt, videoDuration, options.AspectRatio)
 	if err != nil {
 		return "", fmt.Errorf("failed to start video generation: %w", err)
 	}
@@ -56,7 +100,7 @@ func (s *QiniuVideoService) GenerateFromStoryboard(ctx context.Context, taskID s
 		zap.String("qiniu_task_id", result.ID))
 
 	// 等待视频生成完成
-	result, err = s.qiniuClient.WaitForCompletion(ctx, result.ID, s.maxWaitTime)
+	result, err = s.qiniuClient.WaitForCompletion(ctx, result.ID, s.maxWaitTime, toStatusCallback(pollCallback))
 	if err != nil {
 		return "", fmt.Errorf("video generation failed: %w", err)
 	}
@@ -90,10 +134,244 @@ func (s *QiniuVideoService) GenerateFromStoryboard(ctx context.Context, taskID s
 	return videoPath, nil
 }
 
+// ShotCallback 单个镜头片段完成回调
+type ShotCallback func(shot *model.Shot, completed, total int)
+
+// clipJob 生成单个镜头片段并保存到 clipPath
+type clipJob func(ctx context.Context, shot *model.Shot, clipPath string, pollCallback PollCallback) error
+
+// GenerateShotClips 逐镜头生成视频片段
+// 每个镜头提交一个七牛云任务,同时进行的任务数不超过 maxInFlight;任一镜头失败时取消其余任务。
+// 片段保存在 clips 目录,重试时已下载的片段直接复用。回调在同一时刻只会被一个协程调用
+func (s *QiniuVideoService) GenerateShotClips(ctx context.Context, taskID string, storyboard *model.Storyboard, options model.Options, pollCallback PollCallback, shotCallback ShotCallback) error {
+	logger.Info("Starting per-shot video generation with Qiniu",
+		zap.String("task_id", taskID),
+		zap.Int("shots", len(storyboard.Shots)),
+		zap.Int("max_in_flight", s.maxInFlight))
+
+	characters, err := s.characterService.Load(taskID)
+	if err != nil {
+		logger.Debug("No character sheet for task", zap.String("task_id", taskID), zap.Error(err))
+	}
+
+	job := func(ctx context.Context, shot *model.Shot, clipPath string, pollCallback PollCallback) error {
+		prompt := s.buildShotPrompt(shot, characters, options)
+		return s.generateClip(ctx, taskID, shot.ID, prompt, nil, options.AspectRatio, clipPath, pollCallback)
+	}
+
+	return s.generateClips(ctx, taskID, storyboard, job, false, pollCallback, shotCallback)
+}
+
+// AnimateKeyframes 将镜头关键帧通过图生视频转为视频片段
+// 单个镜头失败不影响其他镜头,失败的镜头不设置VideoPath,渲染时退回静态图运动效果
+func (s *QiniuVideoService) AnimateKeyframes(ctx context.Context, taskID string, storyboard *model.Storyboard, options model.Options, pollCallback PollCallback, shotCallback ShotCallback) error {
+	logger.Info("Starting keyframe animation with Qiniu",
+		zap.String("task_id", taskID),
+		zap.Int("shots", len(storyboard.Shots)),
+		zap.Int("max_in_flight", s.maxInFlight))
+
+	job := func(ctx context.Context, shot *model.Shot, clipPath string, pollCallback PollCallback) error {
+		if shot.ImagePath == "" {
+			return fmt.Errorf("shot has no keyframe")
+		}
+		image, err := os.ReadFile(shot.ImagePath)
+		if err != nil {
+			return fmt.Errorf("failed to read keyframe: %w", err)
+		}
+		prompt := s.buildAnimationPrompt(shot, options)
+		return s.generateClip(ctx, taskID, shot.ID, prompt, image, options.AspectRatio, clipPath, pollCallback)
+	}
+
+	return s.generateClips(ctx, taskID, storyboard, job, true, pollCallback, shotCallback)
+}
+
+// generateClips 并发执行所有镜头的片段任务并更新镜头的VideoPath
+// bestEffort 为false时任一镜头失败即取消其余任务并返回错误;为true时只记录失败
+func (s *QiniuVideoService) generateClips(ctx context.Context, taskID string, storyboard *model.Storyboard, job clipJob, bestEffort bool, pollCallback PollCallback, shotCallback ShotCallback) error {
+	clipsDir := filepath.Join(s.dataDir, "projects", taskID, "clips")
+	if err := utils.EnsureDir(clipsDir); err != nil {
+		return fmt.Errorf("failed to create clips directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		firstErr  error
+		failed    int
+		completed int
+		total     = len(storyboard.Shots)
+		sem       = make(chan struct{}, s.maxInFlight)
+	)
+
+	// 轮询回调来自多个协程,串行化后再交给调用方
+	var poll PollCallback
+	if pollCallback != nil {
+		poll = func(event model.PollEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			pollCallback(event)
+		}
+	}
+
+	done := func(shot *model.Shot) {
+		completed++
+		if shotCallback != nil {
+			shotCallback(shot, completed, total)
+		}
+	}
+
+submit:
+	for i := range storyboard.Shots {
+		shot := &storyboard.Shots[i]
+		clipPath := filepath.Join(clipsDir, fmt.Sprintf("shot_%03d.mp4", shot.ID))
+
+		// 复用已下载的片段
+		if utils.FileExists(clipPath) {
+			logger.Info("Reusing existing clip",
+				zap.String("task_id", taskID),
+				zap.Int("shot_id", shot.ID))
+
+			mu.Lock()
+			shot.VideoPath = clipPath
+			done(shot)
+			mu.Unlock()
+			continue
+		}
+
+		mu.Lock()
+		shot.VideoPath = ""
+		mu.Unlock()
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break submit
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := job(ctx, shot, clipPath, poll)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				shot.VideoPath = clipPath
+			case bestEffort && ctx.Err() == nil:
+				failed++
+				logger.Warn("Failed to generate clip, falling back to still image",
+					zap.String("task_id", taskID),
+					zap.Int("shot_id", shot.ID),
+					zap.Error(err))
+			default:
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to generate clip for shot %d: %w", shot.ID, err)
+					cancel()
+				}
+				return
+			}
+			done(shot)
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 保存更新后的分镜脚本
+	storyboardPath := filepath.Join(s.dataDir, "projects", taskID, "storyboard.json")
+	if err := utils.SaveJSON(storyboardPath, storyboard); err != nil {
+		logger.Warn("Failed to save updated storyboard", zap.Error(err))
+	}
+
+	logger.Info("Shot clips generated",
+		zap.String("task_id", taskID),
+		zap.Int("total_shots", total),
+		zap.Int("failed", failed))
+
+	return nil
+}
+
+// generateClip 生成并下载单个镜头片段,image 非空时以其为首帧图生视频
+func (s *QiniuVideoService) generateClip(ctx context.Context, taskID string, shotID int, prompt string, image []byte, aspectRatio, clipPath string, pollCallback PollCallback) error {
+	var result *client.VideoGenerateResponse
+	var err error
+	if image != nil {
+		result, err = s.qiniuClient.GenerateVideoFromImage(ctx, prompt, image, "image/png", s.clipLength, aspectRatio)
+	} else {
+		result, err = s.qiniuClient.GenerateVideo(ctx, prompt, s.clipLength, aspectRatio)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start video generation: %w", err)
+	}
+
+	logger.Info("Shot clip generation task created",
+		zap.String("task_id", taskID),
+		zap.Int("shot_id", shotID),
+		zap.String("qiniu_task_id", result.ID))
+
+	result, err = s.qiniuClient.WaitForCompletion(ctx, result.ID, s.maxWaitTime, toStatusCallback(pollCallback))
+	if err != nil {
+		return fmt.Errorf("video generation failed: %w", err)
+	}
+
+	videoURL := result.GetVideoURL()
+	if videoURL == "" {
+		return fmt.Errorf("no video URL in response")
+	}
+	videoData, err := s.qiniuClient.DownloadVideo(ctx, videoURL)
+	if err != nil {
+		return fmt.Errorf("failed to download video: %w", err)
+	}
+
+	// 先写临时文件,避免中断时留下不完整的片段被重试复用
+	tmpPath := clipPath + ".tmp"
+	if err := os.WriteFile(tmpPath, videoData, 0644); err != nil {
+		return fmt.Errorf("failed to save clip: %w", err)
+	}
+	if err := os.Rename(tmpPath, clipPath); err != nil {
+		return fmt.Errorf("failed to save clip: %w", err)
+	}
+
+	logger.Info("Shot clip saved",
+		zap.String("task_id", taskID),
+		zap.Int("shot_id", shotID),
+		zap.Int("file_size", len(videoData)))
+
+	return nil
+}
+
+// toStatusCallback 将轮询回调转换为客户端状态回调
+func toStatusCallback(pollCallback PollCallback) client.StatusCallback {
+	if pollCallback == nil {
+		return nil
+	}
+	return func(result *client.VideoGenerateResponse, checkCount int) {
+		pollCallback(model.PollEvent{
+			RemoteID:   result.ID,
+			Status:     result.Status,
+			Message:    result.Message,
+			CheckCount: checkCount,
+		})
+	}
+}
+
 // buildVideoPrompt 从分镜脚本构建视频生成prompt
-func (s *QiniuVideoService) buildVideoPrompt(storyboard *model.Storyboard) string {
+func (s *QiniuVideoService) buildVideoPrompt(storyboard *model.Storyboard, characters *model.CharacterSheet, options model.Options) string {
 	// 构建详细的视频描述
-	prompt := "Create an anime-style video with the following scenes:\n\n"
+	prompt := "Create a video with the following scenes:\n\n"
 
 	for i, shot := range storyboard.Shots {
 		prompt += fmt.Sprintf("Scene %d (%s shot, %.1f seconds):\n", i+1, shot.Type, shot.Duration)
@@ -104,18 +382,72 @@ func (s *QiniuVideoService) buildVideoPrompt(storyboard *model.Storyboard) strin
 				shot.Dialogue.Character, shot.Dialogue.Text, shot.Dialogue.Emotion)
 		}
 
-		if len(shot.Characters) > 0 {
+		if desc := characters.Describe(shot.Characters); desc != "" {
+			prompt += fmt.Sprintf("Characters: %s\n", desc)
+		} else if len(shot.Characters) > 0 {
 			prompt += fmt.Sprintf("Characters: %v\n", shot.Characters)
 		}
 
 		prompt += fmt.Sprintf("Transition: %s\n\n", shot.Transition)
 	}
 
-	prompt += "\nStyle: Japanese anime/manga art style, high quality, cinematic"
+	prompt += "\nStyle: " + s.styles.Get(options.Style).VideoStyle
+
+	prompt += framingLine(options.AspectRatio)
+
+	return prompt
+}
+
+// buildShotPrompt 构建单个镜头的视频生成prompt
+func (s *QiniuVideoService) buildShotPrompt(shot *model.Shot, characters *model.CharacterSheet, options model.Options) string {
+	prompt := fmt.Sprintf("Create a single continuous %s shot:\n%s\n", shot.Type, shot.Description)
+
+	if desc := characters.Describe(shot.Characters); desc != "" {
+		prompt += fmt.Sprintf("Characters: %s\n", desc)
+	} else if len(shot.Characters) > 0 {
+		prompt += fmt.Sprintf("Characters: %v\n", shot.Characters)
+	}
+
+	if shot.Dialogue != nil {
+		prompt += fmt.Sprintf("Dialogue: %s says \"%s\" (%s emotion)\n",
+			shot.Dialogue.Character, shot.Dialogue.Text, shot.Dialogue.Emotion)
+	}
+
+	prompt += "Style: " + s.styles.Get(options.Style).VideoStyle
+	prompt += framingLine(options.AspectRatio)
 
 	return prompt
 }
 
+// buildAnimationPrompt 构建关键帧图生视频的prompt
+func (s *QiniuVideoService) buildAnimationPrompt(shot *model.Shot, options model.Options) string {
+	prompt := fmt.Sprintf("Animate this keyframe as a %s shot with subtle, natural motion. Keep the characters' appearance and the composition unchanged.\n%s\n",
+		shot.Type, shot.Description)
+
+	if shot.Dialogue != nil {
+		prompt += fmt.Sprintf("Dialogue: %s says \"%s\" (%s emotion)\n",
+			shot.Dialogue.Character, shot.Dialogue.Text, shot.Dialogue.Emotion)
+	}
+
+	prompt += "Style: " + s.styles.Get(options.Style).VideoStyle
+	prompt += framingLine(options.AspectRatio)
+
+	return prompt
+}
+
+// framingLine 画面比例对应的构图说明
+func framingLine(aspectRatio string) string {
+	switch aspectRatio {
+	case model.AspectRatioPortrait:
+		return "\nFraming: vertical 9:16 video, portrait composition with subjects centered"
+	case model.AspectRatioSquare:
+		return "\nFraming: square 1:1 video, centered composition"
+	case model.AspectRatioClassic:
+		return "\nFraming: 4:3 video"
+	}
+	return ""
+}
+
 // GenerateSimple 简化版本：直接从文本生成视频
 func (s *QiniuVideoService) GenerateSimple(ctx context.Context, taskID, text string, duration int) (string, error) {
 	logger.Info("Starting simple video generation",
@@ -124,12 +456,12 @@ func (s *QiniuVideoService) GenerateSimple(ctx context.Context, taskID, text str
 		zap.Int("duration", duration))
 
 	// 构建简单prompt
-	prompt := fmt.Sprintf("Create an anime-style video based on this story:\n\n%s\n\nStyle: Japanese anime/manga art style, cinematic, high quality", text)
+	prompt := fmt.Sprintf("Create a video based on this story:\n\n%s\n\nStyle: %s", text, s.styles.Get(style.DefaultStyle).VideoStyle)
 
 	// 调用七牛云API
 	// 注意：七牛云Veo API当前只支持8秒视频
 	videoDuration := 8
-	result, err := s.qiniuClient.GenerateVideo(ctx, prompt, videoDuration)
+	result, err := s.qiniuClient.GenerateVideo(ctx, prompt, videoDuration, "")
 	if err != nil {
 		return "", fmt.Errorf("failed to start video generation: %w", err)
 	}
@@ -139,7 +471,7 @@ func (s *QiniuVideoService) GenerateSimple(ctx context.Context, taskID, text str
 		zap.String("qiniu_task_id", result.ID))
 
 	// 等待完成
-	result, err = s.qiniuClient.WaitForCompletion(ctx, result.ID, s.maxWaitTime)
+	result, err = s.qiniuClient.WaitForCompletion(ctx, result.ID, s.maxWaitTime, nil)
 	if err != nil {
 		return "", fmt.Errorf("video generation failed: %w", err)
 	}
@@ -15,34 +15,79 @@ import (
 
 // RenderService 视频渲染服务
 type RenderService struct {
-	ffmpeg  *ffmpeg.FFmpeg
-	dataDir string
-	fps     int
+	ffmpeg             *ffmpeg.FFmpeg
+	dataDir            string
+	width              int // 基准输出分辨率,按画面比例换算
+	height             int
+	fps                int
+	transitionDuration float64       // 默认转场时长(秒)
+	kenBurns           bool          // 是否为静态镜头自动添加推拉摇移运动
+	voiceService       *VoiceService // 对白配音,为nil时不配音
+	storyboardService  *StoryboardService
 }
 
 // NewRenderService 创建视频渲染服务
-func NewRenderService(dataDir string, fps int) *RenderService {
+// voiceService 为nil时不合成对白配音
+func NewRenderService(dataDir string, width, height, fps int, transitionDuration float64, kenBurns bool, voiceService *VoiceService, storyboardService *StoryboardService) *RenderService {
 	return &RenderService{
-		ffmpeg:  ffmpeg.New(),
-		dataDir: dataDir,
-		fps:     fps,
+		ffmpeg:             ffmpeg.New(),
+		dataDir:            dataDir,
+		width:              width,
+		height:             height,
+		fps:                fps,
+		transitionDuration: transitionDuration,
+		kenBurns:           kenBurns,
+		voiceService:       voiceService,
+		storyboardService:  storyboardService,
 	}
 }
 
+// Canvas 画面比例对应的输出分辨率
+func (s *RenderService) Canvas(aspectRatio string) (int, int) {
+	width, height, err := utils.FitAspectRatio(s.width, s.height, aspectRatio)
+	if err != nil {
+		return s.width, s.height
+	}
+	return width, height
+}
+
 // Render 渲染视频
-func (s *RenderService) Render(ctx context.Context, taskID string, storyboard *model.Storyboard, bgmPath string) (*model.Result, error) {
+// 开启配音时先合成对白,再按配音实际时长调整镜头时长并保存分镜
+func (s *RenderService) Render(ctx context.Context, taskID string, storyboard *model.Storyboard, options model.Options) (*model.Result, error) {
+	aspectRatio, bgmPath := options.AspectRatio, options.BGM
+	width, height := s.Canvas(aspectRatio)
+
 	logger.Info("Starting video rendering",
 		zap.String("task_id", taskID),
 		zap.Int("shots", len(storyboard.Shots)),
+		zap.String("aspect_ratio", aspectRatio),
 		zap.String("bgm", bgmPath))
 
 	projectDir := filepath.Join(s.dataDir, "projects", taskID)
 	outputPath := filepath.Join(projectDir, "output.mp4")
 
-	// 生成FFmpeg concat文件
-	concatFile := filepath.Join(projectDir, "concat.txt")
-	if err := s.generateConcatFile(concatFile, storyboard); err != nil {
-		return nil, fmt.Errorf("failed to generate concat file: %w", err)
+	// 合成对白配音,镜头时长需要容纳配音
+	var voices []ShotVoice
+	if s.voiceService != nil {
+		var err error
+		voices, err = s.voiceService.Synthesize(ctx, taskID, storyboard)
+		if err != nil {
+			return nil, fmt.Errorf("failed to synthesize voice-over: %w", err)
+		}
+
+		lengths := make(map[int]float64, len(voices))
+		for _, voice := range voices {
+			lengths[voice.ShotID] = voice.Length
+		}
+		if err := s.storyboardService.Pace(taskID, storyboard, options, lengths); err != nil {
+			return nil, err
+		}
+	}
+
+	// 构建带转场的图片片段
+	clips, err := s.buildClips(storyboard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build clips: %w", err)
 	}
 
 	// 检查BGM文件是否存在
@@ -57,8 +102,14 @@ func (s *RenderService) Render(ctx context.Context, taskID string, storyboard *m
 		}
 	}
 
+	audio := ffmpeg.Audio{BGMPath: bgmPath}
+	if s.voiceService != nil {
+		audio.Voices = VoiceClips(storyboard, voices)
+		audio.Ducking = s.voiceService.Ducking()
+	}
+
 	// 使用FFmpeg合成视频
-	if err := s.ffmpeg.ConcatVideosFromImages(ctx, concatFile, bgmPath, outputPath, s.fps); err != nil {
+	if err := s.ffmpeg.RenderClips(ctx, clips, audio, outputPath, width, height, s.fps); err != nil {
 		return nil, fmt.Errorf("failed to render video: %w", err)
 	}
 
@@ -79,7 +130,7 @@ func (s *RenderService) Render(ctx context.Context, taskID string, storyboard *m
 	result := &model.Result{
 		VideoPath:    outputPath,
 		Duration:     storyboard.TotalDuration,
-		Resolution:   "1920x1080",
+		Resolution:   fmt.Sprintf("%dx%d", width, height),
 		FileSize:     fileSize,
 		ThumbnailURL: thumbnailPath,
 		ShotCount:    len(storyboard.Shots),
@@ -94,42 +145,66 @@ func (s *RenderService) Render(ctx context.Context, taskID string, storyboard *m
 	return result, nil
 }
 
-// generateConcatFile 生成FFmpeg concat文件
-func (s *RenderService) generateConcatFile(path string, storyboard *model.Storyboard) error {
-	logger.Debug("Generating concat file", zap.String("path", path))
-
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create concat file: %w", err)
+// buildClips 将分镜镜头转换为FFmpeg片段,有视频片段的镜头优先使用视频
+// 镜头的Transition表示从上一个镜头进入该镜头的转场
+func (s *RenderService) buildClips(storyboard *model.Storyboard) ([]ffmpeg.Clip, error) {
+	if len(storyboard.Shots) == 0 {
+		return nil, fmt.Errorf("storyboard has no shots")
 	}
-	defer f.Close()
 
-	// FFmpeg concat文件格式:
-	// file 'path/to/image.png'
-	// duration 3.0
-	for i, shot := range storyboard.Shots {
-		if shot.ImagePath == "" {
-			return fmt.Errorf("shot %d has no image path", shot.ID)
+	clips := make([]ffmpeg.Clip, 0, len(storyboard.Shots))
+	for _, shot := range storyboard.Shots {
+		if shot.ImagePath == "" && shot.VideoPath == "" {
+			return nil, fmt.Errorf("shot %d has no image or video path", shot.ID)
 		}
 
-		// 写入文件路径
-		fmt.Fprintf(f, "file '%s'\n", shot.ImagePath)
-
-		// 写入持续时间(最后一帧除外)
-		if i < len(storyboard.Shots)-1 {
-			fmt.Fprintf(f, "duration %.2f\n", shot.Duration)
+		transitionDuration := shot.TransitionDuration
+		if transitionDuration <= 0 {
+			transitionDuration = s.transitionDuration
 		}
+
+		clips = append(clips, ffmpeg.Clip{
+			ImagePath:          shot.ImagePath,
+			VideoPath:          shot.VideoPath,
+			Duration:           shot.Duration,
+			Transition:         shot.Transition,
+			TransitionDuration: transitionDuration,
+			Motion:             s.motionFor(&shot),
+		})
 	}
 
-	// 最后一帧需要重复一次(FFmpeg concat协议要求)
-	lastShot := storyboard.Shots[len(storyboard.Shots)-1]
-	fmt.Fprintf(f, "file '%s'\n", lastShot.ImagePath)
+	logger.Debug("Clips built",
+		zap.Int("shots", len(clips)),
+		zap.Float64("transition_duration", s.transitionDuration))
+
+	return clips, nil
+}
 
-	logger.Debug("Concat file generated successfully",
-		zap.String("path", path),
-		zap.Int("shots", len(storyboard.Shots)))
+// motionFor 选择镜头的运动预设
+// 分镜中指定的预设优先;否则特写推近,远景横摇,中景交替推拉。
+// 交替方向只取决于镜头ID,重新渲染时结果一致
+func (s *RenderService) motionFor(shot *model.Shot) string {
+	if shot.Motion != "" {
+		return shot.Motion
+	}
+	if !s.kenBurns {
+		return model.MotionNone
+	}
 
-	return nil
+	switch shot.Type {
+	case model.ShotTypeCloseup:
+		return model.MotionZoomIn
+	case model.ShotTypeLong:
+		if shot.ID%2 == 0 {
+			return model.MotionPanLeft
+		}
+		return model.MotionPanRight
+	default:
+		if shot.ID%2 == 0 {
+			return model.MotionZoomOut
+		}
+		return model.MotionZoomIn
+	}
 }
 
 // GenerateSubtitles 生成字幕文件(SRT格式)
@@ -194,11 +269,11 @@ func formatSRTTime(seconds float64) string {
 }
 
 // RenderWithSubtitles 渲染带字幕的视频
-func (s *RenderService) RenderWithSubtitles(ctx context.Context, taskID string, storyboard *model.Storyboard, bgmPath string) (*model.Result, error) {
+func (s *RenderService) RenderWithSubtitles(ctx context.Context, taskID string, storyboard *model.Storyboard, options model.Options) (*model.Result, error) {
 	logger.Info("Rendering video with subtitles", zap.String("task_id", taskID))
 
 	// 先渲染基础视频
-	result, err := s.Render(ctx, taskID, storyboard, bgmPath)
+	result, err := s.Render(ctx, taskID, storyboard, options)
 	if err != nil {
 		return nil, err
 	}
@@ -234,3 +309,65 @@ func (s *RenderService) RenderWithSubtitles(ctx context.Context, taskID string,
 
 	return result, nil
 }
+
+// ConcatEpisodes 将剧集各集视频按顺序拼接为完整视频,保存到 data_dir/series/<series_id>/series.mp4
+// 已有的合并视频比所有分集视频都新时直接复用
+func (s *RenderService) ConcatEpisodes(ctx context.Context, seriesID string, episodes []*model.Result) (*model.Result, error) {
+	seriesDir := filepath.Join(s.dataDir, "series", seriesID)
+	if err := utils.EnsureDir(seriesDir); err != nil {
+		return nil, fmt.Errorf("failed to create series directory: %w", err)
+	}
+	outputPath := filepath.Join(seriesDir, "series.mp4")
+
+	result := &model.Result{VideoPath: outputPath}
+	inputs := make([]string, 0, len(episodes))
+	stale := true
+	if info, err := os.Stat(outputPath); err == nil {
+		stale = false
+		for _, episode := range episodes {
+			if episodeInfo, err := os.Stat(episode.VideoPath); err != nil || episodeInfo.ModTime().After(info.ModTime()) {
+				stale = true
+				break
+			}
+		}
+	}
+	for _, episode := range episodes {
+		inputs = append(inputs, episode.VideoPath)
+		result.Duration += episode.Duration
+		result.ShotCount += episode.ShotCount
+		if result.Resolution == "" {
+			result.Resolution = episode.Resolution
+		}
+	}
+
+	// 先拼接到临时文件再重命名,并发下载时不会同时写同一个文件,也不会读到拼接了一半的视频
+	if stale {
+		tmp, err := os.CreateTemp(seriesDir, "series.*.mp4")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create series video: %w", err)
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+
+		if err := s.ffmpeg.ConcatFiles(ctx, inputs, tmp.Name(), s.fps); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp.Name(), outputPath); err != nil {
+			return nil, fmt.Errorf("failed to save series video: %w", err)
+		}
+	}
+
+	fileSize, err := utils.GetFileSize(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat series video: %w", err)
+	}
+	result.FileSize = fileSize
+
+	logger.Info("Series video ready",
+		zap.String("series_id", seriesID),
+		zap.Int("episodes", len(episodes)),
+		zap.Bool("rebuilt", stale),
+		zap.Int64("file_size", fileSize))
+
+	return result, nil
+}
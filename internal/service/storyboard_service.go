@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 
 	"github.com/Jancd/1504/internal/client"
 	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/style"
 	"github.com/Jancd/1504/pkg/logger"
 	"github.com/Jancd/1504/pkg/utils"
 	"go.uber.org/zap"
@@ -14,27 +16,32 @@ import (
 
 // StoryboardService 分镜生成服务
 type StoryboardService struct {
-	openaiClient *client.OpenAIClient
-	dataDir      string
+	llmClient *client.LLMClient
+	styles    *style.Registry
+	pacer     *Pacer
+	dataDir   string
 }
 
 // NewStoryboardService 创建分镜生成服务
-func NewStoryboardService(openaiClient *client.OpenAIClient, dataDir string) *StoryboardService {
+func NewStoryboardService(llmClient *client.LLMClient, styles *style.Registry, pacer *Pacer, dataDir string) *StoryboardService {
 	return &StoryboardService{
-		openaiClient: openaiClient,
-		dataDir:      dataDir,
+		llmClient: llmClient,
+		styles:    styles,
+		pacer:     pacer,
+		dataDir:   dataDir,
 	}
 }
 
-// Generate 生成分镜脚本
-func (s *StoryboardService) Generate(ctx context.Context, taskID string, parsed *model.ParsedScript, targetDuration int) (*model.Storyboard, error) {
+// Generate 生成分镜脚本,episode 非空时按剧集分集设计回顾和悬念镜头
+func (s *StoryboardService) Generate(ctx context.Context, taskID string, parsed *model.ParsedScript, options model.Options, episode *model.Episode) (*model.Storyboard, error) {
 	logger.Info("Starting storyboard generation",
 		zap.String("task_id", taskID),
 		zap.Int("scenes", len(parsed.Scenes)),
-		zap.Int("target_duration", targetDuration))
+		zap.Int("target_duration", options.DurationTarget),
+		zap.String("aspect_ratio", options.AspectRatio))
 
-	// 调用OpenAI生成分镜
-	storyboard, err := s.openaiClient.GenerateStoryboard(ctx, parsed, targetDuration)
+	// 调用LLM生成分镜
+	storyboard, err := s.llmClient.GenerateStoryboard(ctx, parsed, options.DurationTarget, options.AspectRatio, episode)
 	if err != nil {
 		logger.Error("Failed to generate storyboard", zap.String("task_id", taskID), zap.Error(err))
 		return nil, fmt.Errorf("failed to generate storyboard: %w", err)
@@ -44,10 +51,13 @@ func (s *StoryboardService) Generate(ctx context.Context, taskID string, parsed
 	for i := range storyboard.Shots {
 		shot := &storyboard.Shots[i]
 		if shot.Prompt == "" {
-			shot.Prompt = s.generateImagePrompt(shot)
+			shot.Prompt = s.generateImagePrompt(shot, options)
 		}
 	}
 
+	// 按对白阅读时长调整镜头时长
+	s.pacer.Apply(storyboard, nil, float64(options.DurationTarget))
+
 	// 保存分镜脚本
 	projectDir := filepath.Join(s.dataDir, "projects", taskID)
 	storyboardPath := filepath.Join(projectDir, "storyboard.json")
@@ -63,10 +73,143 @@ func (s *StoryboardService) Generate(ctx context.Context, taskID string, parsed
 	return storyboard, nil
 }
 
+// ErrInvalidStoryboard 分镜脚本内容不合法
+var ErrInvalidStoryboard = errors.New("invalid storyboard")
+
+// Save 校验并保存人工编辑后的分镜脚本
+// 缺失的镜头ID按顺序补齐,缺失的Prompt根据画面描述重新生成,总时长按镜头时长重新计算
+// 镜头的图像和片段路径不接受客户端提交的值,只按镜头ID沿用已保存分镜中的路径
+func (s *StoryboardService) Save(taskID string, storyboard *model.Storyboard, options model.Options, maxShots int) error {
+	if len(storyboard.Shots) == 0 {
+		return fmt.Errorf("%w: storyboard must contain at least one shot", ErrInvalidStoryboard)
+	}
+	if maxShots > 0 && len(storyboard.Shots) > maxShots {
+		return fmt.Errorf("%w: too many shots (%d), maximum is %d", ErrInvalidStoryboard, len(storyboard.Shots), maxShots)
+	}
+
+	// 新增镜头没有ID时,从现有最大ID之后编号
+	maxID := 0
+	for _, shot := range storyboard.Shots {
+		if shot.ID > maxID {
+			maxID = shot.ID
+		}
+	}
+
+	stored := make(map[int]model.Shot)
+	if existing, err := s.Load(taskID); err == nil {
+		for _, shot := range existing.Shots {
+			stored[shot.ID] = shot
+		}
+	}
+
+	seen := make(map[int]bool)
+	total := 0.0
+	for i := range storyboard.Shots {
+		shot := &storyboard.Shots[i]
+
+		if shot.ID <= 0 {
+			maxID++
+			shot.ID = maxID
+		}
+		if seen[shot.ID] {
+			return fmt.Errorf("%w: duplicate shot id %d", ErrInvalidStoryboard, shot.ID)
+		}
+		seen[shot.ID] = true
+
+		// 渲染时会读取这两个路径,不能由客户端指定
+		shot.ImagePath, shot.VideoPath = stored[shot.ID].ImagePath, stored[shot.ID].VideoPath
+
+		switch shot.Type {
+		case model.ShotTypeCloseup, model.ShotTypeMedium, model.ShotTypeLong:
+		default:
+			return fmt.Errorf("%w: shot %d has invalid type %q", ErrInvalidStoryboard, shot.ID, shot.Type)
+		}
+
+		switch shot.Transition {
+		case "":
+			shot.Transition = model.TransitionCut
+		case model.TransitionCut, model.TransitionFade, model.TransitionDissolve:
+		default:
+			return fmt.Errorf("%w: shot %d has invalid transition %q", ErrInvalidStoryboard, shot.ID, shot.Transition)
+		}
+
+		switch shot.Motion {
+		case "", model.MotionNone, model.MotionZoomIn, model.MotionZoomOut,
+			model.MotionPanLeft, model.MotionPanRight, model.MotionTiltUp, model.MotionTiltDown:
+		default:
+			return fmt.Errorf("%w: shot %d has invalid motion %q", ErrInvalidStoryboard, shot.ID, shot.Motion)
+		}
+
+		if shot.Duration <= 0 {
+			return fmt.Errorf("%w: shot %d must have a positive duration", ErrInvalidStoryboard, shot.ID)
+		}
+		if shot.Description == "" {
+			return fmt.Errorf("%w: shot %d has no description", ErrInvalidStoryboard, shot.ID)
+		}
+		if shot.Dialogue != nil && shot.Dialogue.Text == "" {
+			shot.Dialogue = nil
+		}
+
+		if shot.Prompt == "" {
+			shot.Prompt = s.generateImagePrompt(shot, options)
+		}
+
+		total += shot.Duration
+	}
+	storyboard.TotalDuration = total
+
+	storyboardPath := filepath.Join(s.dataDir, "projects", taskID, "storyboard.json")
+	if err := utils.SaveJSON(storyboardPath, storyboard); err != nil {
+		return fmt.Errorf("failed to save storyboard: %w", err)
+	}
+
+	logger.Info("Storyboard updated",
+		zap.String("task_id", taskID),
+		zap.Int("shots", len(storyboard.Shots)),
+		zap.Float64("total_duration", storyboard.TotalDuration))
+
+	return nil
+}
+
+// Pace 按配音时长调整镜头时长并保存分镜
+// 经过人工审核的分镜保留审核确定的时长,只延长容纳不下配音的镜头,不再向目标时长重新分配
+func (s *StoryboardService) Pace(taskID string, storyboard *model.Storyboard, options model.Options, voiceLengths map[int]float64) error {
+	target := float64(options.DurationTarget)
+	if options.ReviewStoryboard {
+		target = 0
+	}
+	s.pacer.Apply(storyboard, voiceLengths, target)
+
+	storyboardPath := filepath.Join(s.dataDir, "projects", taskID, "storyboard.json")
+	if err := utils.SaveJSON(storyboardPath, storyboard); err != nil {
+		return fmt.Errorf("failed to save storyboard: %w", err)
+	}
+
+	logger.Info("Storyboard paced to voice-over",
+		zap.String("task_id", taskID),
+		zap.Int("voices", len(voiceLengths)),
+		zap.Float64("total_duration", storyboard.TotalDuration))
+
+	return nil
+}
+
+// Load 加载已保存的分镜脚本
+func (s *StoryboardService) Load(taskID string) (*model.Storyboard, error) {
+	storyboardPath := filepath.Join(s.dataDir, "projects", taskID, "storyboard.json")
+
+	var storyboard model.Storyboard
+	if err := utils.LoadJSON(storyboardPath, &storyboard); err != nil {
+		return nil, fmt.Errorf("failed to load storyboard: %w", err)
+	}
+	return &storyboard, nil
+}
+
 // generateImagePrompt 生成AI绘图Prompt
-func (s *StoryboardService) generateImagePrompt(shot *model.Shot) string {
+func (s *StoryboardService) generateImagePrompt(shot *model.Shot, options model.Options) string {
+	preset := s.styles.Get(options.Style)
+
 	// 基础风格描述
-	basePrompt := "anime style, manga, japanese animation, high quality, detailed, cinematic, "
+	basePrompt := preset.PromptPrefix + ", "
 
 	// 添加镜头类型
 	switch shot.Type {
@@ -93,15 +236,23 @@ func (s *StoryboardService) generateImagePrompt(shot *model.Shot) string {
 		basePrompt += fmt.Sprintf(", %s atmosphere", shot.Dialogue.Emotion)
 	}
 
-	// 添加通用质量标签
-	basePrompt += ", professional artwork, trending on pixiv"
+	// 添加构图方向
+	switch options.AspectRatio {
+	case model.AspectRatioPortrait:
+		basePrompt += ", vertical composition, portrait orientation"
+	case model.AspectRatioSquare:
+		basePrompt += ", square composition, centered subject"
+	}
+
+	// 添加风格质量标签
+	if preset.PromptSuffix != "" {
+		basePrompt += ", " + preset.PromptSuffix
+	}
 
 	return basePrompt
 }
 
 // GenerateNegativePrompt 生成负面Prompt
-func (s *StoryboardService) GenerateNegativePrompt() string {
-	return "low quality, blurry, distorted, ugly, bad anatomy, bad proportions, " +
-		"bad hands, text, error, missing fingers, extra digit, fewer digits, " +
-		"cropped, worst quality, jpeg artifacts, signature, watermark, username"
+func (s *StoryboardService) GenerateNegativePrompt(styleName string) string {
+	return s.styles.Get(styleName).NegativePrompt
 }
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/tts"
+	"github.com/Jancd/1504/pkg/ffmpeg"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// VoiceService 对白配音服务
+type VoiceService struct {
+	provider         tts.Provider
+	characterService *CharacterService
+	ffmpeg           *ffmpeg.FFmpeg
+	dataDir          string
+	defaultVoice     string
+	voices           map[string]string // 角色名(小写) -> 音色
+	ducking          float64
+}
+
+// NewVoiceService 创建对白配音服务
+func NewVoiceService(provider tts.Provider, characterService *CharacterService, dataDir, defaultVoice string, voices map[string]string, ducking float64) *VoiceService {
+	// viper读取的map键为小写,统一按小写匹配
+	normalized := make(map[string]string, len(voices))
+	for name, voice := range voices {
+		normalized[strings.ToLower(name)] = voice
+	}
+
+	return &VoiceService{
+		provider:         provider,
+		characterService: characterService,
+		ffmpeg:           ffmpeg.New(),
+		dataDir:          dataDir,
+		defaultVoice:     defaultVoice,
+		voices:           normalized,
+		ducking:          ducking,
+	}
+}
+
+// Ducking 对白期间BGM压低的比例
+func (s *VoiceService) Ducking() float64 {
+	return s.ducking
+}
+
+// ShotVoice 镜头对白配音
+type ShotVoice struct {
+	ShotID int
+	Path   string
+	Length float64 // 音频时长(秒)
+}
+
+// Synthesize 为所有带对白的镜头合成配音
+// 配音文件名包含文本和音色的摘要,重试时未变化的对白直接复用
+func (s *VoiceService) Synthesize(ctx context.Context, taskID string, storyboard *model.Storyboard) ([]ShotVoice, error) {
+	voiceDir := filepath.Join(s.dataDir, "projects", taskID, "voice")
+	if err := utils.EnsureDir(voiceDir); err != nil {
+		return nil, fmt.Errorf("failed to create voice directory: %w", err)
+	}
+
+	characters, err := s.characterService.Load(taskID)
+	if err != nil {
+		logger.Debug("No character sheet for task", zap.String("task_id", taskID), zap.Error(err))
+	}
+
+	var voices []ShotVoice
+	for _, shot := range storyboard.Shots {
+		if shot.Dialogue == nil || strings.TrimSpace(shot.Dialogue.Text) == "" {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		req := tts.Request{
+			Text:    shot.Dialogue.Text,
+			Voice:   s.voiceFor(shot.Dialogue.Character, characters),
+			Emotion: shot.Dialogue.Emotion,
+		}
+
+		path := filepath.Join(voiceDir, fmt.Sprintf("shot_%03d_%s.%s", shot.ID, requestDigest(req), s.provider.Format()))
+		if !utils.FileExists(path) {
+			logger.Info("Synthesizing dialogue",
+				zap.String("task_id", taskID),
+				zap.Int("shot_id", shot.ID),
+				zap.String("character", shot.Dialogue.Character),
+				zap.String("voice", req.Voice))
+
+			// 先写入临时文件,避免中断后留下不完整的音频被复用
+			tmpPath := path + ".tmp." + s.provider.Format()
+			if err := s.provider.Synthesize(ctx, req, tmpPath); err != nil {
+				os.Remove(tmpPath)
+				return nil, fmt.Errorf("failed to synthesize shot %d: %w", shot.ID, err)
+			}
+			if err := os.Rename(tmpPath, path); err != nil {
+				return nil, fmt.Errorf("failed to save voice for shot %d: %w", shot.ID, err)
+			}
+		}
+
+		length, err := s.ffmpeg.ProbeDuration(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to probe voice for shot %d: %w", shot.ID, err)
+		}
+
+		voices = append(voices, ShotVoice{
+			ShotID: shot.ID,
+			Path:   path,
+			Length: length,
+		})
+	}
+
+	logger.Info("Dialogue synthesized",
+		zap.String("task_id", taskID),
+		zap.Int("clips", len(voices)))
+
+	return voices, nil
+}
+
+// VoiceClips 将配音定位到镜头时间线
+// 每段配音从所属镜头开始播放,超出镜头时长的部分由FFmpeg加速或截断
+func VoiceClips(storyboard *model.Storyboard, voices []ShotVoice) []ffmpeg.VoiceClip {
+	byShot := make(map[int]ShotVoice, len(voices))
+	for _, voice := range voices {
+		byShot[voice.ShotID] = voice
+	}
+
+	var clips []ffmpeg.VoiceClip
+	start := 0.0
+	for _, shot := range storyboard.Shots {
+		if voice, ok := byShot[shot.ID]; ok {
+			clips = append(clips, ffmpeg.VoiceClip{
+				Path:        voice.Path,
+				Start:       start,
+				Length:      voice.Length,
+				MaxDuration: shot.Duration,
+			})
+		}
+		start += shot.Duration
+	}
+	return clips
+}
+
+// voiceFor 选择角色音色: 角色设定表 > 配置映射 > 默认音色
+func (s *VoiceService) voiceFor(name string, characters *model.CharacterSheet) string {
+	if c := characters.Find(name); c != nil && c.Voice != "" {
+		return c.Voice
+	}
+	if voice, ok := s.voices[strings.ToLower(name)]; ok {
+		return voice
+	}
+	return s.defaultVoice
+}
+
+// requestDigest 合成请求摘要,用于识别对白是否变化
+func requestDigest(req tts.Request) string {
+	sum := sha256.Sum256([]byte(req.Voice + "\x00" + req.Emotion + "\x00" + req.Text))
+	return hex.EncodeToString(sum[:4])
+}
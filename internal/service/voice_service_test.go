@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/internal/tts"
+)
+
+func TestVoiceFor(t *testing.T) {
+	s := NewVoiceService(nil, nil, t.TempDir(), "alloy", map[string]string{"Alice": "nova", "老王": "onyx"}, 0.5)
+	characters := &model.CharacterSheet{Characters: []model.Character{
+		{Name: "Alice", Voice: "shimmer"},
+		{Name: "Bob"},
+	}}
+
+	tests := []struct {
+		name       string
+		character  string
+		characters *model.CharacterSheet
+		want       string
+	}{
+		{name: "character sheet wins", character: "Alice", characters: characters, want: "shimmer"},
+		{name: "config mapping ignores case", character: "alice", want: "nova"},
+		{name: "config mapping for sheet without voice", character: "老王", characters: characters, want: "onyx"},
+		{name: "default voice", character: "Bob", characters: characters, want: "alloy"},
+		{name: "narration", want: "alloy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.voiceFor(tt.character, tt.characters); got != tt.want {
+				t.Errorf("voiceFor(%q) = %q, want %q", tt.character, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestDigest(t *testing.T) {
+	base := tts.Request{Text: "你好", Voice: "alloy", Emotion: "calm"}
+	if requestDigest(base) != requestDigest(base) {
+		t.Error("digest is not stable")
+	}
+
+	for _, changed := range []tts.Request{
+		{Text: "你好!", Voice: "alloy", Emotion: "calm"},
+		{Text: "你好", Voice: "nova", Emotion: "calm"},
+		{Text: "你好", Voice: "alloy", Emotion: "sad"},
+	} {
+		if requestDigest(changed) == requestDigest(base) {
+			t.Errorf("digest of %+v equals base", changed)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 回调请求头
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // sha256=<hex(HMAC-SHA256(secret, body))>
+	WebhookEventHeader     = "X-Webhook-Event"     // 任务状态: completed, failed
+)
+
+// AttemptCallback 每次投递尝试后的回调
+type AttemptCallback func(attempt model.CallbackAttempt)
+
+// WebhookService 任务回调服务
+type WebhookService struct {
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+}
+
+// NewWebhookService 创建任务回调服务
+func NewWebhookService(timeoutSec, maxAttempts, initialBackoffSec int) *WebhookService {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	return &WebhookService{
+		client: &http.Client{
+			Timeout: time.Duration(timeoutSec) * time.Second,
+		},
+		maxAttempts:    maxAttempts,
+		initialBackoff: time.Duration(initialBackoffSec) * time.Second,
+	}
+}
+
+// MaxDuration 一次投递最长耗时: 所有尝试的请求超时加上各次重试间隔
+func (s *WebhookService) MaxDuration() time.Duration {
+	total := time.Duration(s.maxAttempts) * s.client.Timeout
+	backoff := s.initialBackoff
+	for attempt := 1; attempt < s.maxAttempts; attempt++ {
+		total += backoff
+		backoff *= 2
+	}
+	return total
+}
+
+// Deliver 将任务POST到回调地址,失败时按指数退避重试
+func (s *WebhookService) Deliver(ctx context.Context, callbackURL, secret string, t *model.Task, attemptCallback AttemptCallback) error {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	backoff := s.initialBackoff
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		record := s.send(ctx, callbackURL, secret, t.Status, body)
+		record.Attempt = attempt
+
+		if attemptCallback != nil {
+			attemptCallback(record)
+		}
+
+		if record.Success {
+			logger.Info("Webhook delivered",
+				zap.String("task_id", t.ID),
+				zap.String("url", callbackURL),
+				zap.Int("attempt", attempt))
+			return nil
+		}
+
+		logger.Warn("Webhook delivery failed",
+			zap.String("task_id", t.ID),
+			zap.String("url", callbackURL),
+			zap.Int("attempt", attempt),
+			zap.Int("status_code", record.StatusCode),
+			zap.String("error", record.Error))
+
+		if attempt == s.maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return fmt.Errorf("webhook delivery failed after %d attempts", s.maxAttempts)
+}
+
+// send 发送一次回调请求
+func (s *WebhookService) send(ctx context.Context, callbackURL, secret, event string, body []byte) model.CallbackAttempt {
+	record := model.CallbackAttempt{Timestamp: time.Now()}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", callbackURL, bytes.NewReader(body))
+	if err != nil {
+		record.Error = fmt.Sprintf("failed to create request: %v", err)
+		return record
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	record.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		record.Success = true
+	} else {
+		record.Error = fmt.Sprintf("callback returned status %d", resp.StatusCode)
+	}
+	return record
+}
+
+// SignWebhook 计算回调请求体的HMAC-SHA256签名(十六进制)
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		{
+			secret: "key",
+			body:   "The quick brown fox jumps over the lazy dog",
+			want:   "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		},
+		{
+			secret: "",
+			body:   "",
+			want:   "b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("SignWebhook(%q, %q) = %s, want %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		statuses []int // 每次请求的响应状态码
+		attempts int
+		wantErr  bool
+	}{
+		{name: "first attempt succeeds", secret: "s3cret", statuses: []int{200}, attempts: 1},
+		{name: "retries until success", statuses: []int{500, 503, 204}, attempts: 3},
+		{name: "gives up after max attempts", secret: "s3cret", statuses: []int{500, 500, 500}, attempts: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				signature := r.Header.Get(WebhookSignatureHeader)
+				if tt.secret == "" && signature != "" {
+					t.Errorf("unexpected signature %q without secret", signature)
+				}
+				if tt.secret != "" && signature != "sha256="+SignWebhook(tt.secret, body) {
+					t.Errorf("signature = %q does not match body", signature)
+				}
+				if event := r.Header.Get(WebhookEventHeader); event != model.TaskStatusCompleted {
+					t.Errorf("event header = %q, want completed", event)
+				}
+
+				mu.Lock()
+				status := tt.statuses[requests]
+				requests++
+				mu.Unlock()
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			s := &WebhookService{client: server.Client(), maxAttempts: 3, initialBackoff: time.Millisecond}
+
+			var records []model.CallbackAttempt
+			err := s.Deliver(context.Background(), server.URL, tt.secret,
+				&model.Task{ID: "t", Status: model.TaskStatusCompleted},
+				func(attempt model.CallbackAttempt) { records = append(records, attempt) })
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Deliver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(records) != tt.attempts {
+				t.Fatalf("recorded %d attempts, want %d", len(records), tt.attempts)
+			}
+			for i, record := range records {
+				if record.Attempt != i+1 || record.StatusCode != tt.statuses[i] {
+					t.Errorf("attempt %d = %+v, want status %d", i+1, record, tt.statuses[i])
+				}
+				if success := tt.statuses[i] < 300; record.Success != success {
+					t.Errorf("attempt %d success = %v, want %v", i+1, record.Success, success)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookDeliverStopsOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	s := &WebhookService{client: server.Client(), maxAttempts: 5, initialBackoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		done <- s.Deliver(ctx, server.URL, "", &model.Task{ID: "t"}, func(model.CallbackAttempt) {
+			attempts++
+			cancel()
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Deliver() error = %v, want context.Canceled", err)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Deliver() kept waiting after cancel")
+	}
+}
+
+func TestWebhookMaxDuration(t *testing.T) {
+	tests := []struct {
+		timeout, attempts, backoff int
+		want                       time.Duration
+	}{
+		{timeout: 10, attempts: 1, backoff: 2, want: 10 * time.Second},
+		// 5次请求超时 + 2+4+8+16秒重试间隔
+		{timeout: 10, attempts: 5, backoff: 2, want: 80 * time.Second},
+		{timeout: 3, attempts: 0, backoff: 1, want: 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		s := NewWebhookService(tt.timeout, tt.attempts, tt.backoff)
+		if got := s.MaxDuration(); got != tt.want {
+			t.Errorf("MaxDuration(%d, %d, %d) = %v, want %v", tt.timeout, tt.attempts, tt.backoff, got, tt.want)
+		}
+	}
+}
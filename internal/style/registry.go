@@ -0,0 +1,153 @@
+package style
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// DefaultStyle 默认风格
+const DefaultStyle = "anime"
+
+// Preset 风格预设
+type Preset struct {
+	Name           string  `mapstructure:"name" json:"name"`
+	DisplayName    string  `mapstructure:"display_name" json:"display_name"`
+	Description    string  `mapstructure:"description" json:"description"`
+	PromptPrefix   string  `mapstructure:"prompt_prefix" json:"prompt_prefix"`     // 图像Prompt前缀
+	PromptSuffix   string  `mapstructure:"prompt_suffix" json:"prompt_suffix"`     // 图像Prompt后缀
+	NegativePrompt string  `mapstructure:"negative_prompt" json:"negative_prompt"` // 负面Prompt
+	Sampler        string  `mapstructure:"sampler" json:"sampler"`                 // SD采样器
+	Steps          int     `mapstructure:"steps" json:"steps"`                     // SD采样步数
+	CFGScale       float64 `mapstructure:"cfg_scale" json:"cfg_scale"`             // SD CFG
+	VideoStyle     string  `mapstructure:"video_style" json:"video_style"`         // 视频模型风格描述句
+}
+
+// builtinAnime 内置的anime风格,风格目录不存在时使用
+var builtinAnime = Preset{
+	Name:         DefaultStyle,
+	DisplayName:  "日式动漫",
+	Description:  "日式动画/漫画风格",
+	PromptPrefix: "anime style, manga, japanese animation, high quality, detailed, cinematic",
+	PromptSuffix: "professional artwork, trending on pixiv",
+	NegativePrompt: "low quality, blurry, distorted, ugly, bad anatomy, bad proportions, " +
+		"bad hands, text, error, missing fingers, extra digit, fewer digits, " +
+		"cropped, worst quality, jpeg artifacts, signature, watermark, username",
+	Sampler:    "DPM++ 2M Karras",
+	Steps:      30,
+	CFGScale:   7.5,
+	VideoStyle: "Japanese anime/manga art style, high quality, cinematic",
+}
+
+// Registry 风格预设注册表
+type Registry struct {
+	presets map[string]*Preset
+}
+
+// NewRegistry 从目录加载所有 *.yaml 风格预设
+// 目录不存在时只包含内置的anime风格
+func NewRegistry(dir string) (*Registry, error) {
+	r := &Registry{
+		presets: make(map[string]*Preset),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list style presets: %w", err)
+	}
+
+	for _, file := range files {
+		preset, err := loadPreset(file)
+		if err != nil {
+			return nil, err
+		}
+		r.presets[preset.Name] = preset
+	}
+
+	if _, ok := r.presets[DefaultStyle]; !ok {
+		if _, err := os.Stat(dir); err == nil {
+			logger.Warn("Default style preset not found, using builtin", zap.String("dir", dir))
+		}
+		preset := builtinAnime
+		r.presets[DefaultStyle] = &preset
+	}
+
+	logger.Info("Style presets loaded",
+		zap.String("dir", dir),
+		zap.Strings("styles", r.Names()))
+
+	return r, nil
+}
+
+// loadPreset 加载单个风格预设文件
+func loadPreset(file string) (*Preset, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("yaml")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read style preset %s: %w", file, err)
+	}
+
+	var preset Preset
+	if err := v.Unmarshal(&preset); err != nil {
+		return nil, fmt.Errorf("failed to parse style preset %s: %w", file, err)
+	}
+
+	// 未指定名称时使用文件名
+	if preset.Name == "" {
+		preset.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+
+	// 未指定的SD参数沿用内置默认值
+	if preset.Sampler == "" {
+		preset.Sampler = builtinAnime.Sampler
+	}
+	if preset.Steps <= 0 {
+		preset.Steps = builtinAnime.Steps
+	}
+	if preset.CFGScale <= 0 {
+		preset.CFGScale = builtinAnime.CFGScale
+	}
+
+	return &preset, nil
+}
+
+// Has 是否存在指定风格
+func (r *Registry) Has(name string) bool {
+	_, ok := r.presets[name]
+	return ok
+}
+
+// Get 获取风格预设,不存在时返回默认风格
+func (r *Registry) Get(name string) *Preset {
+	if preset, ok := r.presets[name]; ok {
+		return preset
+	}
+	return r.presets[DefaultStyle]
+}
+
+// Names 所有风格名称(按名称排序)
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.presets))
+	for name := range r.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List 所有风格预设(按名称排序)
+func (r *Registry) List() []*Preset {
+	presets := make([]*Preset, 0, len(r.presets))
+	for _, name := range r.Names() {
+		presets = append(presets, r.presets[name])
+	}
+	return presets
+}
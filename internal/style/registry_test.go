@@ -0,0 +1,95 @@
+package style
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Jancd/1504/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// writePreset 在目录中写入风格预设文件
+func writePreset(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	dir := t.TempDir()
+	writePreset(t, dir, "noir.yaml", `
+name: "noir"
+prompt_prefix: "film noir"
+negative_prompt: "colorful"
+sampler: "Euler a"
+steps: 20
+cfg_scale: 6
+`)
+	writePreset(t, dir, "sketch.yaml", `prompt_prefix: "pencil sketch"`)
+	writePreset(t, dir, "notes.txt", `name: "ignored"`)
+
+	r, err := NewRegistry(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := r.Names(), []string{"anime", "noir", "sketch"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		style   string
+		want    string
+		sampler string
+		steps   int
+		cfg     float64
+	}{
+		{name: "explicit parameters", style: "noir", want: "noir", sampler: "Euler a", steps: 20, cfg: 6},
+		{name: "name from file and default parameters", style: "sketch", want: "sketch", sampler: "DPM++ 2M Karras", steps: 30, cfg: 7.5},
+		{name: "builtin default", style: "anime", want: "anime", sampler: "DPM++ 2M Karras", steps: 30, cfg: 7.5},
+		{name: "unknown falls back to default", style: "missing", want: "anime", sampler: "DPM++ 2M Karras", steps: 30, cfg: 7.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preset := r.Get(tt.style)
+			if preset.Name != tt.want || preset.Sampler != tt.sampler || preset.Steps != tt.steps || preset.CFGScale != tt.cfg {
+				t.Errorf("Get(%q) = %+v", tt.style, preset)
+			}
+			if r.Has(tt.style) != (tt.style == tt.want) {
+				t.Errorf("Has(%q) = %v", tt.style, r.Has(tt.style))
+			}
+		})
+	}
+}
+
+func TestNewRegistryInvalidPreset(t *testing.T) {
+	dir := t.TempDir()
+	writePreset(t, dir, "broken.yaml", "name: [unclosed")
+
+	if _, err := NewRegistry(dir); err == nil {
+		t.Error("NewRegistry() succeeded with a broken preset")
+	}
+}
+
+func TestBundledPresets(t *testing.T) {
+	r, err := NewRegistry(filepath.Join("..", "..", "configs", "styles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, preset := range r.List() {
+		if preset.PromptPrefix == "" || preset.NegativePrompt == "" || preset.VideoStyle == "" {
+			t.Errorf("preset %s is incomplete: %+v", preset.Name, preset)
+		}
+	}
+}
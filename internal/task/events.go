@@ -0,0 +1,94 @@
+package task
+
+import (
+	"sync"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+// eventBufferSize 每个订阅者的事件缓冲区大小,缓冲区满时丢弃新事件(任务结束事件除外)
+const eventBufferSize = 64
+
+// Broker 任务事件分发器
+type Broker struct {
+	subscribers map[string]map[chan *model.TaskEvent]struct{}
+	mu          sync.RWMutex
+}
+
+// NewBroker 创建任务事件分发器
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan *model.TaskEvent]struct{}),
+	}
+}
+
+// Subscribe 订阅任务事件,返回事件channel和取消订阅函数
+func (b *Broker) Subscribe(taskID string) (<-chan *model.TaskEvent, func()) {
+	ch := make(chan *model.TaskEvent, eventBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[taskID] == nil {
+		b.subscribers[taskID] = make(map[chan *model.TaskEvent]struct{})
+	}
+	b.subscribers[taskID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[taskID], ch)
+			if len(b.subscribers[taskID]) == 0 {
+				delete(b.subscribers, taskID)
+			}
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish 发布任务事件
+// 发送不阻塞,订阅者处理过慢时事件会被丢弃;
+// 任务结束事件不会被丢弃,缓冲区满时挤掉最早的事件,保证订阅者能收到结束状态并关闭连接
+func (b *Broker) Publish(event *model.TaskEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	terminal := isTerminal(event)
+	for ch := range b.subscribers[event.TaskID] {
+		if terminal {
+			sendEvicting(ch, event)
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// sendEvicting 发送事件,缓冲区满时丢弃最早的事件直到发送成功
+func sendEvicting(ch chan *model.TaskEvent, event *model.TaskEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// isTerminal 是否为任务结束事件
+func isTerminal(event *model.TaskEvent) bool {
+	if event.Type != model.TaskEventTask {
+		return false
+	}
+	snapshot, ok := event.Data.(*model.Task)
+	return ok && snapshot.IsFinished()
+}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker()
+
+	first, unsubscribeFirst := broker.Subscribe("a")
+	second, unsubscribeSecond := broker.Subscribe("a")
+	other, unsubscribeOther := broker.Subscribe("b")
+	defer unsubscribeSecond()
+	defer unsubscribeOther()
+
+	broker.Publish(&model.TaskEvent{TaskID: "a", Type: model.TaskEventStep})
+
+	tests := []struct {
+		name   string
+		events <-chan *model.TaskEvent
+		want   bool
+	}{
+		{name: "first subscriber", events: first, want: true},
+		{name: "second subscriber", events: second, want: true},
+		{name: "other task", events: other, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			select {
+			case event := <-tt.events:
+				if !tt.want {
+					t.Fatalf("unexpected event %+v", event)
+				}
+				if event.TaskID != "a" {
+					t.Errorf("task ID = %s, want a", event.TaskID)
+				}
+			default:
+				if tt.want {
+					t.Fatal("event not delivered")
+				}
+			}
+		})
+	}
+
+	// 取消订阅后channel关闭,重复取消不会panic
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+	broker.Publish(&model.TaskEvent{TaskID: "a"})
+}
+
+func TestBrokerSlowSubscriberDoesNotBlock(t *testing.T) {
+	broker := NewBroker()
+	_, unsubscribe := broker.Subscribe("a")
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < eventBufferSize*2; i++ {
+			broker.Publish(&model.TaskEvent{TaskID: "a", Type: model.TaskEventShot})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+}
+
+func TestBrokerKeepsTerminalEvents(t *testing.T) {
+	broker := NewBroker()
+	events, unsubscribe := broker.Subscribe("a")
+	defer unsubscribe()
+
+	// 订阅者缓冲区已满时,任务结束事件挤掉最早的事件
+	for i := 0; i < eventBufferSize*2; i++ {
+		broker.Publish(&model.TaskEvent{TaskID: "a", Type: model.TaskEventShot})
+	}
+	finished := &model.Task{ID: "a", Status: model.TaskStatusCompleted}
+	broker.Publish(&model.TaskEvent{TaskID: "a", Type: model.TaskEventTask, Data: finished})
+
+	var last *model.TaskEvent
+	for range eventBufferSize {
+		select {
+		case last = <-events:
+		case <-time.After(time.Second):
+			t.Fatal("buffered events missing")
+		}
+	}
+	if last.Type != model.TaskEventTask || last.Data != finished {
+		t.Errorf("last event = %+v, want the terminal task event", last)
+	}
+}
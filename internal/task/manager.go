@@ -1,72 +1,165 @@
 package task
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTaskNotFound 任务不存在
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrStatusConflict 任务当前状态不允许该操作
+	ErrStatusConflict = errors.New("task status conflict")
+	// ErrTaskExists 任务ID已存在
+	ErrTaskExists = errors.New("task already exists")
 )
 
 // Manager 任务管理器
 type Manager struct {
-	tasks map[string]*model.Task
-	mu    sync.RWMutex
+	store  Store
+	events *Broker
+	mu     sync.RWMutex
 }
 
 // NewManager 创建任务管理器
-func NewManager() *Manager {
+func NewManager(store Store) *Manager {
 	return &Manager{
-		tasks: make(map[string]*model.Task),
+		store:  store,
+		events: NewBroker(),
 	}
 }
 
+// Events 任务事件分发器
+func (m *Manager) Events() *Broker {
+	return m.events
+}
+
+// Publish 发布任务事件
+func (m *Manager) Publish(taskID, eventType, step string, data interface{}) {
+	m.events.Publish(&model.TaskEvent{
+		Type:      eventType,
+		TaskID:    taskID,
+		Step:      step,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// put 保存任务并推送最新状态
+// 调用方需持有 m.mu
+func (m *Manager) put(task *model.Task) error {
+	err := m.store.Put(task)
+	m.Publish(task.ID, model.TaskEventTask, "", task.Snapshot())
+	return err
+}
+
 // Create 创建任务
 func (m *Manager) Create(task *model.Task) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.tasks[task.ID] = task
+	if err := m.put(task); err != nil {
+		logger.Error("Failed to persist task", zap.String("task_id", task.ID), zap.Error(err))
+	}
+}
+
+// CreateUnique 创建任务,任务ID已存在时返回 ErrTaskExists
+// 检查和创建在同一把锁内完成,用于以外部指定的ID创建任务
+func (m *Manager) CreateUnique(task *model.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.store.Get(task.ID); ok {
+		return fmt.Errorf("%w: %s", ErrTaskExists, task.ID)
+	}
+	if err := m.put(task); err != nil {
+		logger.Error("Failed to persist task", zap.String("task_id", task.ID), zap.Error(err))
+	}
+	return nil
 }
 
 // Get 获取任务
 func (m *Manager) Get(taskID string) (*model.Task, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	task, ok := m.tasks[taskID]
-	return task, ok
+	return m.store.Get(taskID)
 }
 
 // Update 更新任务
 func (m *Manager) Update(task *model.Task) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.tasks[task.ID]; !ok {
+	if _, ok := m.store.Get(task.ID); !ok {
 		return fmt.Errorf("task not found: %s", task.ID)
 	}
-	m.tasks[task.ID] = task
-	return nil
+	return m.put(task)
+}
+
+// Transition 在锁内检查任务状态并修改,检查和修改之间不会插入其他状态转换
+// 返回在锁内复制的任务快照;任务状态不在 from 中时返回 ErrStatusConflict,update 返回错误时放弃修改并返回该错误
+func (m *Manager) Transition(taskID string, from []string, update func(task *model.Task) error) (*model.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.store.Get(taskID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if !slices.Contains(from, task.Status) {
+		return task.Snapshot(), fmt.Errorf("%w: task status is %s", ErrStatusConflict, task.Status)
+	}
+	if err := update(task); err != nil {
+		return task.Snapshot(), err
+	}
+
+	task.UpdatedAt = time.Now()
+	if err := m.put(task); err != nil {
+		logger.Error("Failed to persist task", zap.String("task_id", task.ID), zap.Error(err))
+	}
+	return task.Snapshot(), nil
+}
+
+// AddCallback 追加一条回调投递记录
+func (m *Manager) AddCallback(taskID string, attempt model.CallbackAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.store.Get(taskID)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+
+	task.Callbacks = append(task.Callbacks, attempt)
+	return m.put(task)
+}
+
+// Close 关闭任务存储,写入尚未落盘的任务状态
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.store.Close()
 }
 
 // Delete 删除任务
 func (m *Manager) Delete(taskID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if _, ok := m.tasks[taskID]; !ok {
+	if _, ok := m.store.Get(taskID); !ok {
 		return fmt.Errorf("task not found: %s", taskID)
 	}
-	delete(m.tasks, taskID)
-	return nil
+	return m.store.Delete(taskID)
 }
 
 // List 列出所有任务
 func (m *Manager) List() []*model.Task {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-
-	tasks := make([]*model.Task, 0, len(m.tasks))
-	for _, task := range m.tasks {
-		tasks = append(tasks, task)
-	}
-	return tasks
+	return m.store.List()
 }
 
 // UpdateTaskStatus 更新任务状态
@@ -74,13 +167,13 @@ func (m *Manager) UpdateTaskStatus(taskID, status string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	task, ok := m.tasks[taskID]
+	task, ok := m.store.Get(taskID)
 	if !ok {
 		return fmt.Errorf("task not found: %s", taskID)
 	}
 
 	task.Status = status
-	return nil
+	return m.put(task)
 }
 
 // UpdateTaskProgress 更新任务进度
@@ -88,13 +181,13 @@ func (m *Manager) UpdateTaskProgress(taskID string, progress int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	task, ok := m.tasks[taskID]
+	task, ok := m.store.Get(taskID)
 	if !ok {
 		return fmt.Errorf("task not found: %s", taskID)
 	}
 
 	task.Progress = progress
-	return nil
+	return m.put(task)
 }
 
 // SetTaskError 设置任务错误
@@ -102,14 +195,14 @@ func (m *Manager) SetTaskError(taskID, errMsg string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	task, ok := m.tasks[taskID]
+	task, ok := m.store.Get(taskID)
 	if !ok {
 		return fmt.Errorf("task not found: %s", taskID)
 	}
 
 	task.Status = model.TaskStatusFailed
 	task.Error = errMsg
-	return nil
+	return m.put(task)
 }
 
 // SetTaskResult 设置任务结果
@@ -117,7 +210,7 @@ func (m *Manager) SetTaskResult(taskID string, result *model.Result) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	task, ok := m.tasks[taskID]
+	task, ok := m.store.Get(taskID)
 	if !ok {
 		return fmt.Errorf("task not found: %s", taskID)
 	}
@@ -125,5 +218,77 @@ func (m *Manager) SetTaskResult(taskID string, result *model.Result) error {
 	task.Result = result
 	task.Status = model.TaskStatusCompleted
 	task.Progress = 100
-	return nil
+	return m.put(task)
+}
+
+// RecoverInterrupted 将重启前未完成的任务标记为中断
+// pending 返回true的排队任务尚未提交到任务队列(如等待上一集的剧集分集),保持排队状态;pending 可以为nil。
+// 返回被标记的任务数量
+func (m *Manager) RecoverInterrupted(pending func(task *model.Task) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, task := range m.store.List() {
+		if task.Status != model.TaskStatusQueued && task.Status != model.TaskStatusProcessing {
+			continue
+		}
+		if task.Status == model.TaskStatusQueued && pending != nil && pending(task) {
+			continue
+		}
+
+		// 正在执行的步骤标记为失败
+		for _, step := range task.Steps {
+			if step.Status == model.StepStatusProcessing {
+				task.UpdateStep(step.Name, model.StepStatusFailed)
+			}
+		}
+
+		task.Status = model.TaskStatusInterrupted
+		task.Error = "task interrupted by server restart"
+		task.UpdatedAt = time.Now()
+
+		if err := m.put(task); err != nil {
+			logger.Error("Failed to persist interrupted task",
+				zap.String("task_id", task.ID),
+				zap.Error(err))
+			continue
+		}
+
+		logger.Warn("Task marked as interrupted", zap.String("task_id", task.ID))
+		count++
+	}
+
+	return count
+}
+
+// AverageDuration 已完成任务的平均处理耗时(秒)
+// 没有历史数据时返回 fallback
+func (m *Manager) AverageDuration(fallback float64) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := 0.0
+	count := 0
+	for _, task := range m.store.List() {
+		if task.Status != model.TaskStatusCompleted {
+			continue
+		}
+
+		elapsed := 0.0
+		for _, step := range task.Steps {
+			elapsed += step.Duration
+		}
+		if elapsed <= 0 {
+			continue
+		}
+
+		total += elapsed
+		count++
+	}
+
+	if count == 0 {
+		return fallback
+	}
+	return total / float64(count)
 }
@@ -0,0 +1,168 @@
+package task
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestManagerTransition(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	tests := []struct {
+		name    string
+		id      string
+		from    []string
+		update  func(task *model.Task) error
+		wantErr error
+		status  string
+	}{
+		{
+			name:    "unknown task",
+			id:      "missing",
+			from:    []string{model.TaskStatusFailed},
+			wantErr: ErrTaskNotFound,
+		},
+		{
+			name:    "status not allowed",
+			id:      "t1",
+			from:    []string{model.TaskStatusCompleted},
+			wantErr: ErrStatusConflict,
+			status:  model.TaskStatusFailed,
+		},
+		{
+			name:    "update error",
+			id:      "t1",
+			from:    []string{model.TaskStatusFailed},
+			update:  func(task *model.Task) error { return errRejected },
+			wantErr: errRejected,
+			status:  model.TaskStatusFailed,
+		},
+		{
+			name: "transition applied",
+			id:   "t1",
+			from: []string{model.TaskStatusFailed, model.TaskStatusInterrupted},
+			update: func(task *model.Task) error {
+				task.Status = model.TaskStatusQueued
+				return nil
+			},
+			status: model.TaskStatusQueued,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(NewMemoryStore())
+			task := model.NewTask("t1", model.Input{Text: "x"})
+			task.Status = model.TaskStatusFailed
+			manager.Create(task)
+
+			got, err := manager.Transition(tt.id, tt.from, tt.update)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Transition() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.status == "" {
+				return
+			}
+			if got.Status != tt.status {
+				t.Errorf("returned status = %s, want %s", got.Status, tt.status)
+			}
+			if stored, _ := manager.Get(tt.id); stored.Status != tt.status {
+				t.Errorf("stored status = %s, want %s", stored.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestManagerTransitionConcurrent(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+	task := model.NewTask("t1", model.Input{Text: "x"})
+	task.Status = model.TaskStatusFailed
+	manager.Create(task)
+
+	// 同时发起的多个重试中只有一个能成功
+	const attempts = 16
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := manager.Transition("t1", []string{model.TaskStatusFailed}, func(task *model.Task) error {
+				task.Status = model.TaskStatusQueued
+				return nil
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrStatusConflict) {
+				t.Errorf("Transition() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d transitions succeeded, want 1", succeeded)
+	}
+}
+
+func TestManagerAddCallback(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+	manager.Create(model.NewTask("t1", model.Input{Text: "x"}))
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := manager.AddCallback("t1", model.CallbackAttempt{Attempt: i + 1}); err != nil {
+				t.Errorf("AddCallback() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	task, _ := manager.Get("t1")
+	if len(task.Callbacks) != 8 {
+		t.Errorf("recorded %d callbacks, want 8", len(task.Callbacks))
+	}
+	if err := manager.AddCallback("missing", model.CallbackAttempt{}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("AddCallback(missing) error = %v, want ErrTaskNotFound", err)
+	}
+}
+
+func TestManagerCreateUnique(t *testing.T) {
+	manager := NewManager(NewMemoryStore())
+
+	// 同时以同一ID创建只有一次成功
+	const attempts = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- manager.CreateUnique(model.NewTask("plan", model.Input{Text: "x"}))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrTaskExists):
+			t.Errorf("CreateUnique() error = %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d creations succeeded, want 1", created)
+	}
+}
@@ -0,0 +1,178 @@
+package task
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Handler 任务处理函数,ctx 在任务被取消时关闭
+type Handler func(ctx context.Context, taskID string)
+
+// Queue 有界任务队列
+// 任务按提交顺序(FIFO)排队,最多同时执行 workers 个
+type Queue struct {
+	workers int
+	handler Handler
+	pending []string
+	running map[string]*runningTask
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+// runningTask 正在执行的任务
+type runningTask struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewQueue 创建任务队列并启动工作协程
+func NewQueue(workers int, handler Handler) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	q := &Queue{
+		workers: workers,
+		handler: handler,
+		running: make(map[string]*runningTask),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	for i := 0; i < workers; i++ {
+		go q.worker(i)
+	}
+
+	logger.Info("Task queue started", zap.Int("workers", workers))
+	return q
+}
+
+// Submit 提交任务,返回排队位置(从1开始)
+// 有空闲工作协程、任务会立即开始执行时返回0,与之后 Position 的结果一致
+func (q *Queue) Submit(taskID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending = append(q.pending, taskID)
+	q.cond.Signal()
+
+	idle := q.workers - len(q.running)
+	return max(len(q.pending)-idle, 0)
+}
+
+// Cancel 取消任务
+// 排队中的任务直接移出队列;执行中的任务取消其上下文。
+// 任务不在队列中也未在执行时返回false
+func (q *Queue) Cancel(taskID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, id := range q.pending {
+		if id == taskID {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true
+		}
+	}
+
+	if rt, ok := q.running[taskID]; ok {
+		rt.cancel()
+		return true
+	}
+	return false
+}
+
+// Done 返回任务执行结束时关闭的channel,任务未在执行时返回nil
+func (q *Queue) Done(taskID string) <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if rt, ok := q.running[taskID]; ok {
+		return rt.done
+	}
+	return nil
+}
+
+// Position 获取任务排队位置(从1开始),不在队列中返回0
+func (q *Queue) Position(taskID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, id := range q.pending {
+		if id == taskID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Ahead 排在任务前面的任务数: 执行中的其他任务加上队列中排在其前面的任务
+// 任务正在执行或不在队列中时返回0
+func (q *Queue) Ahead(taskID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.running[taskID]; ok {
+		return 0
+	}
+	for i, id := range q.pending {
+		if id == taskID {
+			return len(q.running) + i
+		}
+	}
+	return 0
+}
+
+// Workers 最大并发数
+func (q *Queue) Workers() int {
+	return q.workers
+}
+
+// Running 正在执行的任务数
+func (q *Queue) Running() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.running)
+}
+
+// worker 工作协程,依次取出队首任务执行
+func (q *Queue) worker(id int) {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.cond.Wait()
+		}
+		taskID := q.pending[0]
+		q.pending = q.pending[1:]
+		ctx, cancel := context.WithCancel(context.Background())
+		rt := &runningTask{cancel: cancel, done: make(chan struct{})}
+		q.running[taskID] = rt
+		q.mu.Unlock()
+
+		logger.Debug("Worker picked task",
+			zap.Int("worker", id),
+			zap.String("task_id", taskID))
+
+		q.run(ctx, taskID)
+
+		q.mu.Lock()
+		delete(q.running, taskID)
+		q.mu.Unlock()
+
+		cancel()
+		close(rt.done)
+	}
+}
+
+// run 执行任务,防止单个任务panic导致工作协程退出
+func (q *Queue) run(ctx context.Context, taskID string) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("Task handler panicked",
+				zap.String("task_id", taskID),
+				zap.Any("panic", r))
+		}
+	}()
+	q.handler(ctx, taskID)
+}
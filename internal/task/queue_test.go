@@ -0,0 +1,254 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+// testQueue 执行时先上报任务ID,再阻塞到 release 收到信号或任务被取消的队列
+func testQueue(workers int) (*Queue, chan string, chan struct{}) {
+	started := make(chan string, 16)
+	release := make(chan struct{})
+	q := NewQueue(workers, func(ctx context.Context, taskID string) {
+		started <- taskID
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		if taskID == "panic" {
+			panic("handler failed")
+		}
+	})
+	return q, started, release
+}
+
+// receive 等待下一个开始执行的任务
+func receive(t *testing.T, started <-chan string) string {
+	t.Helper()
+	select {
+	case id := <-started:
+		return id
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for task to start")
+		return ""
+	}
+}
+
+// idle 确认在短时间内没有任务开始执行
+func idle(t *testing.T, started <-chan string) {
+	t.Helper()
+	select {
+	case id := <-started:
+		t.Fatalf("task %s started unexpectedly", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestQueueFIFO(t *testing.T) {
+	q, started, release := testQueue(1)
+
+	for _, id := range []string{"a", "b", "c"} {
+		q.Submit(id)
+	}
+
+	if got := receive(t, started); got != "a" {
+		t.Fatalf("first task = %s, want a", got)
+	}
+	if got := q.Position("b"); got != 1 {
+		t.Errorf("Position(b) = %d, want 1", got)
+	}
+	if got := q.Position("c"); got != 2 {
+		t.Errorf("Position(c) = %d, want 2", got)
+	}
+	if got := q.Position("a"); got != 0 {
+		t.Errorf("Position(a) = %d, want 0 while running", got)
+	}
+
+	for _, want := range []string{"b", "c"} {
+		release <- struct{}{}
+		if got := receive(t, started); got != want {
+			t.Fatalf("next task = %s, want %s", got, want)
+		}
+	}
+	release <- struct{}{}
+}
+
+func TestQueueWorkerLimit(t *testing.T) {
+	q, started, release := testQueue(2)
+
+	for _, id := range []string{"a", "b", "c"} {
+		q.Submit(id)
+	}
+	receive(t, started)
+	receive(t, started)
+	idle(t, started)
+
+	if got := q.Running(); got != 2 {
+		t.Errorf("Running() = %d, want 2", got)
+	}
+
+	release <- struct{}{}
+	if got := receive(t, started); got != "c" {
+		t.Errorf("third task = %s, want c", got)
+	}
+	release <- struct{}{}
+	release <- struct{}{}
+}
+
+func TestQueueSubmitPosition(t *testing.T) {
+	q, started, release := testQueue(2)
+
+	// 有空闲工作协程时返回0,之后按排队顺序从1开始
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{id: "a", want: 0},
+		{id: "b", want: 0},
+		{id: "c", want: 1},
+		{id: "d", want: 2},
+	}
+	for _, tt := range tests {
+		if got := q.Submit(tt.id); got != tt.want {
+			t.Errorf("Submit(%s) = %d, want %d", tt.id, got, tt.want)
+		}
+	}
+
+	receive(t, started)
+	receive(t, started)
+	if got := q.Position("d"); got != 2 {
+		t.Errorf("Position(d) = %d, want 2", got)
+	}
+
+	for range 4 {
+		release <- struct{}{}
+	}
+}
+
+func TestQueueAhead(t *testing.T) {
+	q, started, release := testQueue(2)
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		q.Submit(id)
+	}
+	receive(t, started)
+	receive(t, started)
+
+	// 前面的任务包括执行中的任务和排在前面的任务,不含自身
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{id: "a", want: 0},
+		{id: "c", want: 2},
+		{id: "d", want: 3},
+		{id: "missing", want: 0},
+	}
+	for _, tt := range tests {
+		if got := q.Ahead(tt.id); got != tt.want {
+			t.Errorf("Ahead(%s) = %d, want %d", tt.id, got, tt.want)
+		}
+	}
+
+	for range 4 {
+		release <- struct{}{}
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q, started, release := testQueue(1)
+
+	q.Submit("a")
+	receive(t, started)
+	q.Submit("b")
+	q.Submit("c")
+
+	done := q.Done("a")
+	if done == nil {
+		t.Fatal("Done(a) = nil for a running task")
+	}
+	if q.Done("b") != nil {
+		t.Error("Done(b) != nil for a queued task")
+	}
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "b", want: true},
+		{id: "b", want: false},
+		{id: "missing", want: false},
+		{id: "a", want: true},
+	}
+	for _, tt := range tests {
+		if got := q.Cancel(tt.id); got != tt.want {
+			t.Errorf("Cancel(%s) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	// 取消执行中的任务会关闭其上下文,任务退出后 Done 关闭
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cancelled task did not stop")
+	}
+	if got := receive(t, started); got != "c" {
+		t.Errorf("next task = %s, want c", got)
+	}
+	release <- struct{}{}
+}
+
+func TestQueueRecoversFromPanic(t *testing.T) {
+	q, started, release := testQueue(1)
+
+	q.Submit("panic")
+	q.Submit("next")
+
+	receive(t, started)
+	release <- struct{}{}
+	if got := receive(t, started); got != "next" {
+		t.Errorf("task after panic = %s, want next", got)
+	}
+	release <- struct{}{}
+}
+
+func TestAverageDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []*model.Task
+		want  float64
+	}{
+		{name: "no history uses fallback", want: 300},
+		{
+			name: "completed tasks only",
+			tasks: []*model.Task{
+				{ID: "a", Status: model.TaskStatusCompleted, Steps: []model.Step{{Duration: 10}, {Duration: 20}}},
+				{ID: "b", Status: model.TaskStatusCompleted, Steps: []model.Step{{Duration: 90}}},
+				{ID: "c", Status: model.TaskStatusFailed, Steps: []model.Step{{Duration: 1000}}},
+			},
+			want: 60,
+		},
+		{
+			name: "completed without timings are ignored",
+			tasks: []*model.Task{
+				{ID: "a", Status: model.TaskStatusCompleted},
+			},
+			want: 300,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(NewMemoryStore())
+			for _, task := range tt.tasks {
+				manager.Create(task)
+			}
+			if got := manager.AverageDuration(300); got != tt.want {
+				t.Errorf("AverageDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
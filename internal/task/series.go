@@ -0,0 +1,258 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// SeriesManager 剧集管理器
+// dir 非空时每个剧集保存为 <dir>/<series_id>.json,重启后可恢复
+type SeriesManager struct {
+	series map[string]*model.Series
+	dir    string
+	mu     sync.RWMutex
+}
+
+// NewSeriesManager 创建剧集管理器并加载已有剧集,dir 为空时只保存在内存中
+func NewSeriesManager(dir string) (*SeriesManager, error) {
+	m := &SeriesManager{
+		series: make(map[string]*model.Series),
+		dir:    dir,
+	}
+	if dir == "" {
+		return m, nil
+	}
+
+	if err := utils.EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create series store directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read series store directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		var series model.Series
+		if err := utils.LoadJSON(filepath.Join(dir, entry.Name()), &series); err != nil {
+			logger.Warn("Failed to load series record, skipping",
+				zap.String("file", entry.Name()),
+				zap.Error(err))
+			continue
+		}
+		m.series[series.ID] = &series
+	}
+
+	logger.Info("Series store loaded",
+		zap.String("dir", dir),
+		zap.Int("series", len(m.series)))
+
+	return m, nil
+}
+
+// Create 创建剧集
+func (m *SeriesManager) Create(series *model.Series) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.put(series)
+}
+
+// Get 获取剧集的副本
+func (m *SeriesManager) Get(seriesID string) (*model.Series, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	series, ok := m.series[seriesID]
+	if !ok {
+		return nil, false
+	}
+	return series.Snapshot(), true
+}
+
+// Refresh 按各集任务的最新状态汇总剧集状态,有变化时保存,返回汇总后的副本
+// lookup 按任务ID查找分集任务
+func (m *SeriesManager) Refresh(seriesID string, lookup func(taskID string) (*model.Task, bool)) (*model.Series, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, ok := m.series[seriesID]
+	if !ok {
+		return nil, fmt.Errorf("series not found: %s", seriesID)
+	}
+
+	tasks := make(map[string]*model.Task, len(series.Episodes))
+	for _, episode := range series.Episodes {
+		if t, ok := lookup(episode.TaskID); ok {
+			tasks[t.ID] = t
+		}
+	}
+
+	var err error
+	if series.Aggregate(tasks) {
+		err = m.put(series)
+	}
+	return series.Snapshot(), err
+}
+
+// NextEpisode 将第 number 集的下一集标记为已提交并返回其任务ID
+// 下一集不存在或已提交过时返回false,保证每一集只被提交一次
+func (m *SeriesManager) NextEpisode(seriesID string, number int) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, ok := m.series[seriesID]
+	if !ok {
+		return "", false
+	}
+
+	for i := range series.Episodes {
+		episode := &series.Episodes[i]
+		if episode.Number != number+1 {
+			continue
+		}
+		if episode.Submitted {
+			return "", false
+		}
+
+		episode.Submitted = true
+		if err := m.put(series); err != nil {
+			logger.Warn("Failed to persist series", zap.String("series_id", seriesID), zap.Error(err))
+		}
+		return episode.TaskID, true
+	}
+	return "", false
+}
+
+// SetResult 保存剧集合并后的完整视频
+func (m *SeriesManager) SetResult(seriesID string, result *model.Result) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, ok := m.series[seriesID]
+	if !ok {
+		return fmt.Errorf("series not found: %s", seriesID)
+	}
+
+	series.Result = result
+	series.UpdatedAt = time.Now()
+	return m.put(series)
+}
+
+// Delete 删除剧集及其磁盘记录
+func (m *SeriesManager) Delete(seriesID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.series[seriesID]; !ok {
+		return fmt.Errorf("series not found: %s", seriesID)
+	}
+
+	delete(m.series, seriesID)
+	if m.dir != "" {
+		if err := os.Remove(m.path(seriesID)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove series record: %w", err)
+		}
+	}
+	return nil
+}
+
+// List 列出所有剧集的副本
+func (m *SeriesManager) List() []*model.Series {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]*model.Series, 0, len(m.series))
+	for _, series := range m.series {
+		list = append(list, series.Snapshot())
+	}
+	return list
+}
+
+// Pending 任务是否为尚未提交到任务队列的剧集分集
+// 这类分集等待上一集提交,重启时保持排队状态
+func (m *SeriesManager) Pending(task *model.Task) bool {
+	if task.Input.Episode == nil {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	series, ok := m.series[task.Input.Episode.SeriesID]
+	if !ok {
+		return false
+	}
+	for _, episode := range series.Episodes {
+		if episode.TaskID == task.ID {
+			return !episode.Submitted
+		}
+	}
+	return false
+}
+
+// Characters 剧集共享的角色设定
+func (m *SeriesManager) Characters(seriesID string) []model.Character {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if series, ok := m.series[seriesID]; ok {
+		return append([]model.Character(nil), series.Characters...)
+	}
+	return nil
+}
+
+// AddCharacters 将分集新出现的角色加入共享角色设定,已有的同名角色保持不变
+func (m *SeriesManager) AddCharacters(seriesID string, characters []model.Character) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series, ok := m.series[seriesID]
+	if !ok {
+		return fmt.Errorf("series not found: %s", seriesID)
+	}
+
+	known := make(map[string]bool)
+	for _, c := range series.Characters {
+		known[c.Name] = true
+	}
+
+	added := 0
+	for _, c := range characters {
+		if !known[c.Name] {
+			known[c.Name] = true
+			series.Characters = append(series.Characters, c)
+			added++
+		}
+	}
+	if added == 0 {
+		return nil
+	}
+	return m.put(series)
+}
+
+// put 保存剧集,调用方需持有 m.mu
+func (m *SeriesManager) put(series *model.Series) error {
+	m.series[series.ID] = series
+	if m.dir == "" {
+		return nil
+	}
+
+	if err := utils.SaveJSON(m.path(series.ID), series); err != nil {
+		return fmt.Errorf("failed to persist series: %w", err)
+	}
+	return nil
+}
+
+// path 剧集记录文件路径
+func (m *SeriesManager) path(seriesID string) string {
+	return filepath.Join(m.dir, seriesID+".json")
+}
@@ -0,0 +1,200 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+)
+
+func TestSeriesManagerReload(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := NewSeriesManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"kept", "deleted"} {
+		if err := m.Create(&model.Series{ID: id, Status: model.TaskStatusQueued}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Delete("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetResult("missing", &model.Result{}); err == nil {
+		t.Error("SetResult() succeeded for unknown series")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewSeriesManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     string
+		exists bool
+	}{
+		{id: "kept", exists: true},
+		{id: "deleted", exists: false},
+		{id: "broken", exists: false},
+	}
+	for _, tt := range tests {
+		if _, ok := reloaded.Get(tt.id); ok != tt.exists {
+			t.Errorf("Get(%q) exists = %v, want %v", tt.id, ok, tt.exists)
+		}
+	}
+	if n := len(reloaded.List()); n != 1 {
+		t.Errorf("List() = %d series, want 1", n)
+	}
+}
+
+func TestSeriesManagerAddCharacters(t *testing.T) {
+	m, err := NewSeriesManager("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Create(&model.Series{ID: "s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.AddCharacters("s", []model.Character{{Name: "Alice", Hair: "red"}, {Name: "Bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	// 已有的同名角色保持先生成分集中的设定
+	if err := m.AddCharacters("s", []model.Character{{Name: "Alice", Hair: "blue"}, {Name: "Carol"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddCharacters("missing", []model.Character{{Name: "Alice"}}); err == nil {
+		t.Error("AddCharacters() succeeded for unknown series")
+	}
+
+	characters := m.Characters("s")
+	want := []string{"Alice", "Bob", "Carol"}
+	if len(characters) != len(want) {
+		t.Fatalf("characters = %+v, want %v", characters, want)
+	}
+	for i, name := range want {
+		if characters[i].Name != name {
+			t.Errorf("characters[%d] = %q, want %q", i, characters[i].Name, name)
+		}
+	}
+	if characters[0].Hair != "red" {
+		t.Errorf("Alice hair = %q, want red", characters[0].Hair)
+	}
+
+	// 返回副本,修改不影响剧集
+	characters[0].Hair = "green"
+	if got := m.Characters("s")[0].Hair; got != "red" {
+		t.Errorf("Characters() returned shared slice, hair = %q", got)
+	}
+}
+
+func TestSeriesManagerRefresh(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewSeriesManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := &model.Series{
+		ID:     "s",
+		Status: model.TaskStatusQueued,
+		Episodes: []model.SeriesEpisode{
+			{Number: 1, TaskID: "e1", Status: model.TaskStatusQueued},
+			{Number: 2, TaskID: "e2", Status: model.TaskStatusQueued},
+		},
+	}
+	if err := m.Create(series); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := map[string]*model.Task{
+		"e1": {ID: "e1", Status: model.TaskStatusCompleted, Progress: 100},
+		"e2": {ID: "e2", Status: model.TaskStatusProcessing, Progress: 50},
+	}
+	lookup := func(taskID string) (*model.Task, bool) {
+		t, ok := tasks[taskID]
+		return t, ok
+	}
+
+	if _, err := m.Refresh("missing", lookup); err == nil {
+		t.Error("Refresh() succeeded for unknown series")
+	}
+
+	got, err := m.Refresh("s", lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != model.TaskStatusProcessing || got.Progress != 75 {
+		t.Errorf("status = %s progress = %d, want processing 75", got.Status, got.Progress)
+	}
+
+	// 返回副本,修改不影响剧集
+	got.Episodes[0].Status = model.TaskStatusFailed
+	if current, _ := m.Get("s"); current.Episodes[0].Status != model.TaskStatusCompleted {
+		t.Errorf("Refresh() returned shared episodes, status = %s", current.Episodes[0].Status)
+	}
+
+	// 汇总结果已保存
+	reloaded, err := NewSeriesManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := reloaded.Get("s"); saved.Status != model.TaskStatusProcessing {
+		t.Errorf("saved status = %s, want processing", saved.Status)
+	}
+
+	if err := m.SetResult("s", &model.Result{VideoPath: "series.mp4"}); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := m.Get("s"); current.Result == nil || current.Result.VideoPath != "series.mp4" {
+		t.Errorf("result = %+v", current.Result)
+	}
+}
+
+func TestSeriesManagerPending(t *testing.T) {
+	m, err := NewSeriesManager("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Create(&model.Series{
+		ID:     "s1",
+		Status: model.TaskStatusQueued,
+		Episodes: []model.SeriesEpisode{
+			{Number: 1, TaskID: "e1", Submitted: true},
+			{Number: 2, TaskID: "e2"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		task    *model.Task
+		pending bool
+	}{
+		{name: "submitted episode", task: model.NewTask("e1", model.Input{Episode: &model.Episode{SeriesID: "s1", Number: 1}})},
+		{name: "unsubmitted episode", task: model.NewTask("e2", model.Input{Episode: &model.Episode{SeriesID: "s1", Number: 2}}), pending: true},
+		{name: "unknown series", task: model.NewTask("e3", model.Input{Episode: &model.Episode{SeriesID: "missing", Number: 2}})},
+		{name: "plain task", task: model.NewTask("t1", model.Input{})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Pending(tt.task); got != tt.pending {
+				t.Errorf("Pending() = %v, want %v", got, tt.pending)
+			}
+		})
+	}
+
+	// 提交后不再保持排队
+	if _, ok := m.NextEpisode("s1", 1); !ok {
+		t.Fatal("NextEpisode() = false, want true")
+	}
+	if m.Pending(tests[1].task) {
+		t.Error("Pending() = true after the episode was submitted")
+	}
+}
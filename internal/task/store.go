@@ -0,0 +1,292 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Store 任务存储接口
+type Store interface {
+	Get(taskID string) (*model.Task, bool)
+	Put(task *model.Task) error
+	Delete(taskID string) error
+	List() []*model.Task
+	Close() error
+}
+
+// MemoryStore 内存任务存储(重启后丢失)
+type MemoryStore struct {
+	tasks map[string]*model.Task
+	mu    sync.RWMutex
+}
+
+// NewMemoryStore 创建内存任务存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks: make(map[string]*model.Task),
+	}
+}
+
+// Get 获取任务
+func (s *MemoryStore) Get(taskID string) (*model.Task, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, ok := s.tasks[taskID]
+	return task, ok
+}
+
+// Put 保存任务
+func (s *MemoryStore) Put(task *model.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[task.ID] = task
+	return nil
+}
+
+// Delete 删除任务
+func (s *MemoryStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, taskID)
+	return nil
+}
+
+// List 列出所有任务
+func (s *MemoryStore) List() []*model.Task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := make([]*model.Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// Close 关闭存储,内存存储无需处理
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// progressWriteInterval 仅进度变化时两次写盘的最小间隔
+const progressWriteInterval = 2 * time.Second
+
+// FileStore 文件任务存储
+// 每个任务保存为 <dir>/<task_id>.json,内存中保留一份缓存用于读取
+// 回调密钥不写入任务记录,单独保存为仅属主可读的 <dir>/<task_id>.secret
+type FileStore struct {
+	*MemoryStore
+	dir     string
+	written map[string]writeRecord // 每个任务最近一次写盘的状态
+	pending map[string]*model.Task // 被节流跳过、尚未写盘的任务快照
+	flusher *time.Timer            // 节流间隔后写入 pending 的定时器
+	closed  bool                   // 关闭后不再节流,每次都立即写盘
+}
+
+// writeRecord 任务最近一次写盘的状态
+type writeRecord struct {
+	state  string // 除进度外的状态摘要
+	secret string
+	at     time.Time
+}
+
+// NewFileStore 创建文件任务存储并加载已有任务
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := utils.EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create task store directory: %w", err)
+	}
+
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		dir:         dir,
+		written:     make(map[string]writeRecord),
+		pending:     make(map[string]*model.Task),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task store directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		var task model.Task
+		if err := utils.LoadJSON(filepath.Join(dir, entry.Name()), &task); err != nil {
+			logger.Warn("Failed to load task record, skipping",
+				zap.String("file", entry.Name()),
+				zap.Error(err))
+			continue
+		}
+		if secret, err := os.ReadFile(s.secretPath(task.ID)); err == nil {
+			task.Input.CallbackSecret = string(secret)
+		}
+		s.tasks[task.ID] = &task
+	}
+
+	logger.Info("Task store loaded",
+		zap.String("dir", dir),
+		zap.Int("tasks", len(s.tasks)))
+
+	return s, nil
+}
+
+// Put 保存任务并写入磁盘
+// 只有进度变化时按 progressWriteInterval 节流写盘,状态、步骤、结果等变化立即写入;
+// 被跳过的最新进度在节流间隔后或 Close 时补写
+func (s *FileStore) Put(task *model.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tasks[task.ID] = task
+
+	record := writeRecord{
+		state:  persistState(task),
+		secret: task.Input.CallbackSecret,
+		at:     time.Now(),
+	}
+	last, ok := s.written[task.ID]
+	if !s.closed && ok && last.state == record.state && last.secret == record.secret &&
+		record.at.Sub(last.at) < progressWriteInterval {
+		s.pending[task.ID] = task.Snapshot()
+		if s.flusher == nil {
+			s.flusher = time.AfterFunc(progressWriteInterval, s.flushPending)
+		}
+		return nil
+	}
+
+	if !ok || last.secret != record.secret {
+		if err := s.saveSecret(task.ID, record.secret); err != nil {
+			return err
+		}
+	}
+
+	if err := s.write(task); err != nil {
+		return err
+	}
+	s.written[task.ID] = record
+	delete(s.pending, task.ID)
+	return nil
+}
+
+// write 将任务记录写入磁盘,回调密钥不写入记录
+// 调用方需持有 s.mu
+func (s *FileStore) write(task *model.Task) error {
+	persisted := *task
+	persisted.Input.CallbackSecret = ""
+
+	if err := utils.SaveJSON(s.path(task.ID), &persisted); err != nil {
+		return fmt.Errorf("failed to persist task: %w", err)
+	}
+	return nil
+}
+
+// flushPending 定时写入被节流跳过的任务
+func (s *FileStore) flushPending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flusher = nil
+	if err := s.flush(); err != nil {
+		logger.Error("Failed to flush task records", zap.Error(err))
+	}
+}
+
+// flush 写入所有被节流跳过的任务,返回遇到的第一个错误
+// 调用方需持有 s.mu
+func (s *FileStore) flush() error {
+	var firstErr error
+	for taskID, task := range s.pending {
+		if err := s.write(task); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		record := s.written[taskID]
+		record.at = time.Now()
+		s.written[taskID] = record
+		delete(s.pending, taskID)
+	}
+	return firstErr
+}
+
+// Close 写入被节流跳过的任务,之后的保存不再节流
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.flusher != nil {
+		s.flusher.Stop()
+		s.flusher = nil
+	}
+	return s.flush()
+}
+
+// Delete 删除任务及其磁盘记录
+func (s *FileStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tasks, taskID)
+	delete(s.written, taskID)
+	delete(s.pending, taskID)
+	for _, path := range []string{s.path(taskID), s.secretPath(taskID)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove task record: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveSecret 保存回调密钥,密钥为空时删除密钥文件
+func (s *FileStore) saveSecret(taskID, secret string) error {
+	path := s.secretPath(taskID)
+	if secret == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove callback secret: %w", err)
+		}
+		return nil
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(secret), 0600); err != nil {
+		return fmt.Errorf("failed to persist callback secret: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to persist callback secret: %w", err)
+	}
+	return nil
+}
+
+// path 任务记录文件路径
+func (s *FileStore) path(taskID string) string {
+	return filepath.Join(s.dir, taskID+".json")
+}
+
+// secretPath 回调密钥文件路径
+func (s *FileStore) secretPath(taskID string) string {
+	return filepath.Join(s.dir, taskID+".secret")
+}
+
+// persistState 任务除进度以外的状态摘要,摘要不变说明只有进度发生了变化
+func persistState(task *model.Task) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s|%s|%t|%d", task.Status, task.CurrentStep, task.Error, task.Result != nil, len(task.Callbacks))
+	for _, step := range task.Steps {
+		fmt.Fprintf(&b, "|%s:%s", step.Name, step.Status)
+	}
+	return b.String()
+}
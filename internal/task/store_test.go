@@ -0,0 +1,325 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jancd/1504/internal/model"
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestFileStoreReload(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	queued := model.NewTask("queued", model.Input{Text: "第一章"})
+	done := model.NewTask("done", model.Input{Text: "第二章"})
+	done.Status = model.TaskStatusCompleted
+	done.Result = &model.Result{VideoPath: "out.mp4"}
+	for _, task := range []*model.Task{queued, done} {
+		if err := store.Put(task); err != nil {
+			t.Fatalf("Put(%s) error = %v", task.ID, err)
+		}
+	}
+	if err := store.Delete("queued"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// 损坏的记录和其他文件不影响加载
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() reload error = %v", err)
+	}
+
+	tests := []struct {
+		id     string
+		exists bool
+		status string
+	}{
+		{id: "queued", exists: false},
+		{id: "done", exists: true, status: model.TaskStatusCompleted},
+		{id: "broken", exists: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			task, ok := reloaded.Get(tt.id)
+			if ok != tt.exists {
+				t.Fatalf("Get(%s) exists = %v, want %v", tt.id, ok, tt.exists)
+			}
+			if ok && task.Status != tt.status {
+				t.Errorf("status = %s, want %s", task.Status, tt.status)
+			}
+		})
+	}
+
+	if got := len(reloaded.List()); got != 1 {
+		t.Errorf("List() has %d tasks, want 1", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "done.json.tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestRecoverInterrupted(t *testing.T) {
+	tests := []struct {
+		id          string
+		status      string
+		pending     bool
+		interrupted bool
+	}{
+		{id: "queued", status: model.TaskStatusQueued, interrupted: true},
+		{id: "processing", status: model.TaskStatusProcessing, interrupted: true},
+		{id: "pending", status: model.TaskStatusQueued, pending: true},
+		{id: "completed", status: model.TaskStatusCompleted},
+		{id: "failed", status: model.TaskStatusFailed},
+	}
+
+	manager := NewManager(NewMemoryStore())
+	pending := make(map[string]bool)
+	for _, tt := range tests {
+		task := model.NewTask(tt.id, model.Input{Text: "x"})
+		task.Status = tt.status
+		if tt.status == model.TaskStatusProcessing {
+			task.UpdateStep(model.StepParseScript, model.StepStatusCompleted)
+			task.UpdateStep(model.StepGenerateStoryboard, model.StepStatusProcessing)
+		}
+		manager.Create(task)
+		pending[tt.id] = tt.pending
+	}
+
+	if got := manager.RecoverInterrupted(func(task *model.Task) bool { return pending[task.ID] }); got != 2 {
+		t.Errorf("RecoverInterrupted() = %d, want 2", got)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			task, _ := manager.Get(tt.id)
+			if interrupted := task.Status == model.TaskStatusInterrupted; interrupted != tt.interrupted {
+				t.Errorf("status = %s, interrupted = %v, want %v", task.Status, interrupted, tt.interrupted)
+			}
+			if !tt.interrupted && task.Status != tt.status {
+				t.Errorf("status = %s, want %s", task.Status, tt.status)
+			}
+			for _, step := range task.Steps {
+				if step.Status == model.StepStatusProcessing {
+					t.Errorf("step %s still processing", step.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreThrottlesProgress(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	task := model.NewTask("t1", model.Input{Text: "x"})
+	task.Status = model.TaskStatusProcessing
+	if err := store.Put(task); err != nil {
+		t.Fatal(err)
+	}
+
+	onDisk := func() *model.Task {
+		t.Helper()
+		var persisted model.Task
+		if err := utils.LoadJSON(filepath.Join(dir, "t1.json"), &persisted); err != nil {
+			t.Fatal(err)
+		}
+		return &persisted
+	}
+
+	steps := []struct {
+		name     string
+		update   func(task *model.Task)
+		elapsed  bool // 距上次写盘已超过节流间隔
+		progress int
+		status   string
+	}{
+		{
+			name:     "progress only is throttled",
+			update:   func(task *model.Task) { task.Progress = 10 },
+			progress: 0,
+			status:   model.TaskStatusProcessing,
+		},
+		{
+			name: "step change is written",
+			update: func(task *model.Task) {
+				task.Progress = 20
+				task.UpdateStep(model.StepParseScript, model.StepStatusCompleted)
+			},
+			progress: 20,
+			status:   model.TaskStatusProcessing,
+		},
+		{
+			name:     "progress after interval is written",
+			update:   func(task *model.Task) { task.Progress = 30 },
+			elapsed:  true,
+			progress: 30,
+			status:   model.TaskStatusProcessing,
+		},
+		{
+			name:     "status change is written",
+			update:   func(task *model.Task) { task.Progress = 100; task.Status = model.TaskStatusCompleted },
+			progress: 100,
+			status:   model.TaskStatusCompleted,
+		},
+	}
+
+	for _, tt := range steps {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.elapsed {
+				record := store.written["t1"]
+				record.at = record.at.Add(-progressWriteInterval)
+				store.written["t1"] = record
+			}
+			tt.update(task)
+			if err := store.Put(task); err != nil {
+				t.Fatal(err)
+			}
+
+			// 内存中始终是最新状态
+			if got, _ := store.Get("t1"); got.Progress != task.Progress {
+				t.Errorf("cached progress = %d, want %d", got.Progress, task.Progress)
+			}
+			persisted := onDisk()
+			if persisted.Progress != tt.progress || persisted.Status != tt.status {
+				t.Errorf("on disk progress = %d status = %s, want %d %s",
+					persisted.Progress, persisted.Status, tt.progress, tt.status)
+			}
+		})
+	}
+}
+
+func TestFileStoreCallbackSecret(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	task := model.NewTask("t1", model.Input{Text: "x", CallbackURL: "http://example.com/hook", CallbackSecret: "s3cret"})
+	if err := store.Put(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// 任务记录中不含密钥,密钥文件仅属主可读
+	record, err := os.ReadFile(filepath.Join(dir, "t1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(record), "s3cret") {
+		t.Errorf("task record contains the callback secret: %s", record)
+	}
+	info, err := os.Stat(filepath.Join(dir, "t1.secret"))
+	if err != nil {
+		t.Fatalf("secret file missing: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("secret file mode = %o, want 600", perm)
+	}
+	if got, _ := store.Get("t1"); got.Input.CallbackSecret != "s3cret" {
+		t.Errorf("cached secret = %q", got.Input.CallbackSecret)
+	}
+
+	reloaded, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.Get("t1"); got.Input.CallbackSecret != "s3cret" {
+		t.Errorf("reloaded secret = %q, want s3cret", got.Input.CallbackSecret)
+	}
+
+	if err := reloaded.Delete("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "t1.secret")); !os.IsNotExist(err) {
+		t.Errorf("secret file left behind: %v", err)
+	}
+}
+
+func TestFileStoreFlushesThrottledProgress(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	progressOnDisk := func() int {
+		t.Helper()
+		var persisted model.Task
+		if err := utils.LoadJSON(filepath.Join(dir, "t1.json"), &persisted); err != nil {
+			t.Fatal(err)
+		}
+		return persisted.Progress
+	}
+
+	task := model.NewTask("t1", model.Input{Text: "x", CallbackSecret: "s3cret"})
+	if err := store.Put(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// 定时补写被跳过的最新进度
+	task.Progress = 10
+	if err := store.Put(task); err != nil {
+		t.Fatal(err)
+	}
+	if got := progressOnDisk(); got != 0 {
+		t.Fatalf("progress on disk = %d, want 0 before flush", got)
+	}
+	store.flushPending()
+	if got := progressOnDisk(); got != 10 {
+		t.Errorf("progress on disk = %d, want 10 after timed flush", got)
+	}
+
+	// 关闭时补写,之后不再节流
+	task.Progress = 20
+	if err := store.Put(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := progressOnDisk(); got != 20 {
+		t.Errorf("progress on disk = %d, want 20 after Close", got)
+	}
+	task.Progress = 30
+	if err := store.Put(task); err != nil {
+		t.Fatal(err)
+	}
+	if got := progressOnDisk(); got != 30 {
+		t.Errorf("progress on disk = %d, want 30 after Close", got)
+	}
+
+	// 补写的记录同样不含回调密钥
+	record, err := os.ReadFile(filepath.Join(dir, "t1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(record), "callback_secret") {
+		t.Errorf("flushed record contains the callback secret: %s", record)
+	}
+}
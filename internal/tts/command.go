@@ -0,0 +1,72 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/Jancd/1504/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// CommandProvider 本地命令行语音合成引擎(如piper、espeak-ng)
+type CommandProvider struct {
+	path    string
+	args    []string // 支持占位符 {text} {voice} {emotion} {output}
+	stdin   bool     // 通过标准输入传入文本
+	format  string
+	timeout time.Duration
+}
+
+// NewCommandProvider 创建命令行语音合成引擎
+func NewCommandProvider(path string, args []string, stdin bool, format string, timeout int) *CommandProvider {
+	return &CommandProvider{
+		path:    path,
+		args:    args,
+		stdin:   stdin,
+		format:  format,
+		timeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+// Format 输出音频格式
+func (p *CommandProvider) Format() string {
+	return p.format
+}
+
+// Synthesize 合成语音
+func (p *CommandProvider) Synthesize(ctx context.Context, req Request, outputPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	replacer := strings.NewReplacer(
+		"{text}", req.Text,
+		"{voice}", req.Voice,
+		"{emotion}", req.Emotion,
+		"{output}", outputPath,
+	)
+	args := make([]string, len(p.args))
+	for i, arg := range p.args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	cmd := exec.CommandContext(ctx, p.path, args...)
+	if p.stdin {
+		cmd.Stdin = strings.NewReader(req.Text)
+	}
+
+	logger.Debug("Executing TTS command", zap.String("command", cmd.String()))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("tts command failed: %w\nOutput: %s", err, string(output))
+	}
+
+	if !utils.FileExists(outputPath) {
+		return fmt.Errorf("tts command produced no output file: %s", outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+// OpenAIProvider OpenAI兼容的 /audio/speech 语音合成
+type OpenAIProvider struct {
+	client  *openai.Client
+	model   string
+	timeout time.Duration
+}
+
+// NewOpenAIProvider 创建OpenAI兼容语音合成引擎
+func NewOpenAIProvider(apiKey, baseURL, modelName string, timeout int) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &OpenAIProvider{
+		client:  openai.NewClientWithConfig(config),
+		model:   modelName,
+		timeout: time.Duration(timeout) * time.Second,
+	}
+}
+
+// Format 输出音频格式
+func (p *OpenAIProvider) Format() string {
+	return "mp3"
+}
+
+// Synthesize 合成语音
+func (p *OpenAIProvider) Synthesize(ctx context.Context, req Request, outputPath string) error {
+	logger.Debug("Synthesizing speech with OpenAI",
+		zap.String("voice", req.Voice),
+		zap.Int("text_length", len(req.Text)))
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	speechReq := openai.CreateSpeechRequest{
+		Model:          openai.SpeechModel(p.model),
+		Input:          req.Text,
+		Voice:          openai.SpeechVoice(req.Voice),
+		ResponseFormat: openai.SpeechResponseFormatMp3,
+	}
+	// tts-1系列不支持语气指令
+	if req.Emotion != "" && p.model != string(openai.TTSModel1) && p.model != string(openai.TTSModel1HD) {
+		speechReq.Instructions = fmt.Sprintf("Speak in a %s tone.", req.Emotion)
+	}
+
+	resp, err := p.client.CreateSpeech(ctx, speechReq)
+	if err != nil {
+		return fmt.Errorf("speech api call failed: %w", err)
+	}
+	defer resp.Close()
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp); err != nil {
+		return fmt.Errorf("failed to write audio file: %w", err)
+	}
+	return nil
+}
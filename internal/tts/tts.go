@@ -0,0 +1,44 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Jancd/1504/pkg/config"
+)
+
+// Request 语音合成请求
+type Request struct {
+	Text    string
+	Voice   string
+	Emotion string // 对白情绪,引擎不支持时忽略
+}
+
+// Provider 语音合成引擎
+type Provider interface {
+	// Synthesize 合成语音并写入 outputPath
+	Synthesize(ctx context.Context, req Request, outputPath string) error
+	// Format 输出音频格式(文件扩展名,不含点)
+	Format() string
+}
+
+// New 按配置创建语音合成引擎
+// OpenAI兼容引擎未单独配置密钥和地址时沿用 openai 配置
+func New(cfg config.TTSConfig, openaiCfg config.OpenAIConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "openai":
+		apiKey := cfg.OpenAI.APIKey
+		if apiKey == "" {
+			apiKey = openaiCfg.APIKey
+		}
+		baseURL := cfg.OpenAI.BaseURL
+		if baseURL == "" {
+			baseURL = openaiCfg.BaseURL
+		}
+		return NewOpenAIProvider(apiKey, baseURL, cfg.OpenAI.Model, cfg.OpenAI.Timeout), nil
+	case "command":
+		return NewCommandProvider(cfg.Command.Path, cfg.Command.Args, cfg.Command.Stdin, cfg.Command.Format, cfg.Command.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown tts provider: %s", cfg.Provider)
+	}
+}
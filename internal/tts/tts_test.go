@@ -0,0 +1,160 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jancd/1504/pkg/config"
+	"github.com/Jancd/1504/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("error", "stdout", ""); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		format   string
+		wantErr  bool
+	}{
+		{name: "openai", provider: "openai", format: "mp3"},
+		{name: "command", provider: "command", format: "wav"},
+		{name: "unknown", provider: "espeak", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.TTSConfig{Provider: tt.provider}
+			cfg.Command.Format = "wav"
+
+			provider, err := New(cfg, config.OpenAIConfig{APIKey: "key"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && provider.Format() != tt.format {
+				t.Errorf("Format() = %q, want %q", provider.Format(), tt.format)
+			}
+		})
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	req := Request{Text: "你好", Voice: "alloy", Emotion: "calm"}
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   bool
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "placeholders",
+			args: []string{"-c", `printf '%s|%s|%s' "$1" "$2" "$3" > "$4"`, "sh", "{text}", "{voice}", "{emotion}", "{output}"},
+			want: "你好|alloy|calm",
+		},
+		{
+			name:  "text on stdin",
+			args:  []string{"-c", `cat > "$1"`, "sh", "{output}"},
+			stdin: true,
+			want:  "你好",
+		},
+		{name: "command fails", args: []string{"-c", "exit 3"}, wantErr: true},
+		{name: "no output file", args: []string{"-c", "true"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "voice.wav")
+			provider := NewCommandProvider("sh", tt.args, tt.stdin, "wav", 5)
+
+			err := provider.Synthesize(context.Background(), req, output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Synthesize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			data, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("output = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        string
+		emotion      string
+		instructions string
+	}{
+		{name: "emotion as instructions", model: "gpt-4o-mini-tts", emotion: "sad", instructions: "Speak in a sad tone."},
+		{name: "tts-1 ignores emotion", model: "tts-1", emotion: "sad"},
+		{name: "no emotion", model: "gpt-4o-mini-tts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/audio/speech" {
+					http.NotFound(w, r)
+					return
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "audio/mpeg")
+				w.Write([]byte("mp3"))
+			}))
+			defer server.Close()
+
+			provider := NewOpenAIProvider("key", server.URL, tt.model, 5)
+			output := filepath.Join(t.TempDir(), "voice.mp3")
+			req := Request{Text: "你好", Voice: "nova", Emotion: tt.emotion}
+			if err := provider.Synthesize(context.Background(), req, output); err != nil {
+				t.Fatal(err)
+			}
+
+			if got["model"] != tt.model || got["input"] != "你好" || got["voice"] != "nova" || got["response_format"] != "mp3" {
+				t.Errorf("request = %v", got)
+			}
+			instructions, _ := got["instructions"].(string)
+			if instructions != tt.instructions {
+				t.Errorf("instructions = %q, want %q", instructions, tt.instructions)
+			}
+			if data, err := os.ReadFile(output); err != nil || string(data) != "mp3" {
+				t.Errorf("output = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestOpenAIProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad voice"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("key", server.URL, "tts-1", 5)
+	if err := provider.Synthesize(context.Background(), Request{Text: "x", Voice: "nope"}, filepath.Join(t.TempDir(), "voice.mp3")); err == nil {
+		t.Error("Synthesize() succeeded on API error")
+	}
+}
@@ -12,9 +12,16 @@ type Config struct {
 	Server          ServerConfig          `mapstructure:"server"`
 	Storage         StorageConfig         `mapstructure:"storage"`
 	OpenAI          OpenAIConfig          `mapstructure:"openai"`
+	LLM             LLMConfig             `mapstructure:"llm"`
+	Parsing         ParsingConfig         `mapstructure:"parsing"`
+	Series          SeriesConfig          `mapstructure:"series"`
 	VideoGeneration VideoGenerationConfig `mapstructure:"video_generation"`
 	Video           VideoConfig           `mapstructure:"video"`
 	Limits          LimitsConfig          `mapstructure:"limits"`
+	Estimate        EstimateConfig        `mapstructure:"estimate"`
+	Webhook         WebhookConfig         `mapstructure:"webhook"`
+	Styles          StylesConfig          `mapstructure:"styles"`
+	TTS             TTSConfig             `mapstructure:"tts"`
 	Log             LogConfig             `mapstructure:"log"`
 }
 
@@ -29,16 +36,63 @@ type ServerConfig struct {
 type StorageConfig struct {
 	DataDir       string `mapstructure:"data_dir"`
 	MaxUploadSize int64  `mapstructure:"max_upload_size"`
+	TaskStore     string `mapstructure:"task_store"` // memory, file
 }
 
 // OpenAIConfig OpenAI配置
 type OpenAIConfig struct {
-	APIKey  string `mapstructure:"api_key"`
-	Model   string `mapstructure:"model"`
+	APIKey   string `mapstructure:"api_key"`
+	Model    string `mapstructure:"model"`
+	BaseURL  string `mapstructure:"base_url"`
+	Timeout  int    `mapstructure:"timeout"`
+	JSONMode bool   `mapstructure:"json_mode"` // 是否使用 response_format JSON模式,兼容服务不支持时关闭
+}
+
+// LLMConfig 各步骤使用的大语言模型配置
+type LLMConfig struct {
+	Parse      LLMStepConfig   `mapstructure:"parse"`       // 剧本解析
+	Storyboard LLMStepConfig   `mapstructure:"storyboard"`  // 分镜生成
+	Characters LLMStepConfig   `mapstructure:"characters"`  // 角色设定提取
+	MaxRepairs int             `mapstructure:"max_repairs"` // 回复JSON校验失败时最多请求模型纠正的次数
+	Anthropic  AnthropicConfig `mapstructure:"anthropic"`
+	Ollama     OllamaConfig    `mapstructure:"ollama"`
+}
+
+// LLMStepConfig 单个步骤的模型选择
+type LLMStepConfig struct {
+	Provider string `mapstructure:"provider"` // openai, anthropic, ollama
+	Model    string `mapstructure:"model"`    // 为空时使用服务配置中的模型
+}
+
+// AnthropicConfig Anthropic风格消息接口配置
+type AnthropicConfig struct {
+	APIKey    string `mapstructure:"api_key"`
+	BaseURL   string `mapstructure:"base_url"`
+	Model     string `mapstructure:"model"`
+	MaxTokens int    `mapstructure:"max_tokens"`
+	Timeout   int    `mapstructure:"timeout"`
+}
+
+// OllamaConfig 本地Ollama配置
+type OllamaConfig struct {
 	BaseURL string `mapstructure:"base_url"`
+	Model   string `mapstructure:"model"`
 	Timeout int    `mapstructure:"timeout"`
 }
 
+// ParsingConfig 剧本解析配置
+type ParsingConfig struct {
+	ChunkSize   int `mapstructure:"chunk_size"`    // 单次解析的最大字数,超过时按章节和段落分段解析;0表示不分段
+	MaxInFlight int `mapstructure:"max_in_flight"` // 同时解析的分段数
+}
+
+// SeriesConfig 剧集配置
+type SeriesConfig struct {
+	EpisodeLength int `mapstructure:"episode_length"`  // 未指定集数时每集的原文字数
+	MaxEpisodes   int `mapstructure:"max_episodes"`    // 单个剧集的最大集数
+	MaxTextLength int `mapstructure:"max_text_length"` // 剧集输入文字的最大长度(字节),每集仍受 limits.max_text_length 限制
+}
+
 // VideoGenerationConfig 视频生成配置
 type VideoGenerationConfig struct {
 	Type    string        `mapstructure:"type"`
@@ -53,21 +107,48 @@ type QiniuConfig struct {
 	Model       string `mapstructure:"model"`
 	Timeout     int    `mapstructure:"timeout"`
 	MaxWaitTime int    `mapstructure:"max_wait_time"`
+	Mode        string `mapstructure:"mode"`          // single: 整个分镜生成一个视频; per_shot: 每个镜头生成一个片段后本地拼接
+	MaxInFlight int    `mapstructure:"max_in_flight"` // per_shot模式同时进行的生成任务数
+	ClipLength  int    `mapstructure:"clip_length"`   // 单次生成的视频长度(秒)
 }
 
 // LocalSDConfig 本地SD配置
 type LocalSDConfig struct {
-	APIURL  string `mapstructure:"api_url"`
-	Timeout int    `mapstructure:"timeout"`
+	Backend   string            `mapstructure:"backend"` // a1111, comfyui, openai, placeholder
+	APIURL    string            `mapstructure:"api_url"` // AUTOMATIC1111 WebUI 地址
+	Timeout   int               `mapstructure:"timeout"`
+	ImageSize int               `mapstructure:"image_size"` // 生成图像的长边像素,0表示与输出分辨率一致
+	ComfyUI   ComfyUIConfig     `mapstructure:"comfyui"`
+	OpenAI    ImageOpenAIConfig `mapstructure:"openai"`
+}
+
+// ComfyUIConfig ComfyUI图像生成配置
+type ComfyUIConfig struct {
+	APIURL   string `mapstructure:"api_url"`
+	Workflow string `mapstructure:"workflow"` // API格式的工作流JSON文件
+	Timeout  int    `mapstructure:"timeout"`
+}
+
+// ImageOpenAIConfig OpenAI兼容图像生成配置
+type ImageOpenAIConfig struct {
+	APIKey  string   `mapstructure:"api_key"`  // 为空时沿用 openai.api_key
+	BaseURL string   `mapstructure:"base_url"` // 为空时沿用 openai.base_url
+	Model   string   `mapstructure:"model"`
+	Sizes   []string `mapstructure:"sizes"` // 接口支持的尺寸,为空时按输出比例直接请求
+	Timeout int      `mapstructure:"timeout"`
 }
 
 // VideoConfig 视频配置
 type VideoConfig struct {
-	DefaultBGM  string `mapstructure:"default_bgm"`
-	Resolution  string `mapstructure:"resolution"`
-	FPS         int    `mapstructure:"fps"`
-	Quality     string `mapstructure:"quality"`
-	MaxDuration int    `mapstructure:"max_duration"`
+	DefaultBGM         string  `mapstructure:"default_bgm"`
+	Resolution         string  `mapstructure:"resolution"`
+	FPS                int     `mapstructure:"fps"`
+	Quality            string  `mapstructure:"quality"`
+	MaxDuration        int     `mapstructure:"max_duration"`
+	TransitionDuration float64 `mapstructure:"transition_duration"` // 默认转场时长(秒)
+	KenBurns           bool    `mapstructure:"ken_burns"`           // 静态镜头自动添加推拉摇移运动
+	ReadingSpeed       float64 `mapstructure:"reading_speed"`       // 字幕阅读速度(字/秒),决定对白镜头的最短时长
+	MinShotDuration    float64 `mapstructure:"min_shot_duration"`   // 镜头最短时长(秒)
 }
 
 // LimitsConfig 限制配置
@@ -77,6 +158,58 @@ type LimitsConfig struct {
 	MaxTextLength      int `mapstructure:"max_text_length"`
 }
 
+// EstimateConfig 试运行计划的估算参数
+type EstimateConfig struct {
+	Currency         string  `mapstructure:"currency"`
+	InputTokenPrice  float64 `mapstructure:"input_token_price"`  // 每千输入token价格
+	OutputTokenPrice float64 `mapstructure:"output_token_price"` // 每千输出token价格
+	ImagePrice       float64 `mapstructure:"image_price"`        // 每张SD图像价格
+	ClipPrice        float64 `mapstructure:"clip_price"`         // 每个七牛云视频片段价格
+	ImageTime        float64 `mapstructure:"image_time"`         // 生成一张图像的耗时(秒)
+	ClipTime         float64 `mapstructure:"clip_time"`          // 生成一个视频片段的耗时(秒)
+	RenderSpeed      float64 `mapstructure:"render_speed"`       // 每秒成片的本地渲染耗时(秒)
+}
+
+// WebhookConfig 任务回调配置
+type WebhookConfig struct {
+	Timeout        int `mapstructure:"timeout"`         // 单次请求超时(秒)
+	MaxAttempts    int `mapstructure:"max_attempts"`    // 最大投递次数
+	InitialBackoff int `mapstructure:"initial_backoff"` // 首次重试间隔(秒),之后每次翻倍
+}
+
+// StylesConfig 风格预设配置
+type StylesConfig struct {
+	Dir string `mapstructure:"dir"` // 风格预设目录,每个 *.yaml 文件为一个风格
+}
+
+// TTSConfig 配音配置
+type TTSConfig struct {
+	Enabled      bool              `mapstructure:"enabled"`
+	Provider     string            `mapstructure:"provider"`      // openai, command
+	DefaultVoice string            `mapstructure:"default_voice"` // 未映射角色使用的音色
+	Voices       map[string]string `mapstructure:"voices"`        // 角色名 -> 音色
+	Ducking      float64           `mapstructure:"ducking"`       // 对白期间BGM压低的比例(0-1)
+	OpenAI       TTSOpenAIConfig   `mapstructure:"openai"`
+	Command      TTSCommandConfig  `mapstructure:"command"`
+}
+
+// TTSOpenAIConfig OpenAI兼容语音合成配置
+type TTSOpenAIConfig struct {
+	APIKey  string `mapstructure:"api_key"`  // 为空时沿用 openai.api_key
+	BaseURL string `mapstructure:"base_url"` // 为空时沿用 openai.base_url
+	Model   string `mapstructure:"model"`
+	Timeout int    `mapstructure:"timeout"`
+}
+
+// TTSCommandConfig 本地命令行语音合成配置
+type TTSCommandConfig struct {
+	Path    string   `mapstructure:"path"`
+	Args    []string `mapstructure:"args"`   // 支持占位符 {text} {voice} {emotion} {output}
+	Stdin   bool     `mapstructure:"stdin"`  // 通过标准输入传入文本
+	Format  string   `mapstructure:"format"` // 输出音频格式(文件扩展名)
+	Timeout int      `mapstructure:"timeout"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level    string `mapstructure:"level"`
@@ -90,6 +223,54 @@ func Load(configPath string) (*Config, error) {
 	v.SetConfigFile(configPath)
 	v.SetConfigType("yaml")
 
+	// 默认值
+	v.SetDefault("storage.task_store", "file")
+	v.SetDefault("storage.max_upload_size", 10<<20)
+	v.SetDefault("openai.json_mode", true)
+	v.SetDefault("llm.parse.provider", "openai")
+	v.SetDefault("llm.storyboard.provider", "openai")
+	v.SetDefault("llm.characters.provider", "openai")
+	v.SetDefault("llm.max_repairs", 2)
+	v.SetDefault("parsing.chunk_size", 3000)
+	v.SetDefault("parsing.max_in_flight", 3)
+	v.SetDefault("series.episode_length", 5000)
+	v.SetDefault("series.max_episodes", 12)
+	v.SetDefault("series.max_text_length", 1000000)
+	v.SetDefault("llm.anthropic.base_url", "https://api.anthropic.com")
+	v.SetDefault("llm.anthropic.max_tokens", 8192)
+	v.SetDefault("llm.anthropic.timeout", 120)
+	v.SetDefault("llm.ollama.base_url", "http://127.0.0.1:11434")
+	v.SetDefault("llm.ollama.timeout", 300)
+	v.SetDefault("video.transition_duration", 0.5)
+	v.SetDefault("video.ken_burns", true)
+	v.SetDefault("video_generation.qiniu.mode", "single")
+	v.SetDefault("video_generation.qiniu.max_in_flight", 2)
+	v.SetDefault("video_generation.qiniu.clip_length", 8)
+	v.SetDefault("video_generation.local_sd.backend", "a1111")
+	v.SetDefault("video_generation.local_sd.comfyui.timeout", 300)
+	v.SetDefault("video_generation.local_sd.openai.model", "dall-e-3")
+	v.SetDefault("video_generation.local_sd.openai.timeout", 120)
+	v.SetDefault("video.reading_speed", 4.0)
+	v.SetDefault("video.min_shot_duration", 2.0)
+	v.SetDefault("estimate.currency", "CNY")
+	v.SetDefault("estimate.input_token_price", 0.002)
+	v.SetDefault("estimate.output_token_price", 0.008)
+	v.SetDefault("estimate.image_time", 10.0)
+	v.SetDefault("estimate.clip_time", 90.0)
+	v.SetDefault("estimate.render_speed", 1.0)
+	v.SetDefault("webhook.timeout", 10)
+	v.SetDefault("webhook.max_attempts", 5)
+	v.SetDefault("webhook.initial_backoff", 2)
+	v.SetDefault("styles.dir", "./configs/styles")
+	v.SetDefault("tts.enabled", false)
+	v.SetDefault("tts.provider", "openai")
+	v.SetDefault("tts.default_voice", "alloy")
+	v.SetDefault("tts.ducking", 0.7)
+	v.SetDefault("tts.openai.model", "tts-1")
+	v.SetDefault("tts.openai.timeout", 60)
+	v.SetDefault("tts.command.format", "wav")
+	v.SetDefault("tts.command.timeout", 60)
+
 	// 自动读取环境变量
 	v.AutomaticEnv()
 
@@ -120,17 +301,117 @@ func expandEnvVars(v *viper.Viper) {
 	if apiKey := v.GetString("openai.api_key"); apiKey != "" {
 		v.Set("openai.api_key", os.ExpandEnv(apiKey))
 	}
+
+	// Anthropic API Key
+	if apiKey := v.GetString("llm.anthropic.api_key"); apiKey != "" {
+		v.Set("llm.anthropic.api_key", os.ExpandEnv(apiKey))
+	}
+
+	// TTS API Key
+	if apiKey := v.GetString("tts.openai.api_key"); apiKey != "" {
+		v.Set("tts.openai.api_key", os.ExpandEnv(apiKey))
+	}
+
+	// 图像生成 API Key
+	if apiKey := v.GetString("video_generation.local_sd.openai.api_key"); apiKey != "" {
+		v.Set("video_generation.local_sd.openai.api_key", os.ExpandEnv(apiKey))
+	}
 }
 
 // validate 验证配置
 func validate(cfg *Config) error {
-	// 验证OpenAI配置
-	if cfg.OpenAI.APIKey == "" {
-		return fmt.Errorf("openai.api_key is required")
+	// 验证大语言模型配置
+	if cfg.LLM.MaxRepairs < 0 {
+		return fmt.Errorf("llm.max_repairs cannot be negative")
+	}
+	steps := map[string]LLMStepConfig{
+		"parse":      cfg.LLM.Parse,
+		"storyboard": cfg.LLM.Storyboard,
+		"characters": cfg.LLM.Characters,
+	}
+	for name, step := range steps {
+		switch step.Provider {
+		case "openai":
+			if cfg.OpenAI.APIKey == "" {
+				return fmt.Errorf("openai.api_key is required when llm.%s.provider is 'openai'", name)
+			}
+		case "anthropic":
+			if cfg.LLM.Anthropic.APIKey == "" {
+				return fmt.Errorf("llm.anthropic.api_key is required when llm.%s.provider is 'anthropic'", name)
+			}
+			if step.Model == "" && cfg.LLM.Anthropic.Model == "" {
+				return fmt.Errorf("llm.%s.model or llm.anthropic.model is required", name)
+			}
+		case "ollama":
+			if step.Model == "" && cfg.LLM.Ollama.Model == "" {
+				return fmt.Errorf("llm.%s.model or llm.ollama.model is required", name)
+			}
+		default:
+			return fmt.Errorf("llm.%s.provider must be one of: openai, anthropic, ollama", name)
+		}
+	}
+
+	// 验证剧本解析配置
+	if cfg.Parsing.ChunkSize < 0 {
+		return fmt.Errorf("parsing.chunk_size cannot be negative")
+	}
+	if cfg.Parsing.MaxInFlight <= 0 {
+		return fmt.Errorf("parsing.max_in_flight must be positive")
+	}
+
+	// 验证估算配置
+	e := cfg.Estimate
+	for name, value := range map[string]float64{
+		"input_token_price":  e.InputTokenPrice,
+		"output_token_price": e.OutputTokenPrice,
+		"image_price":        e.ImagePrice,
+		"clip_price":         e.ClipPrice,
+		"image_time":         e.ImageTime,
+		"clip_time":          e.ClipTime,
+		"render_speed":       e.RenderSpeed,
+	} {
+		if value < 0 {
+			return fmt.Errorf("estimate.%s cannot be negative", name)
+		}
+	}
+
+	// 验证剧集配置
+	if cfg.Series.EpisodeLength <= 0 {
+		return fmt.Errorf("series.episode_length must be positive")
+	}
+	if cfg.Series.MaxEpisodes <= 0 {
+		return fmt.Errorf("series.max_episodes must be positive")
+	}
+	if cfg.Series.MaxTextLength <= 0 {
+		return fmt.Errorf("series.max_text_length must be positive")
+	}
+
+	// 验证任务存储配置
+	if cfg.Storage.TaskStore != "memory" && cfg.Storage.TaskStore != "file" {
+		return fmt.Errorf("storage.task_store must be one of: memory, file")
+	}
+	if cfg.Storage.MaxUploadSize <= 0 {
+		return fmt.Errorf("storage.max_upload_size must be positive")
+	}
+
+	// 验证配音配置
+	if cfg.TTS.Enabled {
+		switch cfg.TTS.Provider {
+		case "openai":
+		case "command":
+			if cfg.TTS.Command.Path == "" {
+				return fmt.Errorf("tts.command.path is required when provider is 'command'")
+			}
+		default:
+			return fmt.Errorf("tts.provider must be one of: openai, command")
+		}
+		if cfg.TTS.Ducking < 0 || cfg.TTS.Ducking >= 1 {
+			return fmt.Errorf("tts.ducking must be in [0, 1)")
+		}
 	}
 
 	// 验证视频生成配置
-	validTypes := []string{"qiniu", "local_sd"}
+	validTypes := []string{"qiniu", "local_sd", "hybrid"}
 	isValid := false
 	for _, t := range validTypes {
 		if cfg.VideoGeneration.Type == t {
@@ -139,15 +420,32 @@ func validate(cfg *Config) error {
 		}
 	}
 	if !isValid {
-		return fmt.Errorf("video_generation.type must be one of: qiniu, local_sd")
+		return fmt.Errorf("video_generation.type must be one of: qiniu, local_sd, hybrid")
 	}
 
-	if cfg.VideoGeneration.Type == "qiniu" && cfg.VideoGeneration.Qiniu.APIKey == "" {
-		return fmt.Errorf("video_generation.qiniu.api_key is required when type is 'qiniu'")
+	if cfg.VideoGeneration.Type != "local_sd" && cfg.VideoGeneration.Qiniu.APIKey == "" {
+		return fmt.Errorf("video_generation.qiniu.api_key is required when type is '%s'", cfg.VideoGeneration.Type)
 	}
 
-	if cfg.VideoGeneration.Type == "local_sd" && cfg.VideoGeneration.LocalSD.APIURL == "" {
-		return fmt.Errorf("video_generation.local_sd.api_url is required when type is 'local_sd'")
+	if mode := cfg.VideoGeneration.Qiniu.Mode; mode != "single" && mode != "per_shot" {
+		return fmt.Errorf("video_generation.qiniu.mode must be one of: single, per_shot")
+	}
+
+	if cfg.VideoGeneration.Type != "qiniu" {
+		sd := cfg.VideoGeneration.LocalSD
+		switch sd.Backend {
+		case "a1111":
+			if sd.APIURL == "" {
+				return fmt.Errorf("video_generation.local_sd.api_url is required when backend is 'a1111'")
+			}
+		case "comfyui":
+			if sd.ComfyUI.APIURL == "" || sd.ComfyUI.Workflow == "" {
+				return fmt.Errorf("video_generation.local_sd.comfyui.api_url and workflow are required when backend is 'comfyui'")
+			}
+		case "openai", "placeholder":
+		default:
+			return fmt.Errorf("video_generation.local_sd.backend must be one of: a1111, comfyui, openai, placeholder")
+		}
 	}
 
 	return nil
@@ -0,0 +1,133 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// maxTempo 配音超出镜头时长时允许的最大加速倍数(atempo单级上限)
+const maxTempo = 2.0
+
+// Audio 视频音轨
+type Audio struct {
+	BGMPath string      // 背景音乐,循环播放
+	Voices  []VoiceClip // 配音片段
+	Ducking float64     // 配音期间BGM压低的比例(0-1)
+}
+
+// VoiceClip 配音片段
+type VoiceClip struct {
+	Path        string
+	Start       float64 // 在时间线上的开始时间(秒)
+	Length      float64 // 音频实际时长(秒)
+	MaxDuration float64 // 允许占用的最长时长(秒),超出时先加速,仍超出则截断;0表示不限制
+}
+
+// tempo 片段需要的加速倍数
+func (v VoiceClip) tempo() float64 {
+	if v.MaxDuration <= 0 || v.Length <= v.MaxDuration {
+		return 1
+	}
+	return math.Min(v.Length/v.MaxDuration, maxTempo)
+}
+
+// End 片段在时间线上的结束时间
+func (v VoiceClip) End() float64 {
+	length := v.Length / v.tempo()
+	if v.MaxDuration > 0 {
+		length = math.Min(length, v.MaxDuration)
+	}
+	return v.Start + length
+}
+
+// BuildAudioGraph 构建音轨滤镜图
+// bgmInput 为BGM的输入序号(-1表示没有BGM),voiceInput 为第一个配音片段的输入序号,配音片段依次排列。
+// 配音按开始时间延迟后混合,BGM在每段配音期间按 ducking 压低音量。
+// 返回滤镜图和输出标签,没有任何音频时返回空字符串
+func BuildAudioGraph(audio Audio, bgmInput, voiceInput int) (string, string) {
+	var graph []string
+
+	// 配音片段: 统一格式、按需加速截断、延迟到镜头开始时间
+	var voiceLabels []string
+	for i, voice := range audio.Voices {
+		chain := fmt.Sprintf("[%d:a]aformat=sample_rates=44100:channel_layouts=stereo", voiceInput+i)
+		if tempo := voice.tempo(); tempo > 1 {
+			chain += fmt.Sprintf(",atempo=%.3f", tempo)
+		}
+		if voice.MaxDuration > 0 {
+			chain += fmt.Sprintf(",atrim=end=%.3f", voice.MaxDuration)
+		}
+		delay := int(math.Round(voice.Start * 1000))
+		chain += fmt.Sprintf(",adelay=%d:all=1", delay)
+
+		label := fmt.Sprintf("a%d", i)
+		graph = append(graph, fmt.Sprintf("%s[%s]", chain, label))
+		voiceLabels = append(voiceLabels, "["+label+"]")
+	}
+
+	voice := ""
+	switch len(voiceLabels) {
+	case 0:
+	case 1:
+		voice = "a0"
+	default:
+		voice = "voice"
+		graph = append(graph, fmt.Sprintf("%samix=inputs=%d:duration=longest:normalize=0[%s]",
+			strings.Join(voiceLabels, ""), len(voiceLabels), voice))
+	}
+
+	switch {
+	case bgmInput < 0 && voice == "":
+		return "", ""
+	case bgmInput < 0:
+		// 只有配音: 补齐静音,由 -t 截断到视频总时长
+		graph = append(graph, fmt.Sprintf("[%s]apad[aout]", voice))
+	case voice == "":
+		graph = append(graph, fmt.Sprintf("[%d:a]anull[aout]", bgmInput))
+	default:
+		// 配音期间压低BGM,再与配音混合
+		graph = append(graph, fmt.Sprintf("[%d:a]aformat=sample_rates=44100:channel_layouts=stereo,volume='%s':eval=frame[bgm]",
+			bgmInput, duckingExpr(audio.Voices, audio.Ducking)))
+		graph = append(graph, fmt.Sprintf("[bgm][%s]amix=inputs=2:duration=first:normalize=0[aout]", voice))
+	}
+
+	return strings.Join(graph, ";"), "aout"
+}
+
+// duckingExpr BGM音量表达式,任一配音播放期间音量为 1-ducking
+func duckingExpr(voices []VoiceClip, ducking float64) string {
+	if ducking <= 0 || len(voices) == 0 {
+		return "1"
+	}
+
+	ranges := make([]string, 0, len(voices))
+	for _, voice := range voices {
+		ranges = append(ranges, fmt.Sprintf("between(t,%.3f,%.3f)", voice.Start, voice.End()))
+	}
+	return fmt.Sprintf("if(%s,%.3f,1)", strings.Join(ranges, "+"), 1-ducking)
+}
+
+// ProbeDuration 使用ffprobe获取媒体文件时长(秒)
+func (f *FFmpeg) ProbeDuration(ctx context.Context, path string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "csv=p=0",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe duration: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ffprobe output %q: %w", string(output), err)
+	}
+	return duration, nil
+}
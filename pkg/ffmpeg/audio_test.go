@@ -0,0 +1,115 @@
+package ffmpeg
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestVoiceClipEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		clip VoiceClip
+		want float64
+	}{
+		{name: "fits in shot", clip: VoiceClip{Start: 2, Length: 1.5, MaxDuration: 3}, want: 3.5},
+		{name: "sped up to fit", clip: VoiceClip{Start: 0, Length: 4, MaxDuration: 3}, want: 3},
+		{name: "truncated beyond max tempo", clip: VoiceClip{Start: 1, Length: 10, MaxDuration: 2}, want: 3},
+		{name: "unlimited", clip: VoiceClip{Start: 1, Length: 10}, want: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.clip.End(); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("End() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildAudioGraph(t *testing.T) {
+	voices := []VoiceClip{
+		{Path: "a.mp3", Start: 0, Length: 4, MaxDuration: 3},
+		{Path: "b.mp3", Start: 3.5, Length: 1, MaxDuration: 2},
+	}
+
+	tests := []struct {
+		name       string
+		audio      Audio
+		bgmInput   int
+		voiceInput int
+		contains   []string
+		empty      bool
+	}{
+		{name: "no audio", bgmInput: -1, voiceInput: 1, empty: true},
+		{
+			name:     "bgm only",
+			audio:    Audio{BGMPath: "bgm.mp3"},
+			bgmInput: 1,
+			contains: []string{"[1:a]anull[aout]"},
+		},
+		{
+			name:       "single voice without bgm",
+			audio:      Audio{Voices: voices[1:]},
+			bgmInput:   -1,
+			voiceInput: 1,
+			contains:   []string{"[1:a]aformat", "atrim=end=2.000", "adelay=3500:all=1[a0]", "[a0]apad[aout]"},
+		},
+		{
+			name:       "voices ducking bgm",
+			audio:      Audio{BGMPath: "bgm.mp3", Voices: voices, Ducking: 0.6},
+			bgmInput:   1,
+			voiceInput: 2,
+			contains: []string{
+				"[2:a]aformat=sample_rates=44100:channel_layouts=stereo,atempo=1.333,atrim=end=3.000,adelay=0:all=1[a0]",
+				"[3:a]aformat",
+				"[a0][a1]amix=inputs=2:duration=longest:normalize=0[voice]",
+				"volume='if(between(t,0.000,3.000)+between(t,3.500,4.500),0.400,1)':eval=frame[bgm]",
+				"[bgm][voice]amix=inputs=2:duration=first:normalize=0[aout]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, label := BuildAudioGraph(tt.audio, tt.bgmInput, tt.voiceInput)
+			if tt.empty {
+				if graph != "" || label != "" {
+					t.Errorf("BuildAudioGraph() = %q, %q, want empty", graph, label)
+				}
+				return
+			}
+			if label != "aout" {
+				t.Errorf("label = %q, want aout", label)
+			}
+			for _, part := range tt.contains {
+				if !strings.Contains(graph, part) {
+					t.Errorf("graph %q missing %q", graph, part)
+				}
+			}
+		})
+	}
+}
+
+func TestDuckingExpr(t *testing.T) {
+	voices := []VoiceClip{{Start: 1, Length: 2}}
+
+	tests := []struct {
+		name    string
+		voices  []VoiceClip
+		ducking float64
+		want    string
+	}{
+		{name: "no ducking", voices: voices, want: "1"},
+		{name: "no voices", ducking: 0.5, want: "1"},
+		{name: "ducked", voices: voices, ducking: 0.5, want: "if(between(t,1.000,3.000),0.500,1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := duckingExpr(tt.voices, tt.ducking); got != tt.want {
+				t.Errorf("duckingExpr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
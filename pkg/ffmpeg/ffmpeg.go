@@ -122,9 +122,9 @@ func (f *FFmpeg) AddSubtitles(ctx context.Context, inputVideo, subtitleFile, out
 }
 
 // GetVideoInfo 获取视频信息
-func (f *FFmpeg) GetVideoInfo(videoPath string) (map[string]interface{}, error) {
+func (f *FFmpeg) GetVideoInfo(ctx context.Context, videoPath string) (map[string]interface{}, error) {
 	// 使用ffprobe获取视频信息
-	cmd := exec.Command("ffprobe",
+	cmd := exec.CommandContext(ctx, "ffprobe",
 		"-v", "quiet",
 		"-print_format", "json",
 		"-show_format",
@@ -145,6 +145,28 @@ func (f *FFmpeg) GetVideoInfo(videoPath string) (map[string]interface{}, error)
 	return info, nil
 }
 
+// ProbeResolution 使用ffprobe获取视频宽高
+func (f *FFmpeg) ProbeResolution(ctx context.Context, videoPath string) (int, int, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height",
+		"-of", "csv=s=x:p=0",
+		videoPath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to probe video: %w", err)
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%dx%d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ffprobe output %q: %w", string(output), err)
+	}
+	return width, height, nil
+}
+
 // CreateThumbnail 创建视频缩略图
 func (f *FFmpeg) CreateThumbnail(ctx context.Context, videoPath, thumbnailPath string, timeOffset float64) error {
 	logger.Info("Creating thumbnail",
@@ -171,3 +193,138 @@ func (f *FFmpeg) CreateThumbnail(ctx context.Context, videoPath, thumbnailPath s
 	logger.Info("Thumbnail created successfully", zap.String("output", thumbnailPath))
 	return nil
 }
+
+// ConcatInput 待拼接的视频文件
+type ConcatInput struct {
+	Path     string
+	Duration float64 // 时长(秒),没有音轨时按该时长补静音
+	HasAudio bool
+}
+
+// BuildConcatGraph 构建拼接视频的filter_complex滤镜图
+// 每个输入统一尺寸、帧率和像素格式;只要有一个输入带音轨,没有音轨的输入补相同时长的静音。
+// 返回滤镜图和是否输出音轨,输出标签为 [v] 和 [a]
+func BuildConcatGraph(inputs []ConcatInput, width, height, fps int) (string, bool) {
+	withAudio := false
+	for _, input := range inputs {
+		withAudio = withAudio || input.HasAudio
+	}
+
+	var graph []string
+	var concat strings.Builder
+	for i, input := range inputs {
+		graph = append(graph, fmt.Sprintf("[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,"+
+			"pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d,format=yuv420p,setpts=PTS-STARTPTS[v%d]",
+			i, width, height, width, height, fps, i))
+		fmt.Fprintf(&concat, "[v%d]", i)
+
+		if !withAudio {
+			continue
+		}
+		if input.HasAudio {
+			graph = append(graph, fmt.Sprintf("[%d:a]aformat=sample_rates=44100:channel_layouts=stereo,asetpts=PTS-STARTPTS[a%d]", i, i))
+		} else {
+			graph = append(graph, fmt.Sprintf("anullsrc=r=44100:cl=stereo,atrim=duration=%.3f[a%d]", input.Duration, i))
+		}
+		fmt.Fprintf(&concat, "[a%d]", i)
+	}
+
+	if withAudio {
+		fmt.Fprintf(&concat, "concat=n=%d:v=1:a=1[v][a]", len(inputs))
+	} else {
+		fmt.Fprintf(&concat, "concat=n=%d:v=1:a=0[v]", len(inputs))
+	}
+	graph = append(graph, concat.String())
+
+	return strings.Join(graph, ";"), withAudio
+}
+
+// ProbeHasAudio 使用ffprobe检查媒体文件是否包含音轨
+func (f *FFmpeg) ProbeHasAudio(ctx context.Context, path string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=index",
+		"-of", "csv=p=0",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to probe audio: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// ConcatFiles 按顺序拼接视频文件并重新编码
+// 各文件的分辨率、帧率和音轨可以不同: 画面统一缩放到第一个文件的分辨率,没有音轨的文件补静音
+func (f *FFmpeg) ConcatFiles(ctx context.Context, paths []string, outputPath string, fps int) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no videos to concatenate")
+	}
+
+	logger.Info("Concatenating videos",
+		zap.Int("inputs", len(paths)),
+		zap.String("output", outputPath))
+
+	width, height, err := f.ProbeResolution(ctx, paths[0])
+	if err != nil {
+		return err
+	}
+
+	inputs := make([]ConcatInput, 0, len(paths))
+	var args []string
+	for _, path := range paths {
+		duration, err := f.ProbeDuration(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to probe %s: %w", path, err)
+		}
+		hasAudio, err := f.ProbeHasAudio(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to probe %s: %w", path, err)
+		}
+		inputs = append(inputs, ConcatInput{Path: path, Duration: duration, HasAudio: hasAudio})
+		args = append(args, "-i", path)
+	}
+
+	graph, withAudio := BuildConcatGraph(inputs, width, height, fps)
+	args = append(args,
+		"-filter_complex", graph,
+		"-map", "[v]",
+	)
+	if withAudio {
+		args = append(args, "-map", "[a]")
+	}
+
+	args = append(args,
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		"-preset", "medium",
+		"-crf", "23",
+	)
+	if withAudio {
+		args = append(args,
+			"-c:a", "aac",
+			"-b:a", "192k",
+		)
+	}
+	args = append(args,
+		"-movflags", "+faststart",
+		"-y", outputPath,
+	)
+
+	cmd := exec.CommandContext(ctx, f.binaryPath, args...)
+
+	logger.Debug("Executing FFmpeg command", zap.String("command", cmd.String()))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		logger.Error("Failed to concatenate videos",
+			zap.Error(err),
+			zap.String("output", string(output)))
+		return fmt.Errorf("failed to concatenate videos: %w\nOutput: %s", err, string(output))
+	}
+
+	logger.Info("Videos concatenated successfully", zap.String("output", outputPath))
+	return nil
+}
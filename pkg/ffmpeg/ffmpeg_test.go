@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildConcatGraph(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputs    []ConcatInput
+		withAudio bool
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name: "all inputs with audio",
+			inputs: []ConcatInput{
+				{Path: "1.mp4", Duration: 10, HasAudio: true},
+				{Path: "2.mp4", Duration: 8, HasAudio: true},
+			},
+			withAudio: true,
+			contains: []string{
+				"[0:v]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=30,format=yuv420p",
+				"[1:a]aformat=sample_rates=44100:channel_layouts=stereo",
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[v][a]",
+			},
+			excludes: []string{"anullsrc"},
+		},
+		{
+			name: "silent input gets padded with silence",
+			inputs: []ConcatInput{
+				{Path: "1.mp4", Duration: 10, HasAudio: true},
+				{Path: "2.mp4", Duration: 8.5},
+			},
+			withAudio: true,
+			contains: []string{
+				"anullsrc=r=44100:cl=stereo,atrim=duration=8.500[a1]",
+				"[v0][a0][v1][a1]concat=n=2:v=1:a=1[v][a]",
+			},
+			excludes: []string{"[1:a]"},
+		},
+		{
+			name: "no input with audio",
+			inputs: []ConcatInput{
+				{Path: "1.mp4", Duration: 10},
+				{Path: "2.mp4", Duration: 8},
+			},
+			contains: []string{"[v0][v1]concat=n=2:v=1:a=0[v]"},
+			excludes: []string{"anullsrc", ":a]", "[a0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, withAudio := BuildConcatGraph(tt.inputs, 1280, 720, 30)
+			if withAudio != tt.withAudio {
+				t.Errorf("withAudio = %v, want %v", withAudio, tt.withAudio)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(graph, want) {
+					t.Errorf("graph missing %q:\n%s", want, graph)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(graph, unwanted) {
+					t.Errorf("graph contains %q:\n%s", unwanted, graph)
+				}
+			}
+		})
+	}
+}
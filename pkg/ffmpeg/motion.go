@@ -0,0 +1,92 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+)
+
+// 镜头运动预设
+const (
+	MotionNone     = "none"
+	MotionZoomIn   = "zoom_in"
+	MotionZoomOut  = "zoom_out"
+	MotionPanLeft  = "pan_left"
+	MotionPanRight = "pan_right"
+	MotionTiltUp   = "tilt_up"
+	MotionTiltDown = "tilt_down"
+)
+
+// motionZoom 运动镜头的最大放大倍数,平移时保持该倍数以留出移动空间
+const motionZoom = 1.2
+
+// hasMotion 片段是否使用运动效果,视频片段不使用
+func (c Clip) hasMotion() bool {
+	return c.VideoPath == "" && c.Motion != "" && c.Motion != MotionNone
+}
+
+// zoompanExpr 运动预设对应的zoompan缩放和位置表达式
+// 表达式只依赖输出帧序号,相同输入总是得到相同画面
+func zoompanExpr(motion string, frames int) (z, x, y string) {
+	// p: 0到1的运动进度
+	p := fmt.Sprintf("on/%d", max(frames-1, 1))
+	centerX := "iw/2-(iw/zoom/2)"
+	centerY := "ih/2-(ih/zoom/2)"
+	zoom := fmt.Sprintf("%.2f", motionZoom)
+
+	switch motion {
+	case MotionZoomIn:
+		return fmt.Sprintf("1+%.2f*%s", motionZoom-1, p), centerX, centerY
+	case MotionZoomOut:
+		return fmt.Sprintf("%s-%.2f*%s", zoom, motionZoom-1, p), centerX, centerY
+	case MotionPanLeft:
+		return zoom, fmt.Sprintf("(iw-iw/zoom)*(1-%s)", p), centerY
+	case MotionPanRight:
+		return zoom, fmt.Sprintf("(iw-iw/zoom)*%s", p), centerY
+	case MotionTiltUp:
+		return zoom, centerX, fmt.Sprintf("(ih-ih/zoom)*(1-%s)", p)
+	case MotionTiltDown:
+		return zoom, centerX, fmt.Sprintf("(ih-ih/zoom)*%s", p)
+	default:
+		return "1", centerX, centerY
+	}
+}
+
+// clipFilter 单个片段的滤镜链: 统一尺寸、帧率和像素格式,运动片段额外应用zoompan
+func clipFilter(index int, clip Clip, duration float64, width, height, fps int) string {
+	if !clip.hasMotion() {
+		return fmt.Sprintf("[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,"+
+			"pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d,format=yuv420p,"+
+			"trim=duration=%.3f,setpts=PTS-STARTPTS",
+			index, width, height, width, height, fps, duration)
+	}
+
+	// 先放大到2倍画布再缩放平移,减少zoompan取整造成的抖动
+	frames := int(math.Ceil(duration * float64(fps)))
+	z, x, y := zoompanExpr(clip.Motion, frames)
+	return fmt.Sprintf("[%d:v]scale=%d:%d:force_original_aspect_ratio=increase,crop=%d:%d,setsar=1,"+
+		"zoompan=z='%s':x='%s':y='%s':d=%d:s=%dx%d:fps=%d,format=yuv420p,"+
+		"trim=duration=%.3f,setpts=PTS-STARTPTS",
+		index, width*2, height*2, width*2, height*2,
+		z, x, y, frames, width, height, fps, duration)
+}
+
+// inputArgs 片段的FFmpeg输入参数
+// 静态片段循环读取图片;运动片段只读取一帧,由zoompan生成全部帧;视频片段循环读取到所需时长
+func inputArgs(clip Clip, duration float64, fps int) []string {
+	if clip.VideoPath != "" {
+		return []string{
+			"-stream_loop", "-1",
+			"-t", fmt.Sprintf("%.3f", duration),
+			"-i", clip.VideoPath,
+		}
+	}
+	if clip.hasMotion() {
+		return []string{"-i", clip.ImagePath}
+	}
+	return []string{
+		"-loop", "1",
+		"-framerate", fmt.Sprintf("%d", fps),
+		"-t", fmt.Sprintf("%.3f", duration),
+		"-i", clip.ImagePath,
+	}
+}
@@ -0,0 +1,206 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+
+	"github.com/Jancd/1504/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// 转场类型
+const (
+	TransitionCut      = "cut"
+	TransitionFade     = "fade"     // 经黑场淡出淡入
+	TransitionDissolve = "dissolve" // 两个画面交叉溶解
+)
+
+// Clip 时间线片段,静态图片或视频片段
+type Clip struct {
+	ImagePath          string
+	VideoPath          string  // 视频片段,设置后忽略ImagePath和Motion;比Duration短时循环,长时截断
+	Duration           float64 // 片段在时间线上的时长(秒)
+	Transition         string  // 进入该片段的转场,第一个片段的fade表示从黑场淡入
+	TransitionDuration float64 // 转场时长(秒)
+	Motion             string  // 镜头运动预设,空或none为静止画面
+}
+
+// xfadeTransition 转场类型对应的xfade效果
+func xfadeTransition(transition string) string {
+	switch transition {
+	case TransitionFade:
+		return "fadeblack"
+	case TransitionDissolve:
+		return "fade"
+	default:
+		return ""
+	}
+}
+
+// transitionDurations 计算每个片段实际使用的转场时长
+// cut转场为0;其他转场不超过相邻两个片段中较短者的一半,保证每个片段都有完整画面停留
+func transitionDurations(clips []Clip) []float64 {
+	durations := make([]float64, len(clips))
+	for i, clip := range clips {
+		if xfadeTransition(clip.Transition) == "" || clip.TransitionDuration <= 0 {
+			continue
+		}
+
+		limit := clip.Duration / 2
+		if i > 0 {
+			limit = math.Min(limit, clips[i-1].Duration/2)
+		}
+		durations[i] = math.Min(clip.TransitionDuration, limit)
+	}
+	return durations
+}
+
+// BuildTransitionGraph 构建带转场的filter_complex滤镜图
+// 第i个输入对应clips[i],每个输入需要以 inputDurations 返回的时长循环读取图片。
+// 转场与前一片段重叠,前一片段相应延长,因此输出总时长等于各片段Duration之和。
+// 返回滤镜图、输出标签和总时长
+func BuildTransitionGraph(clips []Clip, width, height, fps int) (string, string, float64) {
+	overlaps := transitionDurations(clips)
+	inputDurations := InputDurations(clips)
+
+	var graph []string
+
+	// 统一每个片段的尺寸、帧率和像素格式
+	for i, clip := range clips {
+		chain := clipFilter(i, clip, inputDurations[i], width, height, fps)
+
+		// 第一个片段的淡入转场: 从黑场淡入
+		if i == 0 && clip.Transition == TransitionFade && clip.TransitionDuration > 0 {
+			chain += fmt.Sprintf(",fade=t=in:st=0:d=%.3f", math.Min(clip.TransitionDuration, clip.Duration/2))
+		}
+
+		graph = append(graph, fmt.Sprintf("%s[v%d]", chain, i))
+	}
+
+	// 依次连接片段: cut使用concat,其他转场使用xfade
+	last := "v0"
+	length := inputDurations[0]
+	for i := 1; i < len(clips); i++ {
+		out := fmt.Sprintf("x%d", i)
+		if overlaps[i] > 0 {
+			offset := length - overlaps[i]
+			graph = append(graph, fmt.Sprintf("[%s][v%d]xfade=transition=%s:duration=%.3f:offset=%.3f[%s]",
+				last, i, xfadeTransition(clips[i].Transition), overlaps[i], offset, out))
+			length = offset + inputDurations[i]
+		} else {
+			graph = append(graph, fmt.Sprintf("[%s][v%d]concat=n=2:v=1:a=0[%s]", last, i, out))
+			length += inputDurations[i]
+		}
+		last = out
+	}
+
+	return strings.Join(graph, ";"), last, length
+}
+
+// InputDurations 每个片段输入需要读取的时长
+// 片段后面有转场时需要额外延长转场时长,供下一片段重叠
+func InputDurations(clips []Clip) []float64 {
+	overlaps := transitionDurations(clips)
+	durations := make([]float64, len(clips))
+	for i, clip := range clips {
+		durations[i] = clip.Duration
+		if i+1 < len(clips) {
+			durations[i] += overlaps[i+1]
+		}
+	}
+	return durations
+}
+
+// RenderClips 将片段按转场合成为视频,并混合BGM和配音
+func (f *FFmpeg) RenderClips(ctx context.Context, clips []Clip, audio Audio, outputPath string, width, height, fps int) error {
+	if len(clips) == 0 {
+		return fmt.Errorf("no clips to render")
+	}
+
+	logger.Info("Rendering clips with transitions",
+		zap.Int("clips", len(clips)),
+		zap.String("bgm", audio.BGMPath),
+		zap.Int("voices", len(audio.Voices)),
+		zap.String("output", outputPath),
+		zap.Int("width", width),
+		zap.Int("height", height),
+		zap.Int("fps", fps))
+
+	graph, videoLabel, total := BuildTransitionGraph(clips, width, height, fps)
+	inputDurations := InputDurations(clips)
+
+	var args []string
+	for i, clip := range clips {
+		args = append(args, inputArgs(clip, inputDurations[i], fps)...)
+	}
+
+	// BGM循环播放,由 -t 截断到视频总时长
+	bgmInput := -1
+	if audio.BGMPath != "" {
+		bgmInput = len(clips)
+		args = append(args, "-stream_loop", "-1", "-i", audio.BGMPath)
+	}
+
+	voiceInput := len(clips)
+	if bgmInput >= 0 {
+		voiceInput++
+	}
+	for _, voice := range audio.Voices {
+		args = append(args, "-i", voice.Path)
+	}
+
+	audioGraph, audioLabel := BuildAudioGraph(audio, bgmInput, voiceInput)
+	if audioGraph != "" {
+		graph += ";" + audioGraph
+	}
+
+	args = append(args,
+		"-filter_complex", graph,
+		"-map", fmt.Sprintf("[%s]", videoLabel),
+	)
+
+	if audioLabel != "" {
+		args = append(args, "-map", fmt.Sprintf("[%s]", audioLabel))
+	}
+
+	args = append(args,
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p", // 兼容性格式
+		"-r", fmt.Sprintf("%d", fps),
+		"-preset", "medium",
+		"-crf", "23",
+	)
+
+	if audioLabel != "" {
+		args = append(args,
+			"-c:a", "aac",
+			"-b:a", "192k",
+		)
+	}
+
+	args = append(args,
+		"-t", fmt.Sprintf("%.3f", total),
+		"-y", outputPath,
+	)
+
+	cmd := exec.CommandContext(ctx, f.binaryPath, args...)
+
+	logger.Debug("Executing FFmpeg command", zap.String("command", cmd.String()))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		logger.Error("FFmpeg command failed",
+			zap.Error(err),
+			zap.String("output", string(output)))
+		return fmt.Errorf("ffmpeg failed: %w\nOutput: %s", err, string(output))
+	}
+
+	logger.Info("Video created successfully",
+		zap.String("output", outputPath),
+		zap.Float64("duration", total))
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package ffmpeg
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransitionDurations(t *testing.T) {
+	tests := []struct {
+		name  string
+		clips []Clip
+		want  []float64
+	}{
+		{
+			name: "cuts have no overlap",
+			clips: []Clip{
+				{Duration: 4},
+				{Duration: 4, Transition: TransitionCut, TransitionDuration: 1},
+			},
+			want: []float64{0, 0},
+		},
+		{
+			name: "first clip fade is limited by its own duration",
+			clips: []Clip{
+				{Duration: 1, Transition: TransitionFade, TransitionDuration: 1},
+				{Duration: 4, Transition: TransitionDissolve, TransitionDuration: 1},
+			},
+			want: []float64{0.5, 0.5},
+		},
+		{
+			name: "limited by half of the shorter neighbour",
+			clips: []Clip{
+				{Duration: 2},
+				{Duration: 6, Transition: TransitionFade, TransitionDuration: 3},
+				{Duration: 1, Transition: TransitionDissolve, TransitionDuration: 3},
+			},
+			want: []float64{0, 1, 0.5},
+		},
+		{
+			name: "non-positive duration is a cut",
+			clips: []Clip{
+				{Duration: 4},
+				{Duration: 4, Transition: TransitionDissolve, TransitionDuration: -1},
+			},
+			want: []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := transitionDurations(tt.clips)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("transitionDurations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputDurations(t *testing.T) {
+	clips := []Clip{
+		{Duration: 4},
+		{Duration: 4, Transition: TransitionDissolve, TransitionDuration: 1},
+		{Duration: 3, Transition: TransitionCut},
+		{Duration: 2, Transition: TransitionFade, TransitionDuration: 0.5},
+	}
+
+	want := []float64{5, 4, 3.5, 2}
+	if got := InputDurations(clips); !reflect.DeepEqual(got, want) {
+		t.Errorf("InputDurations() = %v, want %v", got, want)
+	}
+}
+
+func TestBuildTransitionGraph(t *testing.T) {
+	tests := []struct {
+		name     string
+		clips    []Clip
+		label    string
+		contains []string
+	}{
+		{
+			name:     "single clip",
+			clips:    []Clip{{ImagePath: "a.png", Duration: 3}},
+			label:    "v0",
+			contains: []string{"[v0]"},
+		},
+		{
+			name: "fade in from black on first clip",
+			clips: []Clip{
+				{ImagePath: "a.png", Duration: 1, Transition: TransitionFade, TransitionDuration: 2},
+			},
+			label:    "v0",
+			contains: []string{"fade=t=in:st=0:d=0.500"},
+		},
+		{
+			name: "xfade offset accounts for extended inputs",
+			clips: []Clip{
+				{ImagePath: "a.png", Duration: 4},
+				{ImagePath: "b.png", Duration: 4, Transition: TransitionDissolve, TransitionDuration: 1},
+				{ImagePath: "c.png", Duration: 4, Transition: TransitionFade, TransitionDuration: 1},
+			},
+			label: "x2",
+			contains: []string{
+				"[v0][v1]xfade=transition=fade:duration=1.000:offset=4.000[x1]",
+				"[x1][v2]xfade=transition=fadeblack:duration=1.000:offset=8.000[x2]",
+			},
+		},
+		{
+			name: "cut after transition uses concat",
+			clips: []Clip{
+				{ImagePath: "a.png", Duration: 2},
+				{ImagePath: "b.png", Duration: 3, Transition: TransitionDissolve, TransitionDuration: 0.5},
+				{ImagePath: "c.png", Duration: 5, Transition: TransitionCut},
+			},
+			label: "x2",
+			contains: []string{
+				"[v0][v1]xfade=transition=fade:duration=0.500:offset=2.000[x1]",
+				"[x1][v2]concat=n=2:v=1:a=0[x2]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, label, total := BuildTransitionGraph(tt.clips, 1280, 720, 30)
+			if label != tt.label {
+				t.Errorf("label = %q, want %q", label, tt.label)
+			}
+
+			// 输出总时长等于各片段时长之和
+			var want float64
+			for _, clip := range tt.clips {
+				want += clip.Duration
+			}
+			if math.Abs(total-want) > 1e-9 {
+				t.Errorf("total = %v, want %v", total, want)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(graph, s) {
+					t.Errorf("graph missing %q\ngraph: %s", s, graph)
+				}
+			}
+		})
+	}
+}
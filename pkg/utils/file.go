@@ -17,6 +17,7 @@ func EnsureDir(dir string) error {
 }
 
 // SaveJSON 保存JSON文件
+// 先写临时文件再重命名,并发读取的一方不会读到写了一半的文件
 func SaveJSON(path string, data interface{}) error {
 	// 确保目录存在
 	dir := filepath.Dir(path)
@@ -31,7 +32,23 @@ func SaveJSON(path string, data interface{}) error {
 	}
 
 	// 写入文件
-	if err := os.WriteFile(path, jsonData, 0644); err != nil {
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(jsonData)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseResolution 解析 "1920x1080" 格式的分辨率
+func ParseResolution(resolution string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(resolution), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid resolution: %s", resolution)
+	}
+
+	width, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution width: %s", resolution)
+	}
+	height, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution height: %s", resolution)
+	}
+
+	return width, height, nil
+}
+
+// ParseAspectRatio 解析 "16:9" 格式的画面比例
+func ParseAspectRatio(ratio string) (int, int, error) {
+	parts := strings.Split(ratio, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %s", ratio)
+	}
+
+	w, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || w <= 0 {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %s", ratio)
+	}
+	h, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid aspect ratio: %s", ratio)
+	}
+
+	return w, h, nil
+}
+
+// FitAspectRatio 保持基准分辨率的短边,按画面比例计算宽高
+// 例如基准1920x1080: 16:9得到1920x1080,9:16得到1080x1920,1:1得到1080x1080
+func FitAspectRatio(baseWidth, baseHeight int, ratio string) (int, int, error) {
+	rw, rh, err := ParseAspectRatio(ratio)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	short := min(baseWidth, baseHeight)
+	if rw >= rh {
+		return even(short * rw / rh), even(short), nil
+	}
+	return even(short), even(short * rh / rw), nil
+}
+
+// ScaleToLongSide 等比缩放使长边为 longSide,宽高对齐到 multiple 的整数倍
+func ScaleToLongSide(width, height, longSide, multiple int) (int, int) {
+	if longSide <= 0 {
+		return width, height
+	}
+
+	var w, h float64
+	if width >= height {
+		w = float64(longSide)
+		h = float64(height) * float64(longSide) / float64(width)
+	} else {
+		h = float64(longSide)
+		w = float64(width) * float64(longSide) / float64(height)
+	}
+
+	return alignTo(w, multiple), alignTo(h, multiple)
+}
+
+// even 向下对齐到偶数(H.264编码要求宽高为偶数)
+func even(n int) int {
+	return n - n%2
+}
+
+// alignTo 四舍五入对齐到 multiple 的整数倍
+func alignTo(v float64, multiple int) int {
+	if multiple <= 1 {
+		return int(v + 0.5)
+	}
+	n := (int(v) + multiple/2) / multiple * multiple
+	if n < multiple {
+		n = multiple
+	}
+	return n
+}